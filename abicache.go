@@ -0,0 +1,290 @@
+package dkafka
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/eoscanada/eos-go"
+	pbbstream "github.com/streamingfast/pbgo/dfuse/bstream/v1"
+	"go.uber.org/zap"
+)
+
+const abiCacheFileExt = ".json"
+
+// AbiCache is a disk-backed ABI store. Each ABI version is saved under
+// <dir>/<account>/<abi-block-num>.json so it survives restarts and can be
+// used when the abicodec service is not reachable.
+type AbiCache struct {
+	dir string
+}
+
+// AbiCacheEntry is the on-disk representation of one ABI version. The
+// CheckedBlockNum is the highest block number at which the ABI was known to
+// be the active one.
+type AbiCacheEntry struct {
+	Account         string   `json:"account"`
+	AbiBlockNum     uint32   `json:"abi_block_num"`
+	CheckedBlockNum uint32   `json:"checked_block_num"`
+	ABI             *eos.ABI `json:"abi"`
+}
+
+func (e AbiCacheEntry) asABI() *ABI {
+	return &ABI{
+		ABI:          e.ABI,
+		AbiBlockNum:  e.AbiBlockNum,
+		Account:      e.Account,
+		Irreversible: true,
+	}
+}
+
+func NewAbiCache(dir string) (*AbiCache, error) {
+	if dir == "" {
+		return nil, fmt.Errorf("abi cache directory cannot be empty")
+	}
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		return nil, fmt.Errorf("cannot create abi cache directory: %s, error: %w", dir, err)
+	}
+	return &AbiCache{dir: dir}, nil
+}
+
+func (c *AbiCache) accountDir(account string) string {
+	return filepath.Join(c.dir, account)
+}
+
+func (c *AbiCache) entryPath(account string, abiBlockNum uint32) string {
+	return filepath.Join(c.accountDir(account), fmt.Sprintf("%d%s", abiBlockNum, abiCacheFileExt))
+}
+
+// Accounts returns the sorted list of accounts present in the cache.
+func (c *AbiCache) Accounts() ([]string, error) {
+	files, err := os.ReadDir(c.dir)
+	if err != nil {
+		return nil, fmt.Errorf("cannot read abi cache directory: %s, error: %w", c.dir, err)
+	}
+	accounts := make([]string, 0, len(files))
+	for _, f := range files {
+		if f.IsDir() {
+			accounts = append(accounts, f.Name())
+		}
+	}
+	sort.Strings(accounts)
+	return accounts, nil
+}
+
+// BlockNums returns the ABI block numbers cached for the given account in
+// ascending order.
+func (c *AbiCache) BlockNums(account string) ([]uint32, error) {
+	files, err := os.ReadDir(c.accountDir(account))
+	if err != nil {
+		if os.IsNotExist(err) {
+			return nil, nil
+		}
+		return nil, fmt.Errorf("cannot read abi cache of account: %s, error: %w", account, err)
+	}
+	blockNums := make([]uint32, 0, len(files))
+	for _, f := range files {
+		name := f.Name()
+		if f.IsDir() || !strings.HasSuffix(name, abiCacheFileExt) {
+			continue
+		}
+		blockNum, err := strconv.ParseUint(strings.TrimSuffix(name, abiCacheFileExt), 10, 32)
+		if err != nil {
+			zlog.Warn("ignore unexpected file in abi cache", zap.String("account", account), zap.String("file", name))
+			continue
+		}
+		blockNums = append(blockNums, uint32(blockNum))
+	}
+	sort.Slice(blockNums, func(i, j int) bool { return blockNums[i] < blockNums[j] })
+	return blockNums, nil
+}
+
+// Get reads the ABI version of the account activated at abiBlockNum.
+func (c *AbiCache) Get(account string, abiBlockNum uint32) (entry AbiCacheEntry, err error) {
+	path := c.entryPath(account, abiBlockNum)
+	content, err := os.ReadFile(path)
+	if err != nil {
+		return entry, fmt.Errorf("cannot read abi cache entry: %s, error: %w", path, err)
+	}
+	if err = json.Unmarshal(content, &entry); err != nil {
+		return entry, fmt.Errorf("cannot decode abi cache entry: %s, error: %w", path, err)
+	}
+	return entry, nil
+}
+
+// Lookup returns the ABI version of the account active at blockNum, i.e. the
+// one with the highest activation block lower or equal to blockNum.
+func (c *AbiCache) Lookup(account string, blockNum uint32) (entry AbiCacheEntry, found bool, err error) {
+	blockNums, err := c.BlockNums(account)
+	if err != nil {
+		return
+	}
+	for i := len(blockNums) - 1; i >= 0; i-- {
+		if blockNums[i] <= blockNum {
+			entry, err = c.Get(account, blockNums[i])
+			found = err == nil
+			return
+		}
+	}
+	return
+}
+
+// Put saves the ABI in the cache and records that it was still active at
+// checkedBlockNum. An existing entry keeps its highest checked block number.
+func (c *AbiCache) Put(abi *ABI, checkedBlockNum uint32) error {
+	if abi == nil || abi.ABI == nil {
+		return fmt.Errorf("cannot cache an empty abi")
+	}
+	if checkedBlockNum < abi.AbiBlockNum {
+		checkedBlockNum = abi.AbiBlockNum
+	}
+	if previous, err := c.Get(abi.Account, abi.AbiBlockNum); err == nil && previous.CheckedBlockNum > checkedBlockNum {
+		checkedBlockNum = previous.CheckedBlockNum
+	}
+	content, err := json.Marshal(AbiCacheEntry{
+		Account:         abi.Account,
+		AbiBlockNum:     abi.AbiBlockNum,
+		CheckedBlockNum: checkedBlockNum,
+		ABI:             abi.ABI,
+	})
+	if err != nil {
+		return fmt.Errorf("cannot encode abi cache entry for account: %s, error: %w", abi.Account, err)
+	}
+	if err = os.MkdirAll(c.accountDir(abi.Account), 0755); err != nil {
+		return fmt.Errorf("cannot create abi cache directory for account: %s, error: %w", abi.Account, err)
+	}
+	path := c.entryPath(abi.Account, abi.AbiBlockNum)
+	// write then rename to never leave a partially written entry behind
+	tmp, err := os.CreateTemp(c.accountDir(abi.Account), ".tmp-*")
+	if err != nil {
+		return fmt.Errorf("cannot create abi cache entry: %s, error: %w", path, err)
+	}
+	if _, err = tmp.Write(content); err != nil {
+		tmp.Close()
+		os.Remove(tmp.Name())
+		return fmt.Errorf("cannot write abi cache entry: %s, error: %w", path, err)
+	}
+	if err = tmp.Close(); err != nil {
+		os.Remove(tmp.Name())
+		return fmt.Errorf("cannot write abi cache entry: %s, error: %w", path, err)
+	}
+	if err = os.Rename(tmp.Name(), path); err != nil {
+		os.Remove(tmp.Name())
+		return fmt.Errorf("cannot write abi cache entry: %s, error: %w", path, err)
+	}
+	return nil
+}
+
+// Remove deletes the ABI version of the account activated at abiBlockNum.
+func (c *AbiCache) Remove(account string, abiBlockNum uint32) error {
+	err := os.Remove(c.entryPath(account, abiBlockNum))
+	if err != nil && !os.IsNotExist(err) {
+		return fmt.Errorf("cannot remove abi cache entry for account: %s at block: %d, error: %w", account, abiBlockNum, err)
+	}
+	return nil
+}
+
+// Prune removes the ABI versions of the account activated before
+// beforeBlockNum (0 means no limit) while always keeping the keep most
+// recent ones. It returns the removed ABI block numbers.
+func (c *AbiCache) Prune(account string, keep int, beforeBlockNum uint32) ([]uint32, error) {
+	blockNums, err := c.BlockNums(account)
+	if err != nil {
+		return nil, err
+	}
+	if keep < 0 {
+		keep = 0
+	}
+	var removed []uint32
+	for i := 0; i < len(blockNums)-keep; i++ {
+		if beforeBlockNum != 0 && blockNums[i] >= beforeBlockNum {
+			break
+		}
+		if err := c.Remove(account, blockNums[i]); err != nil {
+			return removed, err
+		}
+		removed = append(removed, blockNums[i])
+	}
+	return removed, nil
+}
+
+// abiRecorder is implemented by the AbiRepository that must be notified
+// of the ABI changes seen on chain.
+type abiRecorder interface {
+	recordABI(abi *ABI, step pbbstream.ForkStep)
+}
+
+// abiOverrider is implemented by the AbiRepository that hold user provided
+// ABIs which must always take precedence over the cached ones.
+type abiOverrider interface {
+	overriddenVersions(contract string) []*ABI
+}
+
+// CachedAbiRepository serves the ABIs from the disk cache when they are known
+// to be active at the requested block and delegates to the remote repository
+// otherwise. Every ABI fetched remotely is saved in the cache. When the remote
+// fails the closest cached ABI is used instead.
+type CachedAbiRepository struct {
+	cache  *AbiCache
+	remote AbiRepository
+}
+
+func NewCachedAbiRepository(cache *AbiCache, remote AbiRepository) *CachedAbiRepository {
+	return &CachedAbiRepository{
+		cache:  cache,
+		remote: remote,
+	}
+}
+
+func (r *CachedAbiRepository) IsNOOP() bool {
+	return false
+}
+
+func (r *CachedAbiRepository) GetAbi(contract string, blockNum uint32) (*ABI, error) {
+	if len(r.overriddenVersions(contract)) > 0 {
+		return r.remote.GetAbi(contract, blockNum)
+	}
+	entry, found, cacheErr := r.cache.Lookup(contract, blockNum)
+	if cacheErr != nil {
+		zlog.Warn("fail to lookup abi cache", zap.String("contract", contract), zap.Uint32("block_num", blockNum), zap.Error(cacheErr))
+	}
+	if found && blockNum <= entry.CheckedBlockNum {
+		zlog.Debug("abi found in cache", zap.String("contract", contract), zap.Uint32("block_num", blockNum), zap.Uint32("abi_block_num", entry.AbiBlockNum))
+		return entry.asABI(), nil
+	}
+	abi, err := r.remote.GetAbi(contract, blockNum)
+	if err != nil {
+		if found {
+			zlog.Warn("fail to get abi from remote use cached one", zap.String("contract", contract), zap.Uint32("block_num", blockNum), zap.Uint32("abi_block_num", entry.AbiBlockNum), zap.Error(err))
+			return entry.asABI(), nil
+		}
+		return nil, err
+	}
+	if err := r.cache.Put(abi, blockNum); err != nil {
+		zlog.Warn("fail to save abi in cache", zap.String("contract", contract), zap.Uint32("abi_block_num", abi.AbiBlockNum), zap.Error(err))
+	}
+	return abi, nil
+}
+
+func (r *CachedAbiRepository) overriddenVersions(contract string) []*ABI {
+	if overrider, ok := r.remote.(abiOverrider); ok {
+		return overrider.overriddenVersions(contract)
+	}
+	return nil
+}
+
+func (r *CachedAbiRepository) recordABI(abi *ABI, step pbbstream.ForkStep) {
+	var err error
+	if step == pbbstream.ForkStep_STEP_UNDO {
+		err = r.cache.Remove(abi.Account, abi.AbiBlockNum)
+	} else {
+		err = r.cache.Put(abi, abi.AbiBlockNum)
+	}
+	if err != nil {
+		zlog.Warn("fail to record abi update in cache", zap.String("contract", abi.Account), zap.Uint32("abi_block_num", abi.AbiBlockNum), zap.Stringer("step", step), zap.Error(err))
+	}
+}
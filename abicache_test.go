@@ -0,0 +1,237 @@
+package dkafka
+
+import (
+	"fmt"
+	"testing"
+
+	"github.com/eoscanada/eos-go"
+	"github.com/go-test/deep"
+	pbbstream "github.com/streamingfast/pbgo/dfuse/bstream/v1"
+)
+
+func newTestAbiCache(t *testing.T, abis ...*ABI) *AbiCache {
+	cache, err := NewAbiCache(t.TempDir())
+	if err != nil {
+		t.Fatalf("NewAbiCache() error: %v", err)
+	}
+	for _, abi := range abis {
+		if err := cache.Put(abi, abi.AbiBlockNum); err != nil {
+			t.Fatalf("AbiCache.Put() error: %v", err)
+		}
+	}
+	return cache
+}
+
+func TestAbiCache_Lookup(t *testing.T) {
+	cache := newTestAbiCache(t,
+		&ABI{ABI: &eos.ABI{Version: "1"}, AbiBlockNum: 10, Account: "test"},
+		&ABI{ABI: &eos.ABI{Version: "2"}, AbiBlockNum: 20, Account: "test"},
+	)
+	tests := []struct {
+		name        string
+		account     string
+		blockNum    uint32
+		wantFound   bool
+		wantVersion string
+	}{
+		{
+			name:      "before first version",
+			account:   "test",
+			blockNum:  9,
+			wantFound: false,
+		},
+		{
+			name:        "first version",
+			account:     "test",
+			blockNum:    15,
+			wantFound:   true,
+			wantVersion: "1",
+		},
+		{
+			name:        "last version",
+			account:     "test",
+			blockNum:    42,
+			wantFound:   true,
+			wantVersion: "2",
+		},
+		{
+			name:      "unknown account",
+			account:   "unknown",
+			blockNum:  42,
+			wantFound: false,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			entry, found, err := cache.Lookup(tt.account, tt.blockNum)
+			if err != nil {
+				t.Fatalf("AbiCache.Lookup() error: %v", err)
+			}
+			if found != tt.wantFound {
+				t.Fatalf("AbiCache.Lookup() found = %v, want %v", found, tt.wantFound)
+			}
+			if found && entry.ABI.Version != tt.wantVersion {
+				t.Errorf("AbiCache.Lookup() version = %v, want %v", entry.ABI.Version, tt.wantVersion)
+			}
+		})
+	}
+}
+
+func TestAbiCache_Put_keepHighestCheckedBlock(t *testing.T) {
+	abi := &ABI{ABI: &eos.ABI{Version: "1"}, AbiBlockNum: 10, Account: "test"}
+	cache := newTestAbiCache(t)
+	for _, checked := range []uint32{30, 20} {
+		if err := cache.Put(abi, checked); err != nil {
+			t.Fatalf("AbiCache.Put() error: %v", err)
+		}
+	}
+	entry, err := cache.Get("test", 10)
+	if err != nil {
+		t.Fatalf("AbiCache.Get() error: %v", err)
+	}
+	if entry.CheckedBlockNum != 30 {
+		t.Errorf("AbiCache.Get() checked block = %v, want %v", entry.CheckedBlockNum, 30)
+	}
+}
+
+func TestAbiCache_Prune(t *testing.T) {
+	tests := []struct {
+		name        string
+		keep        int
+		before      uint32
+		wantRemoved []uint32
+		wantLeft    []uint32
+	}{
+		{
+			name:        "keep last",
+			keep:        1,
+			wantRemoved: []uint32{10, 20},
+			wantLeft:    []uint32{30},
+		},
+		{
+			name:        "before block",
+			keep:        0,
+			before:      20,
+			wantRemoved: []uint32{10},
+			wantLeft:    []uint32{20, 30},
+		},
+		{
+			name:        "keep wins over before block",
+			keep:        2,
+			before:      40,
+			wantRemoved: []uint32{10},
+			wantLeft:    []uint32{20, 30},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			cache := newTestAbiCache(t,
+				&ABI{ABI: &eos.ABI{Version: "1"}, AbiBlockNum: 10, Account: "test"},
+				&ABI{ABI: &eos.ABI{Version: "2"}, AbiBlockNum: 20, Account: "test"},
+				&ABI{ABI: &eos.ABI{Version: "3"}, AbiBlockNum: 30, Account: "test"},
+			)
+			removed, err := cache.Prune("test", tt.keep, tt.before)
+			if err != nil {
+				t.Fatalf("AbiCache.Prune() error: %v", err)
+			}
+			if diff := deep.Equal(removed, tt.wantRemoved); diff != nil {
+				t.Errorf("AbiCache.Prune() removed diff: %v", diff)
+			}
+			left, err := cache.BlockNums("test")
+			if err != nil {
+				t.Fatalf("AbiCache.BlockNums() error: %v", err)
+			}
+			if diff := deep.Equal(left, tt.wantLeft); diff != nil {
+				t.Errorf("AbiCache.BlockNums() diff: %v", diff)
+			}
+		})
+	}
+}
+
+func TestCachedAbiRepository_GetAbi(t *testing.T) {
+	cached := &ABI{ABI: &eos.ABI{Version: "cached"}, AbiBlockNum: 10, Account: "test", Irreversible: true}
+	remote := &ABI{ABI: &eos.ABI{Version: "remote"}, AbiBlockNum: 10, Account: "test", Irreversible: true}
+	tests := []struct {
+		name        string
+		remote      AbiRepository
+		blockNum    uint32
+		want        *ABI
+		wantChecked uint32
+		wantErr     bool
+	}{
+		{
+			name:        "served from cache when checked",
+			remote:      &AbiRepositoryStub{err: fmt.Errorf("must not be called")},
+			blockNum:    10,
+			want:        cached,
+			wantChecked: 10,
+		},
+		{
+			name:        "fetched from remote after checked block",
+			remote:      &AbiRepositoryStub{abi: remote},
+			blockNum:    42,
+			want:        remote,
+			wantChecked: 42,
+		},
+		{
+			name:        "fallback on cache when remote fail",
+			remote:      &AbiRepositoryStub{err: fmt.Errorf("unavailable")},
+			blockNum:    42,
+			want:        cached,
+			wantChecked: 10,
+		},
+		{
+			name:     "fail when remote fail and nothing cached",
+			remote:   &AbiRepositoryStub{err: fmt.Errorf("unavailable")},
+			blockNum: 9,
+			wantErr:  true,
+		},
+		{
+			name: "overrides are never cached",
+			remote: &DfuseAbiRepository{overrides: map[string][]*ABI{
+				"test": {{ABI: &eos.ABI{Version: "override"}, AbiBlockNum: 0, Account: "test"}},
+			}},
+			blockNum:    10,
+			want:        &ABI{ABI: &eos.ABI{Version: "override"}, AbiBlockNum: 0, Account: "test"},
+			wantChecked: 10,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			cache := newTestAbiCache(t, cached)
+			r := NewCachedAbiRepository(cache, tt.remote)
+			got, err := r.GetAbi("test", tt.blockNum)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("CachedAbiRepository.GetAbi() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if tt.wantErr {
+				return
+			}
+			if diff := deep.Equal(got, tt.want); diff != nil {
+				t.Errorf("CachedAbiRepository.GetAbi() diff: %v", diff)
+			}
+			entry, err := cache.Get("test", 10)
+			if err != nil {
+				t.Fatalf("AbiCache.Get() error: %v", err)
+			}
+			if entry.CheckedBlockNum != tt.wantChecked {
+				t.Errorf("AbiCache.Get() checked block = %v, want %v", entry.CheckedBlockNum, tt.wantChecked)
+			}
+		})
+	}
+}
+
+func TestCachedAbiRepository_recordABI(t *testing.T) {
+	cache := newTestAbiCache(t)
+	r := NewCachedAbiRepository(cache, &AbiRepositoryStub{})
+	abi := &ABI{ABI: &eos.ABI{Version: "1"}, AbiBlockNum: 42, Account: "test"}
+
+	r.recordABI(abi, pbbstream.ForkStep_STEP_NEW)
+	if _, err := cache.Get("test", 42); err != nil {
+		t.Fatalf("ABI not recorded on new step: %v", err)
+	}
+	r.recordABI(abi, pbbstream.ForkStep_STEP_UNDO)
+	if blockNums, _ := cache.BlockNums("test"); len(blockNums) != 0 {
+		t.Errorf("ABI not removed on undo step, got: %v", blockNums)
+	}
+}
@@ -7,6 +7,7 @@ import (
 	"fmt"
 	"math"
 	"os"
+	"sort"
 	"strconv"
 	"strings"
 
@@ -34,6 +35,7 @@ type ABICodec interface {
 	DecodeDBOp(in *pbcodec.DBOp, blockNum uint32) (*decodedDBOp, error)
 	GetCodec(codecId CodecId, blockNum uint32) (Codec, error)
 	UpdateABI(blockNum uint32, step pbbstream.ForkStep, trxID string, actionTrace *pbcodec.ActionTrace) error
+	GetABI(account string, blockNum uint32) (*ABI, error)
 }
 
 type JsonABICodec struct {
@@ -50,6 +52,10 @@ func (c *JsonABICodec) UpdateABI(_ uint32, _ pbbstream.ForkStep, _ string, _ *pb
 	return nil
 }
 
+func (c *JsonABICodec) GetABI(account string, blockNum uint32) (*ABI, error) {
+	return c.abi(account, blockNum, false)
+}
+
 func NewJsonABICodec(
 	decoder *ABIDecoder,
 	account string,
@@ -67,26 +73,44 @@ type MessageSchemaSupplier = func(string, *ABI) (MessageSchema, error)
 
 // ABIDecoder legacy abi codec does not support schema registry
 type ABIDecoder struct {
-	overrides   map[string]*ABI
+	overrides   map[string][]*ABI
 	abiCodecCli pbabicodec.DecoderClient
-	abisCache   map[string]*ABI
-	context     context.Context
+	// remote is used instead of the abicodec client when set
+	remote    AbiRepository
+	abisCache map[string]*ABI
+	abiCache  *AbiCache
+	context   context.Context
 }
 
 func (a *ABIDecoder) IsNOOP() bool {
-	return a.overrides == nil && a.abiCodecCli == nil
+	return a.overrides == nil && a.abiCodecCli == nil && a.remote == nil && a.abiCache == nil
 }
 
-func ParseABIFileSpecs(specs []string) (abiFileSpecs map[string]string, err error) {
-	abiFileSpecs = make(map[string]string)
+// ParseABIFileSpecs groups the local ABI file definitions by account. An
+// account can have several ABI files as long as each one has its own
+// activation block number.
+func ParseABIFileSpecs(specs []string) (map[string][]string, error) {
+	abiFileSpecs := make(map[string][]string)
+	activations := make(map[string]map[uint32]string)
 	for _, ext := range specs {
-		account, abiPath, err := ParseABIFileSpec(ext)
+		account, abiFile, err := ParseABIFileSpec(ext)
+		if err != nil {
+			return nil, err
+		}
+		_, abiBlockNum, err := parseABIFile(abiFile)
 		if err != nil {
-			break
+			return nil, fmt.Errorf("invalid block number for local ABI file: %s, error: %w", ext, err)
 		}
-		abiFileSpecs[account] = abiPath
+		if activations[account] == nil {
+			activations[account] = make(map[uint32]string)
+		}
+		if previous, found := activations[account][abiBlockNum]; found {
+			return nil, fmt.Errorf("overlapping local ABI files for account: %s at block: %d, '%s' and '%s'", account, abiBlockNum, previous, abiFile)
+		}
+		activations[account][abiBlockNum] = abiFile
+		abiFileSpecs[account] = append(abiFileSpecs[account], abiFile)
 	}
-	return
+	return abiFileSpecs, nil
 }
 
 func ParseABIFileSpec(spec string) (account string, abiPath string, err error) {
@@ -100,30 +124,47 @@ func ParseABIFileSpec(spec string) (account string, abiPath string, err error) {
 	return
 }
 
-// LoadABIFiles will load ABIs for different accounts from JSON files
-func LoadABIFiles(abiFiles map[string]string) (map[string]*ABI, error) {
-	out := make(map[string]*ABI)
-	for contract, abiFile := range abiFiles {
-		abi, err := LoadABIFile(contract, abiFile)
-		if err != nil {
-			return nil, fmt.Errorf("reading abi file %s: %w", abiFile, err)
+// parseABIFile splits an ABI file definition '{path}[:{block-number}]'
+func parseABIFile(abiFile string) (abiPath string, abiBlockNum uint32, err error) {
+	kv := strings.SplitN(abiFile, ":", 2) //[abiFilePath] - [abiFilePath, abiNumber]
+	abiPath = abiFile
+	if len(kv) == 2 {
+		abiPath = kv[0]
+		var blockNum uint64
+		blockNum, err = strconv.ParseUint(kv[1], 10, 32)
+		abiBlockNum = uint32(blockNum)
+	}
+	return
+}
+
+// LoadABIFiles will load ABIs for different accounts from JSON files. The
+// ABIs of each account are sorted by activation block number.
+func LoadABIFiles(abiFiles map[string][]string) (map[string][]*ABI, error) {
+	out := make(map[string][]*ABI)
+	for contract, files := range abiFiles {
+		abis := make([]*ABI, 0, len(files))
+		for _, abiFile := range files {
+			abi, err := LoadABIFile(contract, abiFile)
+			if err != nil {
+				return nil, fmt.Errorf("reading abi file %s: %w", abiFile, err)
+			}
+			abis = append(abis, abi)
 		}
-		out[contract] = abi
+		sort.SliceStable(abis, func(i, j int) bool { return abis[i].AbiBlockNum < abis[j].AbiBlockNum })
+		for i := 1; i < len(abis); i++ {
+			if abis[i].AbiBlockNum == abis[i-1].AbiBlockNum {
+				return nil, fmt.Errorf("overlapping abi files for account: %s at block: %d", contract, abis[i].AbiBlockNum)
+			}
+		}
+		out[contract] = abis
 	}
 	return out, nil
 }
 
 func LoadABIFile(account string, abiFile string) (*ABI, error) {
-	kv := strings.SplitN(abiFile, ":", 2) //[abiFilePath] - [abiFilePath, abiNumber]
-	var abiPath = abiFile
-	var abiBlockNum = uint64(0)
-	if len(kv) == 2 {
-		abiPath = kv[0]
-		var err error
-		abiBlockNum, err = strconv.ParseUint(kv[1], 10, 32)
-		if err != nil {
-			return nil, err
-		}
+	abiPath, abiBlockNum, err := parseABIFile(abiFile)
+	if err != nil {
+		return nil, err
 	}
 	f, err := os.Open(abiPath)
 	if err == nil {
@@ -131,7 +172,7 @@ func LoadABIFile(account string, abiFile string) (*ABI, error) {
 		eosAbi, err := eos.NewABI(f)
 		abi := &ABI{
 			ABI:          eosAbi,
-			AbiBlockNum:  uint32(abiBlockNum),
+			AbiBlockNum:  abiBlockNum,
 			Account:      account,
 			Irreversible: true,
 		}
@@ -140,8 +181,31 @@ func LoadABIFile(account string, abiFile string) (*ABI, error) {
 	return nil, err
 }
 
+// abiVersionAt returns the ABI with the highest activation block lower or
+// equal to blockNum from versions sorted by activation block number.
+func abiVersionAt(versions []*ABI, blockNum uint32) *ABI {
+	i := sort.Search(len(versions), func(i int) bool { return versions[i].AbiBlockNum > blockNum })
+	if i == 0 {
+		return nil
+	}
+	return versions[i-1]
+}
+
+// overrideAt returns the local ABI active at blockNum. The first local ABI is
+// also used for the blocks preceding its activation.
+func overrideAt(overrides map[string][]*ABI, contract string, blockNum uint32) (*ABI, bool) {
+	versions, found := overrides[contract]
+	if !found || len(versions) == 0 {
+		return nil, false
+	}
+	if abi := abiVersionAt(versions, blockNum); abi != nil {
+		return abi, true
+	}
+	return versions[0], true
+}
+
 func NewABIDecoder(
-	overrides map[string]*ABI,
+	overrides map[string][]*ABI,
 	abiCodecCli pbabicodec.DecoderClient,
 	context context.Context,
 ) *ABIDecoder {
@@ -181,14 +245,8 @@ func addOptional(m *map[string]interface{}, key string, value map[string]interfa
 // }
 
 func (a *ABIDecoder) abi(contract string, blockNum uint32, forceRefresh bool) (*ABI, error) {
-	if a.overrides != nil {
-		if abi, ok := a.overrides[contract]; ok {
-			return abi, nil
-		}
-	}
-
-	if a.abiCodecCli == nil {
-		return nil, fmt.Errorf("unable to get abi for contract %q", contract)
+	if abi, ok := overrideAt(a.overrides, contract, blockNum); ok {
+		return abi, nil
 	}
 
 	if !forceRefresh {
@@ -197,26 +255,75 @@ func (a *ABIDecoder) abi(contract string, blockNum uint32, forceRefresh bool) (*
 				return abiObj, nil
 			}
 		}
+		if abi, ok := a.cachedAbi(contract, blockNum, true); ok {
+			a.abisCache[contract] = abi
+			return abi, nil
+		}
+	}
+
+	if a.abiCodecCli == nil && a.remote == nil {
+		if abi, ok := a.cachedAbi(contract, blockNum, false); ok {
+			return abi, nil
+		}
+		return nil, fmt.Errorf("unable to get abi for contract %q", contract)
 	}
 	zlog.Info("ABIDecoder.abi(...) => call onReload()", zap.String("contract", contract), zap.Uint32("block_num", blockNum), zap.Bool("force_refresh", forceRefresh))
+	abi, err := a.fetchAbi(contract, blockNum)
+	if err != nil {
+		if abi, ok := a.cachedAbi(contract, blockNum, false); ok {
+			zlog.Warn("fail to get abi from remote use cached one", zap.String("contract", contract), zap.Uint32("block_num", blockNum), zap.Error(err))
+			return abi, nil
+		}
+		return nil, fmt.Errorf("unable to get abi for contract %q: %w", contract, err)
+	}
+	zlog.Info("new ABI loaded", zap.String("contract", contract), zap.Uint32("block_num", blockNum), zap.Uint32("abi_block_num", abi.AbiBlockNum))
+	// store abi in cache for late uses
+	a.abisCache[contract] = abi
+	if a.abiCache != nil {
+		if err := a.abiCache.Put(abi, blockNum); err != nil {
+			zlog.Warn("fail to save abi in cache", zap.String("contract", contract), zap.Uint32("abi_block_num", abi.AbiBlockNum), zap.Error(err))
+		}
+	}
+	return abi, nil
+}
+
+// fetchAbi gets the ABI from the remote repository if any or from the
+// abicodec service.
+func (a *ABIDecoder) fetchAbi(contract string, blockNum uint32) (*ABI, error) {
+	if a.remote != nil {
+		return a.remote.GetAbi(contract, blockNum)
+	}
 	resp, err := a.abiCodecCli.GetAbi(a.context, &pbabicodec.GetAbiRequest{
 		Account:    contract,
 		AtBlockNum: blockNum,
 	})
 	if err != nil {
-		return nil, fmt.Errorf("unable to get abi for contract %q: %w", contract, err)
+		return nil, err
 	}
-
 	var eosAbi *eos.ABI
 	err = json.Unmarshal([]byte(resp.JsonPayload), &eosAbi)
 	if err != nil {
 		return nil, fmt.Errorf("unable to decode abi for contract %q: %w", contract, err)
 	}
-	var abi = ABI{eosAbi, resp.AbiBlockNum, contract, true}
-	zlog.Info("new ABI loaded", zap.String("contract", contract), zap.Uint32("block_num", blockNum), zap.Uint32("abi_block_num", abi.AbiBlockNum))
-	// store abi in cache for late uses
-	a.abisCache[contract] = &abi
-	return &abi, nil
+	return &ABI{eosAbi, resp.AbiBlockNum, contract, true}, nil
+}
+
+// cachedAbi looks for the ABI active at blockNum in the disk cache. When
+// checked is set the ABI is only returned if it is known to be still active
+// at this block.
+func (a *ABIDecoder) cachedAbi(contract string, blockNum uint32, checked bool) (*ABI, bool) {
+	if a.abiCache == nil {
+		return nil, false
+	}
+	entry, found, err := a.abiCache.Lookup(contract, blockNum)
+	if err != nil {
+		zlog.Warn("fail to lookup abi cache", zap.String("contract", contract), zap.Uint32("block_num", blockNum), zap.Error(err))
+		return nil, false
+	}
+	if !found || (checked && blockNum > entry.CheckedBlockNum) {
+		return nil, false
+	}
+	return entry.asABI(), true
 }
 
 func (a *ABIDecoder) decodeDBOp(op *decodedDBOp, blockNum uint32, forceRefresh bool) error {
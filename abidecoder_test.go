@@ -5,6 +5,7 @@ import (
 	"testing"
 
 	"github.com/eoscanada/eos-go"
+	"github.com/go-test/deep"
 )
 
 func TestParseABIFileSpec(t *testing.T) {
@@ -61,6 +62,114 @@ func TestParseABIFileSpec(t *testing.T) {
 	}
 }
 
+func TestParseABIFileSpecs(t *testing.T) {
+	tests := []struct {
+		name    string
+		specs   []string
+		want    map[string][]string
+		wantErr bool
+	}{
+		{
+			name:  "single",
+			specs: []string{"eosio.nft.ft:testdata/eosio.nft.ft.abi"},
+			want:  map[string][]string{"eosio.nft.ft": {"testdata/eosio.nft.ft.abi"}},
+		},
+		{
+			name: "multiple-per-account",
+			specs: []string{
+				"eosio.nft.ft:testdata/eosio.nft.ft-1.31.1.abi",
+				"eosio.token:testdata/eosio.token.abi",
+				"eosio.nft.ft:testdata/eosio.nft.ft-2.0.abi:100",
+			},
+			want: map[string][]string{
+				"eosio.nft.ft": {"testdata/eosio.nft.ft-1.31.1.abi", "testdata/eosio.nft.ft-2.0.abi:100"},
+				"eosio.token":  {"testdata/eosio.token.abi"},
+			},
+		},
+		{
+			name: "duplicate",
+			specs: []string{
+				"eosio.nft.ft:testdata/eosio.nft.ft.abi:1",
+				"eosio.nft.ft:testdata/eosio.nft.ft.abi:1",
+			},
+			wantErr: true,
+		},
+		{
+			name: "overlapping",
+			specs: []string{
+				"eosio.nft.ft:testdata/eosio.nft.ft-1.31.1.abi",
+				"eosio.nft.ft:testdata/eosio.nft.ft-2.0.abi:0",
+			},
+			wantErr: true,
+		},
+		{
+			name:    "invalid",
+			specs:   []string{"eosio.nft.ft:testdata/eosio.nft.ft.abi", "eosio.nft.f"},
+			wantErr: true,
+		},
+		{
+			name:    "invalid-block-number",
+			specs:   []string{"eosio.nft.ft:testdata/eosio.nft.ft.abi:first"},
+			wantErr: true,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := ParseABIFileSpecs(tt.specs)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("ParseABIFileSpecs() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if diff := deep.Equal(got, tt.want); diff != nil {
+				t.Errorf("ParseABIFileSpecs() diff: %v", diff)
+			}
+		})
+	}
+}
+
+func TestLoadABIFiles(t *testing.T) {
+	abis, err := LoadABIFiles(map[string][]string{
+		"eosio.nft.ft": {"testdata/eosio.nft.ft-2.0.abi:100", "testdata/eosio.nft.ft-1.31.1.abi:10"},
+	})
+	if err != nil {
+		t.Fatalf("LoadABIFiles() error: %v", err)
+	}
+	versions := abis["eosio.nft.ft"]
+	if len(versions) != 2 || versions[0].AbiBlockNum != 10 || versions[1].AbiBlockNum != 100 {
+		t.Fatalf("LoadABIFiles() must sort ABIs by activation block, got: %v", versions)
+	}
+	tests := []struct {
+		name            string
+		blockNum        uint32
+		wantAbiBlockNum uint32
+	}{
+		{"before-first-activation", 5, 10},
+		{"first-activation", 10, 10},
+		{"before-upgrade", 99, 10},
+		{"upgrade", 100, 100},
+		{"after-upgrade", 1000, 100},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, found := overrideAt(abis, "eosio.nft.ft", tt.blockNum)
+			if !found {
+				t.Fatalf("overrideAt() not found")
+			}
+			if got.AbiBlockNum != tt.wantAbiBlockNum {
+				t.Errorf("overrideAt() AbiBlockNum = %v, want %v", got.AbiBlockNum, tt.wantAbiBlockNum)
+			}
+		})
+	}
+	if _, found := overrideAt(abis, "eosio.token", 10); found {
+		t.Errorf("overrideAt() must not find unknown account")
+	}
+	_, err = LoadABIFiles(map[string][]string{
+		"eosio.nft.ft": {"testdata/eosio.nft.ft-2.0.abi:10", "testdata/eosio.nft.ft-1.31.1.abi:10"},
+	})
+	if err == nil {
+		t.Errorf("LoadABIFiles() must fail on overlapping ABIs")
+	}
+}
+
 func TestLoadABIFile(t *testing.T) {
 	type args struct {
 		account string
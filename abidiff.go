@@ -0,0 +1,223 @@
+package dkafka
+
+import (
+	"fmt"
+	"reflect"
+	"sort"
+
+	"github.com/eoscanada/eos-go"
+	"github.com/riferrei/srclient"
+)
+
+// DiffEntries lists the names of the added, removed and changed definitions
+// of one kind between two ABIs.
+type DiffEntries struct {
+	Added   []string
+	Removed []string
+	Changed []string
+}
+
+func (d DiffEntries) IsEmpty() bool {
+	return len(d.Added) == 0 && len(d.Removed) == 0 && len(d.Changed) == 0
+}
+
+// AbiDiff is the structural difference between two versions of an ABI.
+// Fields are named as <struct>.<field>.
+type AbiDiff struct {
+	Structs  DiffEntries
+	Fields   DiffEntries
+	Tables   DiffEntries
+	Actions  DiffEntries
+	Variants DiffEntries
+}
+
+func (d AbiDiff) IsEmpty() bool {
+	return d.Structs.IsEmpty() && d.Fields.IsEmpty() && d.Tables.IsEmpty() && d.Actions.IsEmpty() && d.Variants.IsEmpty()
+}
+
+// DiffABI compares the previous and next versions of an ABI.
+func DiffABI(previous *eos.ABI, next *eos.ABI) AbiDiff {
+	diff := AbiDiff{
+		Structs:  diffDefinitions(structsByName(previous), structsByName(next)),
+		Tables:   diffDefinitions(tablesByName(previous), tablesByName(next)),
+		Actions:  diffDefinitions(actionsByName(previous), actionsByName(next)),
+		Variants: diffDefinitions(variantsByName(previous), variantsByName(next)),
+	}
+	previousStructs := structsByName(previous)
+	nextStructs := structsByName(next)
+	for _, name := range diff.Structs.Changed {
+		fields := diffDefinitions(fieldsByName(previousStructs[name]), fieldsByName(nextStructs[name]))
+		diff.Fields.Added = append(diff.Fields.Added, prefixed(name, fields.Added)...)
+		diff.Fields.Removed = append(diff.Fields.Removed, prefixed(name, fields.Removed)...)
+		diff.Fields.Changed = append(diff.Fields.Changed, prefixed(name, fields.Changed)...)
+	}
+	return diff
+}
+
+func prefixed(prefix string, names []string) []string {
+	result := make([]string, 0, len(names))
+	for _, name := range names {
+		result = append(result, fmt.Sprintf("%s.%s", prefix, name))
+	}
+	return result
+}
+
+func diffDefinitions(previous map[string]interface{}, next map[string]interface{}) (diff DiffEntries) {
+	for name, def := range next {
+		previousDef, found := previous[name]
+		if !found {
+			diff.Added = append(diff.Added, name)
+		} else if !reflect.DeepEqual(previousDef, def) {
+			diff.Changed = append(diff.Changed, name)
+		}
+	}
+	for name := range previous {
+		if _, found := next[name]; !found {
+			diff.Removed = append(diff.Removed, name)
+		}
+	}
+	sort.Strings(diff.Added)
+	sort.Strings(diff.Removed)
+	sort.Strings(diff.Changed)
+	return
+}
+
+func structsByName(abi *eos.ABI) map[string]interface{} {
+	defs := make(map[string]interface{})
+	for _, s := range abi.Structs {
+		defs[s.Name] = s
+	}
+	return defs
+}
+
+func fieldsByName(s interface{}) map[string]interface{} {
+	defs := make(map[string]interface{})
+	if structDef, ok := s.(eos.StructDef); ok {
+		for i, f := range structDef.Fields {
+			// the position is part of the binary layout
+			defs[f.Name] = struct {
+				Index int
+				Type  string
+			}{i, f.Type}
+		}
+	}
+	return defs
+}
+
+func tablesByName(abi *eos.ABI) map[string]interface{} {
+	defs := make(map[string]interface{})
+	for _, t := range abi.Tables {
+		defs[string(t.Name)] = t
+	}
+	return defs
+}
+
+func actionsByName(abi *eos.ABI) map[string]interface{} {
+	defs := make(map[string]interface{})
+	for _, a := range abi.Actions {
+		// the ricardian contract does not change the data layout
+		defs[string(a.Name)] = a.Type
+	}
+	return defs
+}
+
+func variantsByName(abi *eos.ABI) map[string]interface{} {
+	defs := make(map[string]interface{})
+	for _, v := range abi.Variants {
+		defs[v.Name] = v.Types
+	}
+	return defs
+}
+
+const (
+	SubjectAdded     = "added"
+	SubjectRemoved   = "removed"
+	SubjectChanged   = "changed"
+	SubjectUnchanged = "unchanged"
+)
+
+// SubjectCompatibility is the compatibility of one schema registry subject
+// between two versions of an ABI.
+type SubjectCompatibility struct {
+	Subject string
+	// Kind is either "table" or "action"
+	Kind          string
+	Name          string
+	Status        string
+	Compatibility AvroCompatibility
+}
+
+// Satisfies reports whether the subject change meets the required level.
+// Added and removed subjects are always compatible as a single schema
+// version is involved.
+func (s SubjectCompatibility) Satisfies(required srclient.CompatibilityLevel) bool {
+	switch s.Status {
+	case SubjectAdded, SubjectRemoved:
+		return true
+	default:
+		return s.Compatibility.Satisfies(required)
+	}
+}
+
+// CheckSchemasCompatibility generates the table and action schemas of both
+// ABI versions and checks for each subject the Avro compatibility of the
+// next schema against the previous one. The subjects are named with the
+// subjectName strategy used to register the schemas.
+func CheckSchemasCompatibility(previous *ABI, next *ABI, namespace string, subjectName SubjectNameStrategy) ([]SubjectCompatibility, error) {
+	var result []SubjectCompatibility
+	for _, kind := range []struct {
+		name     string
+		generate func(NamedSchemaGenOptions) (MessageSchema, error)
+		names    func(abi *eos.ABI) map[string]interface{}
+	}{
+		{"table", GenerateTableSchema, tablesByName},
+		{"action", GenerateActionSchema, actionsByName},
+	} {
+		previousNames := kind.names(previous.ABI)
+		nextNames := kind.names(next.ABI)
+		names := make([]string, 0, len(nextNames))
+		for name := range nextNames {
+			names = append(names, name)
+		}
+		for name := range previousNames {
+			if _, found := nextNames[name]; !found {
+				names = append(names, name)
+			}
+		}
+		sort.Strings(names)
+		for _, name := range names {
+			subject := SubjectCompatibility{Kind: kind.name, Name: name}
+			var previousSchema, nextSchema MessageSchema
+			var err error
+			if _, found := previousNames[name]; found {
+				if previousSchema, err = kind.generate(NamedSchemaGenOptions{Name: name, Namespace: namespace, AbiSpec: previous, Domain: previous.Account}); err != nil {
+					return nil, fmt.Errorf("cannot generate previous %s: %s schema, error: %w", kind.name, name, err)
+				}
+				subject.Subject = subjectName(previousSchema)
+				subject.Status = SubjectRemoved
+			}
+			if _, found := nextNames[name]; found {
+				if nextSchema, err = kind.generate(NamedSchemaGenOptions{Name: name, Namespace: namespace, AbiSpec: next, Domain: next.Account}); err != nil {
+					return nil, fmt.Errorf("cannot generate next %s: %s schema, error: %w", kind.name, name, err)
+				}
+				subject.Subject = subjectName(nextSchema)
+				if subject.Status == SubjectRemoved {
+					subject.Compatibility = CheckAvroCompatibility(previousSchema.RecordSchema, nextSchema.RecordSchema)
+					if reflect.DeepEqual(previousSchema.RecordSchema, nextSchema.RecordSchema) {
+						subject.Status = SubjectUnchanged
+					} else {
+						subject.Status = SubjectChanged
+					}
+				} else {
+					subject.Status = SubjectAdded
+				}
+			}
+			result = append(result, subject)
+		}
+	}
+	return result, nil
+}
+
+func schemaSubject(schema MessageSchema) string {
+	return fmt.Sprintf("%s.%s", schema.Namespace, schema.Name)
+}
@@ -0,0 +1,121 @@
+package dkafka
+
+import (
+	"testing"
+
+	"github.com/go-test/deep"
+	"github.com/riferrei/srclient"
+)
+
+func TestDiffABI(t *testing.T) {
+	previous, err := LoadABIFile("eosio.nft.ft", "testdata/eosio.nft.ft-1.31.1.abi")
+	if err != nil {
+		t.Fatalf("LoadABIFile() error: %v", err)
+	}
+	next, err := LoadABIFile("eosio.nft.ft", "testdata/eosio.nft.ft-2.0.abi")
+	if err != nil {
+		t.Fatalf("LoadABIFile() error: %v", err)
+	}
+	if diff := DiffABI(previous.ABI, previous.ABI); !diff.IsEmpty() {
+		t.Errorf("DiffABI() on same ABI should be empty, got: %+v", diff)
+	}
+	diff := DiffABI(previous.ABI, next.ABI)
+	if d := deep.Equal(diff.Fields, DiffEntries{
+		Added:   []string{"create_wrap.account_minting_limit", "create_wrap.authorized_minters"},
+		Changed: []string{"authminter.token_factory_id", "setconrecv.token_factory_id", "setmeta.token_factory_id", "setstatus.token_factory_id"},
+	}); d != nil {
+		t.Errorf("DiffABI() fields diff: %v", d)
+	}
+	if d := deep.Equal(diff.Tables.Added, []string{"factory.b", "migration", "ramvault.a", "token.b"}); d != nil {
+		t.Errorf("DiffABI() added tables diff: %v", d)
+	}
+	if d := deep.Equal(DiffABI(next.ABI, previous.ABI).Tables.Removed, []string{"factory.b", "migration", "ramvault.a", "token.b"}); d != nil {
+		t.Errorf("DiffABI() removed tables diff: %v", d)
+	}
+}
+
+func TestCheckSchemasCompatibility(t *testing.T) {
+	previous, err := LoadABIFile("eosio.nft.ft", "testdata/eosio.nft.ft-1.31.1.abi")
+	if err != nil {
+		t.Fatalf("LoadABIFile() error: %v", err)
+	}
+	next, err := LoadABIFile("eosio.nft.ft", "testdata/eosio.nft.ft-2.0.abi")
+	if err != nil {
+		t.Fatalf("LoadABIFile() error: %v", err)
+	}
+	subjects, err := CheckSchemasCompatibility(previous, next, "", schemaSubject)
+	if err != nil {
+		t.Fatalf("CheckSchemasCompatibility() error: %v", err)
+	}
+	bySubject := make(map[string]SubjectCompatibility)
+	for _, s := range subjects {
+		bySubject[s.Subject] = s
+	}
+	tests := []struct {
+		subject      string
+		wantStatus   string
+		wantForward  bool
+		wantBackward bool
+	}{
+		{"eosio.nft.ft.FactoryATableNotification", SubjectUnchanged, true, true},
+		{"eosio.nft.ft.TokenBTableNotification", SubjectAdded, true, true},
+		{"eosio.nft.ft.AuthminterActionNotification", SubjectChanged, true, false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.subject, func(t *testing.T) {
+			s, found := bySubject[tt.subject]
+			if !found {
+				t.Fatalf("subject: %s not found", tt.subject)
+			}
+			if s.Status != tt.wantStatus {
+				t.Errorf("status = %v, want %v", s.Status, tt.wantStatus)
+			}
+			if got := s.Satisfies(srclient.Forward); got != tt.wantForward {
+				t.Errorf("Satisfies(FORWARD) = %v, want %v", got, tt.wantForward)
+			}
+			if got := s.Satisfies(srclient.Backward); got != tt.wantBackward {
+				t.Errorf("Satisfies(BACKWARD) = %v, want %v", got, tt.wantBackward)
+			}
+		})
+	}
+}
+
+func TestCheckSchemasCompatibility_subjectNameStrategy(t *testing.T) {
+	previous, err := LoadABIFile("eosio.nft.ft", "testdata/eosio.nft.ft-1.31.1.abi")
+	if err != nil {
+		t.Fatalf("LoadABIFile() error: %v", err)
+	}
+	next, err := LoadABIFile("eosio.nft.ft", "testdata/eosio.nft.ft-2.0.abi")
+	if err != nil {
+		t.Fatalf("LoadABIFile() error: %v", err)
+	}
+	tests := []struct {
+		strategy    string
+		wantSubject string
+	}{
+		{RecordNameStrategy, "eosio.nft.ft.TokenBTableNotification"},
+		{TopicNameStrategy, "nft-value"},
+		{TopicRecordNameStrategy, "nft-eosio.nft.ft.TokenBTableNotification"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.strategy, func(t *testing.T) {
+			subjectName, err := NewSubjectNameStrategy(tt.strategy, "nft")
+			if err != nil {
+				t.Fatalf("NewSubjectNameStrategy() error: %v", err)
+			}
+			subjects, err := CheckSchemasCompatibility(previous, next, "", subjectName)
+			if err != nil {
+				t.Fatalf("CheckSchemasCompatibility() error: %v", err)
+			}
+			for _, s := range subjects {
+				if s.Kind == "table" && s.Name == "token.b" {
+					if s.Subject != tt.wantSubject {
+						t.Errorf("subject = %v, want %v", s.Subject, tt.wantSubject)
+					}
+					return
+				}
+			}
+			t.Errorf("table: token.b not found")
+		})
+	}
+}
@@ -0,0 +1,263 @@
+package dkafka
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"sort"
+
+	"github.com/confluentinc/confluent-kafka-go/kafka"
+	pbcodec "github.com/dfuse-io/dfuse-eosio/pb/dfuse/eosio/codec/v1"
+	"github.com/eoscanada/eos-go"
+	"go.uber.org/zap"
+)
+
+const abiUpdatedNotification = "AbiUpdatedNotification"
+
+var AbiUpdatedSchema = RecordSchema{
+	Type:      "record",
+	Name:      abiUpdatedNotification,
+	Namespace: dkafkaNamespace,
+	Doc: `Emitted when the ABI of the tracked smart contract is updated by a 'setabi' action.
+On UNDO the same event is emitted with the UNDO step to compensate the reverted update.`,
+	Fields: []FieldSchema{
+		{
+			Name: "account",
+			Type: "string",
+		},
+		{
+			Name: "block_num",
+			Type: "long",
+		},
+		{
+			Name: "block_id",
+			Type: "string",
+		},
+		{
+			Name: "trx_id",
+			Type: "string",
+		},
+		{
+			Name: "block_step",
+			Type: "string",
+		},
+		{
+			Name: "time",
+			Type: map[string]string{
+				"type":        "long",
+				"logicalType": "timestamp-millis",
+			},
+		},
+		{
+			Name: "abi_hash",
+			Type: "string",
+			Doc:  "Hex encoded sha256 of the binary ABI",
+		},
+		{
+			Name: "abi",
+			Type: "string",
+			Doc:  "JSON representation of the ABI",
+		},
+		{
+			Name: "changed_tables",
+			Type: NewArray("string"),
+			Doc:  "Tables whose generated schema changed compared to the previous ABI",
+		},
+		{
+			Name: "changed_actions",
+			Type: NewArray("string"),
+			Doc:  "Actions whose generated schema changed compared to the previous ABI",
+		},
+	},
+}
+
+var AbiUpdatedMessageSchema = MessageSchema{
+	AbiUpdatedSchema,
+	newMeta(dkafkaMetaSupplier{}),
+}
+
+// abiHash returns the hex encoded sha256 of the binary ABI
+func abiHash(hexData string) (string, error) {
+	abiData, err := hex.DecodeString(hexData)
+	if err != nil {
+		return "", err
+	}
+	hash := sha256.Sum256(abiData)
+	return hex.EncodeToString(hash[:]), nil
+}
+
+// changedSchemas returns the tables and actions whose schema generated from
+// next differs from the one generated from previous, including the added and
+// removed ones. The schemas are generated with the options of the codec
+// generator. A nil previous ABI means that everything changed.
+func changedSchemas(previous *ABI, next *ABI, generator MessageSchemaGenerator) (tables []string, actions []string, err error) {
+	tableNames := make(map[string]bool)
+	actionNames := make(map[string]bool)
+	for _, abi := range []*ABI{previous, next} {
+		if abi == nil || abi.ABI == nil {
+			continue
+		}
+		for _, table := range abi.Tables {
+			tableNames[string(table.Name)] = true
+		}
+		for _, action := range abi.Actions {
+			actionNames[string(action.Name)] = true
+		}
+	}
+	for name := range tableNames {
+		changed, err := schemaChanged(previous, next, func(abi *ABI) (RecordSchema, error) {
+			if abi.TableForName(eos.TableName(name)) == nil {
+				return RecordSchema{}, errMissingEntry
+			}
+			schema, err := generator.getTableSchema(name, abi)
+			return schema.RecordSchema, err
+		})
+		if err != nil {
+			return nil, nil, fmt.Errorf("cannot compare table: %s schemas, error: %w", name, err)
+		}
+		if changed {
+			tables = append(tables, name)
+		}
+	}
+	for name := range actionNames {
+		changed, err := schemaChanged(previous, next, func(abi *ABI) (RecordSchema, error) {
+			if abi.ActionForName(eos.ActionName(name)) == nil {
+				return RecordSchema{}, errMissingEntry
+			}
+			schema, err := generator.getActionSchema(name, abi)
+			return schema.RecordSchema, err
+		})
+		if err != nil {
+			return nil, nil, fmt.Errorf("cannot compare action: %s schemas, error: %w", name, err)
+		}
+		if changed {
+			actions = append(actions, name)
+		}
+	}
+	sort.Strings(tables)
+	sort.Strings(actions)
+	return
+}
+
+var errMissingEntry = fmt.Errorf("missing entry")
+
+func schemaChanged(previous *ABI, next *ABI, toRecord func(*ABI) (RecordSchema, error)) (bool, error) {
+	var schemas [2][]byte
+	for i, abi := range []*ABI{previous, next} {
+		if abi == nil || abi.ABI == nil {
+			continue
+		}
+		record, err := toRecord(abi)
+		if err == errMissingEntry {
+			continue
+		}
+		if err != nil {
+			return false, err
+		}
+		if schemas[i], err = json.Marshal(record); err != nil {
+			return false, err
+		}
+	}
+	return string(schemas[0]) != string(schemas[1]), nil
+}
+
+func newAbiUpdatedMap(gc TransactionContext, account string, hash string, abi *eos.ABI, changedTables []string, changedActions []string) (map[string]interface{}, error) {
+	abiJSON, err := json.Marshal(abi)
+	if err != nil {
+		return nil, fmt.Errorf("cannot encode abi to json: %w", err)
+	}
+	if changedTables == nil {
+		changedTables = []string{}
+	}
+	if changedActions == nil {
+		changedActions = []string{}
+	}
+	return map[string]interface{}{
+		"account":         account,
+		"block_num":       gc.block.Number,
+		"block_id":        gc.block.Id,
+		"trx_id":          gc.transaction.Id,
+		"block_step":      gc.stepName,
+		"time":            gc.block.MustTime().UTC(),
+		"abi_hash":        hash,
+		"abi":             string(abiJSON),
+		"changed_tables":  changedTables,
+		"changed_actions": changedActions,
+	}, nil
+}
+
+// abiUpdated updates the ABI codec with the ABI set by the given action and
+// produces the AbiUpdatedNotification message.
+func (t transaction2ActionsGenerator) abiUpdated(gc TransactionContext, act *pbcodec.ActionTrace) (*kafka.Message, error) {
+	blockNum := gc.block.Number
+	account := act.GetData("account").String()
+	hexData := act.GetData("abi").String()
+	var previous *ABI
+	if blockNum > 0 {
+		var err error
+		if previous, err = t.abiCodec.GetABI(account, blockNum-1); err != nil {
+			zlog.Info("no previous abi found consider everything changed", zap.String("account", account), zap.Uint32("block_num", blockNum), zap.Error(err))
+			previous = nil
+		}
+	}
+	if err := t.abiCodec.UpdateABI(blockNum, gc.step, gc.transaction.Id, act); err != nil {
+		return nil, err
+	}
+	abi, err := DecodeABI(gc.transaction.Id, account, hexData)
+	if err != nil {
+		return nil, err
+	}
+	hash, err := abiHash(hexData)
+	if err != nil {
+		return nil, fmt.Errorf("cannot hash abi of account: %s, error: %w", account, err)
+	}
+	changedTables, changedActions, err := changedSchemas(previous, &ABI{ABI: abi, AbiBlockNum: blockNum, Account: account}, t.schemaGenerator)
+	if err != nil {
+		return nil, err
+	}
+	value, err := newAbiUpdatedMap(gc, account, hash, abi, changedTables, changedActions)
+	if err != nil {
+		return nil, err
+	}
+	codec, err := t.abiCodec.GetCodec(AbiUpdatedSchema.AsCodecId(), 0)
+	if err != nil {
+		return nil, fmt.Errorf("fail to get codec for %s: %w", abiUpdatedNotification, err)
+	}
+	bytes, err := codec.Marshal(nil, value)
+	if err != nil {
+		return nil, fmt.Errorf("fail to marshal %s: %w", abiUpdatedNotification, err)
+	}
+	ceId := hashString(fmt.Sprintf(
+		"%s%s%d%s",
+		gc.cursor,
+		gc.transaction.Id,
+		act.ExecutionIndex,
+		gc.stepName,
+	))
+	headers := append(t.headers,
+		kafka.Header{
+			Key:   "ce_id",
+			Value: ceId,
+		},
+		kafka.Header{
+			Key:   "ce_type",
+			Value: []byte(abiUpdatedNotification),
+		},
+		BlockStep{blk: gc.block}.timeHeader(),
+		kafka.Header{
+			Key:   "ce_blkstep",
+			Value: []byte(gc.stepName),
+		},
+	)
+	headers = append(headers, codec.GetHeaders()...)
+	return &kafka.Message{
+		Key:     []byte(account),
+		Headers: headers,
+		Value:   bytes,
+		TopicPartition: kafka.TopicPartition{
+			Topic:     &t.topic,
+			Partition: kafka.PartitionAny,
+		},
+	}, nil
+}
@@ -0,0 +1,252 @@
+package dkafka
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+	"testing"
+
+	pbcodec "github.com/dfuse-io/dfuse-eosio/pb/dfuse/eosio/codec/v1"
+	"github.com/eoscanada/eos-go"
+	"github.com/go-test/deep"
+	"github.com/riferrei/srclient"
+	pbbstream "github.com/streamingfast/pbgo/dfuse/bstream/v1"
+)
+
+func Test_changedSchemas(t *testing.T) {
+	abi, err := LoadABIFile("eosio.nft.ft", "testdata/eosio.nft.ft.abi")
+	if err != nil {
+		t.Fatalf("LoadABIFile() error: %v", err)
+	}
+	withoutFactoryA := *abi.ABI
+	withoutFactoryA.Tables = nil
+	for _, table := range abi.Tables {
+		if table.Name != "factory.a" {
+			withoutFactoryA.Tables = append(withoutFactoryA.Tables, table)
+		}
+	}
+	tests := []struct {
+		name        string
+		previous    *ABI
+		next        *ABI
+		wantTables  []string
+		wantActions []string
+	}{
+		{
+			name:     "same abi",
+			previous: abi,
+			next:     abi,
+		},
+		{
+			name:        "no previous abi",
+			previous:    nil,
+			next:        &ABI{ABI: &withoutFactoryA, Account: "eosio.nft.ft"},
+			wantTables:  tableNames(&withoutFactoryA),
+			wantActions: actionNames(&withoutFactoryA),
+		},
+		{
+			name:       "removed table",
+			previous:   abi,
+			next:       &ABI{ABI: &withoutFactoryA, Account: "eosio.nft.ft"},
+			wantTables: []string{"factory.a"},
+		},
+		{
+			name:       "added table",
+			previous:   &ABI{ABI: &withoutFactoryA, Account: "eosio.nft.ft"},
+			next:       abi,
+			wantTables: []string{"factory.a"},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			tables, actions, err := changedSchemas(tt.previous, tt.next, MessageSchemaGenerator{})
+			if err != nil {
+				t.Fatalf("changedSchemas() error: %v", err)
+			}
+			if diff := deep.Equal(tables, tt.wantTables); diff != nil {
+				t.Errorf("changedSchemas() tables diff: %v", diff)
+			}
+			if diff := deep.Equal(actions, tt.wantActions); diff != nil {
+				t.Errorf("changedSchemas() actions diff: %v", diff)
+			}
+		})
+	}
+}
+
+func Test_changedSchemas_mapping(t *testing.T) {
+	variantABI := func(types ...string) *ABI {
+		return &ABI{
+			ABI: &eos.ABI{
+				Structs: []eos.StructDef{
+					{Name: "row", Fields: []eos.FieldDef{{Name: "value", Type: "value_type"}}},
+				},
+				Variants: []eos.VariantDef{{Name: "value_type", Types: types}},
+				Tables:   []eos.TableDef{{Name: "rows", Type: "row"}},
+			},
+			Account: "test",
+		}
+	}
+	previous := variantABI("uint32", "string")
+	next := variantABI("uint32", "string", "uint8[]")
+	tests := []struct {
+		name       string
+		mapping    SchemaMappingProfile
+		wantTables []string
+	}{
+		{
+			name:       "union variant",
+			wantTables: []string{"rows"},
+		},
+		{
+			// the JSON representation of a variant does not depend on its types
+			name:    "json variant",
+			mapping: SchemaMappingProfile{Variants: VariantMapping{{Match: "*", Strategy: JSONVariant}}},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			generator := MessageSchemaGenerator{Namespace: "test", Account: "test", Mapping: tt.mapping}
+			tables, _, err := changedSchemas(previous, next, generator)
+			if err != nil {
+				t.Fatalf("changedSchemas() error: %v", err)
+			}
+			if diff := deep.Equal(tables, tt.wantTables); diff != nil {
+				t.Errorf("changedSchemas() tables diff: %v", diff)
+			}
+		})
+	}
+}
+
+func tableNames(abi *eos.ABI) (names []string) {
+	for _, table := range abi.Tables {
+		names = append(names, string(table.Name))
+	}
+	sort.Strings(names)
+	return names
+}
+
+func actionNames(abi *eos.ABI) (names []string) {
+	for _, action := range abi.Actions {
+		names = append(names, string(action.Name))
+	}
+	sort.Strings(names)
+	return names
+}
+
+func Test_transaction2ActionsGenerator_abiUpdated(t *testing.T) {
+	hexData := strings.TrimSpace(string(readFileFromTestdata(t, "testdata/abi.hex")))
+	act := &pbcodec.ActionTrace{
+		Receiver: "eosio",
+		Action: &pbcodec.Action{
+			Account:  "eosio",
+			Name:     "setabi",
+			JsonData: fmt.Sprintf(`{"account":"eosio.nft.ft","abi":"%s"}`, hexData),
+		},
+		FilteringMatched: true,
+	}
+	for _, step := range []pbbstream.ForkStep{pbbstream.ForkStep_STEP_NEW, pbbstream.ForkStep_STEP_UNDO} {
+		t.Run(step.String(), func(t *testing.T) {
+			block := newBlock4Test(t)
+			trx := block.UnfilteredTransactionTraces[0]
+			trx.ActionTraces = []*pbcodec.ActionTrace{act}
+			abiCodec := NewStreamedAbiCodec(
+				&AbiRepositoryStub{err: fmt.Errorf("no abi")},
+				nil,
+				srclient.CreateMockSchemaRegistryClient("mock://Test_transaction2ActionsGenerator_abiUpdated"),
+				"eosio.nft.ft",
+				"mock://Test_transaction2ActionsGenerator_abiUpdated",
+				srclient.Forward,
+			)
+			g := transaction2ActionsGenerator{
+				abiCodec: abiCodec,
+				headers:  default_headers,
+				topic:    "dkafka.test",
+				account:  "eosio.nft.ft",
+			}
+			msgs, err := g.Apply(TransactionContext{
+				block:       block,
+				stepName:    step.String(),
+				step:        step,
+				cursor:      "cursor",
+				transaction: trx,
+			})
+			if err != nil {
+				t.Fatalf("Apply() error: %v", err)
+			}
+			if len(msgs) != 1 {
+				t.Fatalf("Apply() expected 1 message got: %d", len(msgs))
+			}
+			msg := msgs[0]
+			if string(msg.Key) != "eosio.nft.ft" {
+				t.Errorf("message key = %s, want eosio.nft.ft", string(msg.Key))
+			}
+			if ceType := findHeader("ce_type", msg.Headers); ceType != abiUpdatedNotification {
+				t.Errorf("ce_type = %s, want %s", ceType, abiUpdatedNotification)
+			}
+			codec, err := abiCodec.GetCodec(AbiUpdatedSchema.AsCodecId(), 0)
+			if err != nil {
+				t.Fatalf("GetCodec() error: %v", err)
+			}
+			value, err := codec.Unmarshal(msg.Value)
+			if err != nil {
+				t.Fatalf("Unmarshal() error: %v", err)
+			}
+			record := value.(map[string]interface{})
+			wantHash, _ := abiHash(hexData)
+			if record["abi_hash"] != wantHash {
+				t.Errorf("abi_hash = %v, want %v", record["abi_hash"], wantHash)
+			}
+			if record["block_step"] != step.String() {
+				t.Errorf("block_step = %v, want %v", record["block_step"], step.String())
+			}
+			if tables := record["changed_tables"].([]interface{}); len(tables) == 0 {
+				t.Errorf("changed_tables should not be empty without previous abi")
+			}
+			_, err = abiCodec.GetABI("eosio.nft.ft", 42)
+			if step == pbbstream.ForkStep_STEP_UNDO && err == nil {
+				t.Errorf("abi version should be removed on undo")
+			}
+			if step == pbbstream.ForkStep_STEP_NEW && err != nil {
+				t.Errorf("abi version should be added on new: %v", err)
+			}
+		})
+	}
+}
+
+func Test_transaction2ActionsGenerator_abiUpdatedError(t *testing.T) {
+	act := &pbcodec.ActionTrace{
+		Receiver: "eosio",
+		Action: &pbcodec.Action{
+			Account:  "eosio",
+			Name:     "setabi",
+			JsonData: `{"account":"eosio.nft.ft","abi":"not-an-abi"}`,
+		},
+		FilteringMatched: true,
+	}
+	block := newBlock4Test(t)
+	trx := block.UnfilteredTransactionTraces[0]
+	trx.ActionTraces = []*pbcodec.ActionTrace{act}
+	g := transaction2ActionsGenerator{
+		abiCodec: NewStreamedAbiCodec(
+			&AbiRepositoryStub{err: fmt.Errorf("no abi")},
+			nil,
+			srclient.CreateMockSchemaRegistryClient("mock://Test_transaction2ActionsGenerator_abiUpdatedError"),
+			"eosio.nft.ft",
+			"mock://Test_transaction2ActionsGenerator_abiUpdatedError",
+			srclient.Forward,
+		),
+		headers: default_headers,
+		topic:   "dkafka.test",
+		account: "eosio.nft.ft",
+	}
+	msgs, err := g.Apply(TransactionContext{
+		block:       block,
+		stepName:    "NEW",
+		step:        pbbstream.ForkStep_STEP_NEW,
+		cursor:      "cursor",
+		transaction: trx,
+	})
+	if err == nil {
+		t.Fatalf("Apply() expected an error on an invalid abi, got %d messages", len(msgs))
+	}
+}
@@ -5,6 +5,7 @@ import (
 
 	"github.com/confluentinc/confluent-kafka-go/kafka"
 	pbcodec "github.com/dfuse-io/dfuse-eosio/pb/dfuse/eosio/codec/v1"
+	"github.com/streamingfast/bstream/forkable"
 	pbbstream "github.com/streamingfast/pbgo/dfuse/bstream/v1"
 	"go.uber.org/zap"
 )
@@ -50,6 +51,21 @@ func (bs BlockStep) blockNum() uint32 {
 	return bs.blk.Number
 }
 
+// libNum returns the last irreversible block number known by the cursor
+func (bs BlockStep) libNum() (uint32, error) {
+	c, err := forkable.CursorFromOpaque(bs.cursor)
+	if err != nil {
+		return 0, err
+	}
+	return uint32(c.LIB.Num()), nil
+}
+
+// irreversibilityTracker is implemented by the ABI codecs keeping a fork aware
+// history of the ABIs.
+type irreversibilityTracker interface {
+	MarkIrreversible(libNum uint32)
+}
+
 type CdCAdapter struct {
 	topic     string
 	saveBlock SaveBlock
@@ -97,6 +113,13 @@ func (m *CdCAdapter) Adapt(blkStep BlockStep) ([]*kafka.Message, error) {
 		}
 		msgs = append(msgs, msgs1...)
 	}
+	if tracker, ok := m.abiCodec.(irreversibilityTracker); ok {
+		if libNum, err := blkStep.libNum(); err == nil {
+			tracker.MarkIrreversible(libNum)
+		} else {
+			zlog.Debug("cannot get LIB from cursor", zap.String("cursor", blkStep.cursor), zap.Error(err))
+		}
+	}
 	zlog.Debug("produced kafka messages", zap.Uint32("block_num", blk.Number), zap.String("step", step), zap.Int("nb_messages", len(msgs)))
 	return msgs, nil
 }
@@ -96,8 +96,8 @@ func findHeader(name string, headers []kafka.Header) string {
 }
 
 func newTableGen4Test(t testing.TB, tableName string) TableGenerator {
-	var localABIFiles = map[string]string{
-		"eosio.nft.ft": "testdata/eosio.nft.ft.abi",
+	var localABIFiles = map[string][]string{
+		"eosio.nft.ft": {"testdata/eosio.nft.ft.abi"},
 	}
 	abiFiles, err := LoadABIFiles(localABIFiles)
 	if err != nil {
@@ -112,6 +112,36 @@ func newTableGen4Test(t testing.TB, tableName string) TableGenerator {
 	}
 }
 
+// newBlock4Test returns the block-42 testdata block of 2022-01-01: the
+// eosio.nft.ft create action of trx-1 inserts the token 104 in the resale.a
+// table and trx-2 is a soft fail
+func newBlock4Test(t testing.TB) *pbcodec.Block {
+	block := &pbcodec.Block{}
+	readFileFromTestdataProto(t, "testdata/block-42.pb.json", block)
+	return block
+}
+
+// newActionContext4Test returns the NEW step context of the create action of
+// trx-1 in the block-42 testdata block. The given DB operations, if any,
+// replace the ones of the transaction.
+func newActionContext4Test(t testing.TB, dbOps ...*pbcodec.DBOp) ActionContext {
+	block := newBlock4Test(t)
+	trx := block.UnfilteredTransactionTraces[0]
+	if len(dbOps) > 0 {
+		trx.DbOps = dbOps
+	}
+	return ActionContext{
+		TransactionContext: TransactionContext{
+			block:       block,
+			stepName:    pbbstream.ForkStep_STEP_NEW.String(),
+			step:        pbbstream.ForkStep_STEP_NEW,
+			cursor:      "cursor",
+			transaction: trx,
+		},
+		actionTrace: trx.ActionTraces[0],
+	}
+}
+
 func tableSchema(t testing.TB, account string, abiFile string, tableName string) string {
 	abiSpec, err := LoadABIFile(account, abiFile)
 	if err != nil {
@@ -139,7 +169,7 @@ func TestCdCAdapter_Adapt_pb(t *testing.T) {
 		name       string
 		file       string
 		account    string
-		abis       map[string]string
+		abis       map[string][]string
 		table      string
 		nbMessages int
 	}{
@@ -147,7 +177,7 @@ func TestCdCAdapter_Adapt_pb(t *testing.T) {
 			name:       "accounts",
 			file:       "testdata/block-49608395.pb.json",
 			account:    "eosio.token",
-			abis:       map[string]string{"eosio.token": "testdata/eosio.token.abi"},
+			abis:       map[string][]string{"eosio.token": {"testdata/eosio.token.abi"}},
 			table:      "accounts",
 			nbMessages: 2,
 		},
@@ -155,7 +185,7 @@ func TestCdCAdapter_Adapt_pb(t *testing.T) {
 			"nft-factory",
 			"testdata/block-50705256.pb.json",
 			"eosio.nft.ft",
-			map[string]string{"eosio.nft.ft": "testdata/eosio.nft.ft.abi"},
+			map[string][]string{"eosio.nft.ft": {"testdata/eosio.nft.ft.abi"}},
 			"factory.a",
 			1,
 		},
@@ -163,7 +193,7 @@ func TestCdCAdapter_Adapt_pb(t *testing.T) {
 			"nft-factory-b",
 			"testdata/block-135283216.pb.json",
 			"eosio.nft.ft",
-			map[string]string{"eosio.nft.ft": "testdata/eosio.nft.ft-4.0.6-snapshot.abi"},
+			map[string][]string{"eosio.nft.ft": {"testdata/eosio.nft.ft-4.0.6-snapshot.abi"}},
 			"factory.b",
 			1,
 		},
@@ -171,7 +201,7 @@ func TestCdCAdapter_Adapt_pb(t *testing.T) {
 			"eosio.oracle",
 			"testdata/block-43922498.pb.json",
 			"eosio.oracle",
-			map[string]string{"eosio.oracle": "testdata/eosio.oracle.abi"},
+			map[string][]string{"eosio.oracle": {"testdata/eosio.oracle.abi"}},
 			"*",
 			4,
 		},
@@ -179,7 +209,7 @@ func TestCdCAdapter_Adapt_pb(t *testing.T) {
 			"eosio.token-chained-table-with-ultra.rgrab",
 			"testdata/block-224785515.pb.json",
 			"eosio.token",
-			map[string]string{"eosio.token": "testdata/eosio.token-2.abi", "ultra.rgrab": "testdata/ultra.rgrab.abi"},
+			map[string][]string{"eosio.token": {"testdata/eosio.token-2.abi"}, "ultra.rgrab": {"testdata/ultra.rgrab.abi"}},
 			"*",
 			2,
 		},
@@ -187,7 +217,7 @@ func TestCdCAdapter_Adapt_pb(t *testing.T) {
 			"ultra.rgrab-chained-table-with-eosio.token",
 			"testdata/block-224785515.pb.json",
 			"ultra.rgrab",
-			map[string]string{"eosio.token": "testdata/eosio.token-2.abi", "ultra.rgrab": "testdata/ultra.rgrab.abi"},
+			map[string][]string{"eosio.token": {"testdata/eosio.token-2.abi"}, "ultra.rgrab": {"testdata/ultra.rgrab.abi"}},
 			"*",
 			1,
 		},
@@ -195,7 +225,7 @@ func TestCdCAdapter_Adapt_pb(t *testing.T) {
 			"eosio.token-chained-table-with-1aa2aa3aa4bx",
 			"testdata/block-105048059.pb.json",
 			"eosio.token",
-			map[string]string{"eosio.token": "testdata/eosio.token-2.abi", "1aa2aa3aa4bx": "testdata/1aa2aa3aa4bx.abi"},
+			map[string][]string{"eosio.token": {"testdata/eosio.token-2.abi"}, "1aa2aa3aa4bx": {"testdata/1aa2aa3aa4bx.abi"}},
 			"*",
 			2,
 		},
@@ -292,76 +322,80 @@ func TestCdCAdapter_Action_pb(t *testing.T) {
 	}
 
 	for _, tt := range tests {
-		t.Run(tt.name, func(t *testing.T) {
-			block := &pbcodec.Block{}
-			err := jsonpb.UnmarshalString(string(readFileFromTestdata(t, tt.file)), block)
-			if err != nil {
-				t.Fatalf("jsonpb.UnmarshalString(): %v", err)
-			}
+		for _, typedActionData := range []bool{false, true} {
+			t.Run(fmt.Sprintf("%s-typed-%v", tt.name, typedActionData), func(t *testing.T) {
+				block := &pbcodec.Block{}
+				err := jsonpb.UnmarshalString(string(readFileFromTestdata(t, tt.file)), block)
+				if err != nil {
+					t.Fatalf("jsonpb.UnmarshalString(): %v", err)
+				}
 
-			var localABIFiles = map[string]string{}
-			var abiAccount string
+				var localABIFiles = map[string][]string{}
+				var abiAccount string
 
-			if account, abiPath, err := ParseABIFileSpec(tt.abi); err != nil {
-				t.Fatalf("ParseABIFileSpec() fail to get ABI from: '%s'; %v", tt.abi, err)
-			} else {
-				abiAccount = account
-				localABIFiles[account] = abiPath
-			}
+				if account, abiPath, err := ParseABIFileSpec(tt.abi); err != nil {
+					t.Fatalf("ParseABIFileSpec() fail to get ABI from: '%s'; %v", tt.abi, err)
+				} else {
+					abiAccount = account
+					localABIFiles[account] = []string{abiPath}
+				}
 
-			abiFiles, err := LoadABIFiles(localABIFiles)
-			if err != nil {
-				t.Fatalf("LoadABIFiles() error: %v", err)
-			}
-			abiDecoder := NewABIDecoder(abiFiles, nil, context.Background())
-			msg := MessageSchemaGenerator{
-				Namespace: "test.dkafka",
-				Version:   "1.2.3",
-				Account:   abiAccount,
-			}
-			// abi, _ := abiDecoder.abi(abiAccount, 0, false)
-			// schema, _ := msg.getTableSchema("accounts", abi)
-			// jsonSchema, err := json.Marshal(schema)
-			// fmt.Println(string(jsonSchema))
-			actionKeyExpressions, err := createCdcKeyExpressions(tt.actionExpression)
-			if err != nil {
-				t.Fatalf("createCdcKeyExpressions() error: %v", err)
-			}
-			g := ActionGenerator2{
-				keyExtractors: actionKeyExpressions,
-				abiCodec: NewStreamedAbiCodec(&DfuseAbiRepository{
-					overrides:   abiDecoder.overrides,
-					abiCodecCli: abiDecoder.abiCodecCli,
-					context:     abiDecoder.context,
-				}, msg.getActionSchema, srclient.CreateMockSchemaRegistryClient("mock://bench-adapter"), abiAccount, "mock://bench-adapter", srclient.Forward),
-			}
-			a := &CdCAdapter{
-				topic:     "test.topic",
-				saveBlock: saveBlockNoop,
-				generator: transaction2ActionsGenerator{
-					actionLevelGenerator: g,
-					topic:                "test.topic",
-					headers:              default_headers,
-				},
-				headers: default_headers,
-			}
-			blockStep := BlockStep{
-				blk:    block,
-				step:   pbbstream.ForkStep_STEP_NEW,
-				cursor: "123",
-			}
-			messages, err := a.Adapt(blockStep)
-			if err != nil {
-				t.Fatalf("Adapt() error: %v", err)
-			}
-			assert.Equal(t, len(messages), tt.nbMessages)
-			fmt.Printf("messages size: %v\n", len(messages[0].Value))
-			for _, m := range messages {
-				assert.Equal(t, findHeader("content-type", m.Headers), "application/avro")
-				assert.Equal(t, findHeader("ce_datacontenttype", m.Headers), "application/avro")
-				assert.Assert(t, findHeader("ce_dataschema", m.Headers) != "")
-			}
-		})
+				abiFiles, err := LoadABIFiles(localABIFiles)
+				if err != nil {
+					t.Fatalf("LoadABIFiles() error: %v", err)
+				}
+				abiDecoder := NewABIDecoder(abiFiles, nil, context.Background())
+				msg := MessageSchemaGenerator{
+					Namespace:       "test.dkafka",
+					Version:         "1.2.3",
+					Account:         abiAccount,
+					TypedActionData: typedActionData,
+				}
+				// abi, _ := abiDecoder.abi(abiAccount, 0, false)
+				// schema, _ := msg.getTableSchema("accounts", abi)
+				// jsonSchema, err := json.Marshal(schema)
+				// fmt.Println(string(jsonSchema))
+				actionKeyExpressions, err := createCdcKeyExpressions(tt.actionExpression)
+				if err != nil {
+					t.Fatalf("createCdcKeyExpressions() error: %v", err)
+				}
+				g := ActionGenerator2{
+					keyExtractors: actionKeyExpressions,
+					abiCodec: NewStreamedAbiCodec(&DfuseAbiRepository{
+						overrides:   abiDecoder.overrides,
+						abiCodecCli: abiDecoder.abiCodecCli,
+						context:     abiDecoder.context,
+					}, msg.getActionSchema, srclient.CreateMockSchemaRegistryClient("mock://bench-adapter"), abiAccount, "mock://bench-adapter", srclient.Forward),
+					decodeRawData: typedActionData,
+				}
+				a := &CdCAdapter{
+					topic:     "test.topic",
+					saveBlock: saveBlockNoop,
+					generator: transaction2ActionsGenerator{
+						actionLevelGenerator: g,
+						topic:                "test.topic",
+						headers:              default_headers,
+					},
+					headers: default_headers,
+				}
+				blockStep := BlockStep{
+					blk:    block,
+					step:   pbbstream.ForkStep_STEP_NEW,
+					cursor: "123",
+				}
+				messages, err := a.Adapt(blockStep)
+				if err != nil {
+					t.Fatalf("Adapt() error: %v", err)
+				}
+				assert.Equal(t, len(messages), tt.nbMessages)
+				fmt.Printf("messages size: %v\n", len(messages[0].Value))
+				for _, m := range messages {
+					assert.Equal(t, findHeader("content-type", m.Headers), "application/avro")
+					assert.Equal(t, findHeader("ce_datacontenttype", m.Headers), "application/avro")
+					assert.Assert(t, findHeader("ce_dataschema", m.Headers) != "")
+				}
+			})
+		}
 	}
 }
 
@@ -84,8 +84,8 @@ func Test_adapter_adapt(t *testing.T) {
 			if err != nil {
 				t.Fatalf("Unmarshal() error: %v", err)
 			}
-			var localABIFiles = map[string]string{
-				"eosio.nft.ft": "testdata/eosio.nft.ft.abi",
+			var localABIFiles = map[string][]string{
+				"eosio.nft.ft": {"testdata/eosio.nft.ft.abi"},
 			}
 			abiFiles, err := LoadABIFiles(localABIFiles)
 			if err != nil {
@@ -232,8 +232,8 @@ func Benchmark_adapter_adapt(b *testing.B) {
 		if err != nil {
 			b.Fatalf("Unmarshal() error: %v", err)
 		}
-		var localABIFiles = map[string]string{
-			"eosio.nft.ft": "testdata/eosio.nft.ft.abi",
+		var localABIFiles = map[string][]string{
+			"eosio.nft.ft": {"testdata/eosio.nft.ft.abi"},
 		}
 		abiFiles, err := LoadABIFiles(localABIFiles)
 		if err != nil {
@@ -397,9 +397,9 @@ func readFileFromTestdataProto(t testing.TB, file string, m proto.Message) {
 func Test_adapter_correlation_id(t *testing.T) {
 	block := &pbcodec.Block{}
 	readFileFromTestdataProto(t, "testdata/block-49608395.pb.json", block)
-	var localABIFiles = map[string]string{
-		"eosio.nft.ft": "testdata/eosio.nft.ft.abi",
-		"eosio.token":  "testdata/eosio.token.abi",
+	var localABIFiles = map[string][]string{
+		"eosio.nft.ft": {"testdata/eosio.nft.ft.abi"},
+		"eosio.token":  {"testdata/eosio.token.abi"},
 	}
 	abiFiles, err := LoadABIFiles(localABIFiles)
 	if err != nil {
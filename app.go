@@ -18,6 +18,7 @@ import (
 	"github.com/eoscanada/eos-go"
 	"github.com/golang/protobuf/ptypes"
 	"github.com/google/cel-go/cel"
+	"github.com/google/cel-go/checker/decls"
 	"github.com/riferrei/srclient"
 	"github.com/streamingfast/bstream/forkable"
 	"github.com/streamingfast/dgrpc"
@@ -35,10 +36,11 @@ import (
 const TABLES_CDC_TYPE = "tables"
 const ACTIONS_CDC_TYPE = "actions"
 const TRANSACTION_CDC_TYPE = "transactions"
+const RAW_CDC_TYPE = "raw"
 
 type Config struct {
 	DfuseGRPCEndpoint string
-	DfuseToken        string
+	DfuseToken        string `json:"-"`
 
 	DryRun        bool // do not connect to Kafka, just print to stdout
 	BatchMode     bool
@@ -66,14 +68,19 @@ type Config struct {
 	KafkaCursorTopic     string
 	KafkaCursorPartition int32
 	EventSource          string
+	CloudEventsMode      string
 
 	IncludeFilterExpr string
 	EventKeysExpr     string
 	EventTypeExpr     string
 	ActionsExpr       string
 
-	LocalABIFiles         map[string]string
+	LocalABIFiles         map[string][]string
 	ABICodecGRPCAddr      string
+	AbiSource             string
+	AbiSourceTimeout      time.Duration
+	AbiSourceRetries      int
+	AbiCacheDir           string
 	FailOnUndecodableDBOP bool
 
 	CdCType           string
@@ -84,13 +91,36 @@ type Config struct {
 	Executed          bool
 	Irreversible      bool
 	SkipDbOps         bool
+	// DecodeActionRawData decodes the action parameters from the raw data
+	// with the ABI instead of using the firehose JSON representation
+	DecodeActionRawData bool
+	// UndecodableDBOpPolicy is the CDC policy on tables for the DB operations
+	// that cannot be decoded: fail, skip or raw
+	UndecodableDBOpPolicy string
+	// TableExpressions are the CDC CEL filter and key expressions of the
+	// tables: {<table-name>|*}={"filter":"<expr>","key":"<expr>"}
+	TableExpressions []string
+	// RawGranularity is the CDC raw message granularity: transaction or block
+	RawGranularity string
+	// SchemaMapping selects the lossless avro representations of some
+	// built-in types
+	SchemaMapping SchemaMappingProfile
 
 	Codec              string
 	SchemaRegistryURL  string
+	SchemaRegistryAuth SchemaRegistryAuth
 	SchemaNamespace    string
 	SchemaMajorVersion uint
 	SchemaVersion      string
 	Compatibility      string
+	// SchemaReferences registers the shared records under their own subject
+	// and references them from the generated schemas instead of inlining them
+	SchemaReferences bool
+	// SubjectNameStrategy is one of record (default), topic or topic-record
+	SubjectNameStrategy string
+	// SchemaRegistryReadOnly uses the pre-registered schemas matching the
+	// generated ones instead of registering them
+	SchemaRegistryReadOnly bool
 }
 
 var compatibilityMap = map[string]srclient.CompatibilityLevel{
@@ -129,6 +159,9 @@ func New(config *Config) *App {
 
 func (a *App) Run() (err error) {
 	go startPrometheusMetrics("/metrics", ":9102")
+	if err := ValidateCloudEventsMode(a.config.CloudEventsMode); err != nil {
+		return err
+	}
 	// get and setup the dfuse fetcher that gets a stream of blocks, includes the filter, will include the auth token resolver/refresher
 	addr := a.config.DfuseGRPCEndpoint
 	plaintext := strings.Contains(addr, "*")
@@ -151,7 +184,7 @@ func (a *App) Run() (err error) {
 		saveBlock = saveBlockProto
 	}
 
-	var abiFiles map[string]*ABI
+	var abiFiles map[string][]*ABI
 	if len(a.config.LocalABIFiles) != 0 {
 		abiFiles, err = LoadABIFiles(a.config.LocalABIFiles)
 		if err != nil {
@@ -159,9 +192,24 @@ func (a *App) Run() (err error) {
 		}
 	}
 
+	abiCodecGRPCAddr := a.config.ABICodecGRPCAddr
+	var nodeosURL string
+	if a.config.AbiSource != "" {
+		kind, address, err := ParseAbiSource(a.config.AbiSource)
+		if err != nil {
+			return err
+		}
+		switch kind {
+		case AbicodecAbiSource:
+			abiCodecGRPCAddr = address
+		case NodeosAbiSource:
+			nodeosURL = address
+		}
+	}
+
 	var abiCodecClient pbabicodec.DecoderClient
-	if a.config.ABICodecGRPCAddr != "" {
-		abiCodecConn, err := dgrpc.NewInternalClient(a.config.ABICodecGRPCAddr)
+	if abiCodecGRPCAddr != "" && nodeosURL == "" {
+		abiCodecConn, err := dgrpc.NewInternalClient(abiCodecGRPCAddr)
 		if err != nil {
 			return fmt.Errorf("setting up abicodec client: %w", err)
 		}
@@ -257,6 +305,16 @@ func (a *App) Run() (err error) {
 
 	zlog.Info("setting up ABIDecoder")
 	abiDecoder := NewABIDecoder(abiFiles, abiCodecClient, ctx)
+	if nodeosURL != "" {
+		zlog.Info("use nodeos abi source", zap.String("url", nodeosURL))
+		abiDecoder.remote = NewNodeosAbiRepository(nodeosURL, abiFiles, a.config.AbiSourceTimeout, a.config.AbiSourceRetries)
+	}
+	if a.config.AbiCacheDir != "" {
+		zlog.Info("use abi cache", zap.String("dir", a.config.AbiCacheDir))
+		if abiDecoder.abiCache, err = NewAbiCache(a.config.AbiCacheDir); err != nil {
+			return err
+		}
+	}
 
 	if abiDecoder.IsNOOP() && a.config.FailOnUndecodableDBOP {
 		return fmt.Errorf("invalid config: no abicodec GRPC address and no local ABI file has been set, but fail-on-undecodable-db-op is enabled")
@@ -273,7 +331,7 @@ func (a *App) Run() (err error) {
 	}
 
 	if a.config.DryRun {
-		appCtx.sender = &DryRunSender{}
+		appCtx.sender = &DryRunSender{cloudEventsMode: a.config.CloudEventsMode}
 	}
 	req := NewRequest(appCtx.filter, a.config.StartBlockNum, a.config.StopBlockNum, appCtx.cursor, a.config.Irreversible)
 
@@ -319,11 +377,17 @@ func (a *App) NewCDCCtx(ctx context.Context, producer *kafka.Producer, headers [
 
 	switch cdcType := a.config.CdCType; cdcType {
 	case TABLES_CDC_TYPE:
+		if policy := a.config.UndecodableDBOpPolicy; policy != "" {
+			if err = ValidateUndecodableDBOpPolicy(policy); err != nil {
+				return appCtx, err
+			}
+		}
 		msg := MessageSchemaGenerator{
 			Namespace:    a.config.SchemaNamespace,
 			MajorVersion: a.config.SchemaMajorVersion,
 			Version:      a.config.SchemaVersion,
 			Account:      a.config.Account,
+			Mapping:      a.config.SchemaMapping,
 		}
 		abiCodec, err = a.config.newABICodec(
 			abiDecoder,
@@ -338,16 +402,23 @@ func (a *App) NewCDCCtx(ctx context.Context, producer *kafka.Producer, headers [
 		if finder, err = buildTableKeyExtractorFinder(a.config.TableNames); err != nil {
 			return appCtx, err
 		}
+		var expressionFinder TableExpressionFinder
+		if expressionFinder, err = buildTableExpressionFinder(a.config.TableExpressions); err != nil {
+			return appCtx, err
+		}
 		generator = transaction2ActionsGenerator{
 			actionLevelGenerator: TableGenerator{
-				getExtractKey:   finder,
-				abiCodec:        abiCodec,
-				targetedAccount: a.config.Account,
+				getExtractKey:     finder,
+				abiCodec:          abiCodec,
+				targetedAccount:   a.config.Account,
+				undecodablePolicy: a.config.UndecodableDBOpPolicy,
+				getExpression:     expressionFinder,
 			},
-			abiCodec: abiCodec,
-			headers:  headers,
-			topic:    a.config.KafkaTopic,
-			account:  a.config.Account,
+			abiCodec:        abiCodec,
+			headers:         headers,
+			topic:           a.config.KafkaTopic,
+			account:         a.config.Account,
+			schemaGenerator: msg,
 		}
 	case ACTIONS_CDC_TYPE:
 		filter = addAccountABIFilter(action.Filter(a.config.Account), a.config.Account)
@@ -356,10 +427,12 @@ func (a *App) NewCDCCtx(ctx context.Context, producer *kafka.Producer, headers [
 			return appCtx, err
 		}
 		msg := MessageSchemaGenerator{
-			Namespace:    a.config.SchemaNamespace,
-			MajorVersion: a.config.SchemaMajorVersion,
-			Version:      a.config.SchemaVersion,
-			Account:      a.config.Account,
+			Namespace:       a.config.SchemaNamespace,
+			MajorVersion:    a.config.SchemaMajorVersion,
+			Version:         a.config.SchemaVersion,
+			Account:         a.config.Account,
+			TypedActionData: a.config.DecodeActionRawData,
+			Mapping:         a.config.SchemaMapping,
 		}
 		abiCodec, err = a.config.newABICodec(
 			abiDecoder,
@@ -374,11 +447,13 @@ func (a *App) NewCDCCtx(ctx context.Context, producer *kafka.Producer, headers [
 				keyExtractors: actionKeyExpressions,
 				abiCodec:      abiCodec,
 				skipDbOps:     a.config.SkipDbOps,
+				decodeRawData: a.config.DecodeActionRawData,
 			},
-			abiCodec: abiCodec,
-			headers:  headers,
-			topic:    a.config.KafkaTopic,
-			account:  a.config.Account,
+			abiCodec:        abiCodec,
+			headers:         headers,
+			topic:           a.config.KafkaTopic,
+			account:         a.config.Account,
+			schemaGenerator: msg,
 		}
 
 	case TRANSACTION_CDC_TYPE:
@@ -388,6 +463,7 @@ func (a *App) NewCDCCtx(ctx context.Context, producer *kafka.Producer, headers [
 			MajorVersion: a.config.SchemaMajorVersion,
 			Version:      a.config.SchemaVersion,
 			Account:      a.config.Account,
+			Mapping:      a.config.SchemaMapping,
 		}
 		abiCodec, err = a.config.newABICodec(
 			abiDecoder,
@@ -402,20 +478,57 @@ func (a *App) NewCDCCtx(ctx context.Context, producer *kafka.Producer, headers [
 			headers:  headers,
 			abiCodec: abiCodec,
 		}
+	case RAW_CDC_TYPE:
+		if err = ValidateRawGranularity(a.config.RawGranularity); err != nil {
+			return appCtx, err
+		}
+		if a.config.Codec != ProtobufCodec {
+			return appCtx, fmt.Errorf("the %s cdc publishes protobuf messages, it requires the %s codec, got: '%s'", RAW_CDC_TYPE, ProtobufCodec, a.config.Codec)
+		}
+		filter = a.config.IncludeFilterExpr
+		msg := MessageSchemaGenerator{
+			Namespace:    a.config.SchemaNamespace,
+			MajorVersion: a.config.SchemaMajorVersion,
+			Version:      a.config.SchemaVersion,
+			Account:      a.config.Account,
+			Mapping:      a.config.SchemaMapping,
+		}
+		perBlock := a.config.RawGranularity == BlockRawGranularity
+		rawMessage := rawTransactionTraceMessage
+		if perBlock {
+			rawMessage = rawBlockMessage
+		}
+		abiCodec, err = a.config.newABICodec(
+			abiDecoder,
+			msg.getNoopSchema,
+			newStreamedAbiCodecWithRawMessages(rawMessage),
+		)
+		if err != nil {
+			return appCtx, err
+		}
+		adapter = &RawAdapter{
+			topic:     a.config.KafkaTopic,
+			saveBlock: saveBlock,
+			headers:   headers,
+			abiCodec:  abiCodec,
+			perBlock:  perBlock,
+		}
 	default:
 		return appCtx, fmt.Errorf("unsupported CDC type %s", cdcType)
 	}
-	adapter = &CdCAdapter{
-		topic:     a.config.KafkaTopic,
-		saveBlock: saveBlock,
-		headers:   headers,
-		generator: generator,
-		abiCodec:  abiCodec,
+	if adapter == nil {
+		adapter = &CdCAdapter{
+			topic:     a.config.KafkaTopic,
+			saveBlock: saveBlock,
+			headers:   headers,
+			generator: generator,
+			abiCodec:  abiCodec,
+		}
 	}
 	appCtx.adapter = adapter
 	appCtx.cursor = cursor
 	appCtx.filter = addExecutedFilter(filter, a.config.Executed)
-	appCtx.sender = NewFastSender(ctx, producer, a.config.KafkaTopic, headers, abiCodec)
+	appCtx.sender = NewFastSender(ctx, producer, a.config.KafkaTopic, headers, abiCodec, a.config.CloudEventsMode)
 	return appCtx, nil
 }
 
@@ -469,6 +582,61 @@ func buildTableKeyExtractorFinder(tableNamesConfig []string) (finder TableKeyExt
 	return
 }
 
+// buildTableExpressionFinder compiles and typechecks the table expressions
+// {<table-name>|*}={"filter":"<expr>","key":"<expr>"}, the filter must return
+// a bool and the key a string. The wildcard expression applies to the tables
+// without their own expression.
+func buildTableExpressionFinder(tableExprConfig []string) (finder TableExpressionFinder, err error) {
+	expressions := make(map[string]TableExpression)
+	for _, tableExpr := range tableExprConfig {
+		kv := strings.SplitN(tableExpr, "=", 2)
+		if len(kv) != 2 || kv[0] == "" {
+			err = fmt.Errorf("unsupported table expression: %s, on support {<name>|*}={\"filter\":\"<expr>\",\"key\":\"<expr>\"}", tableExpr)
+			return
+		}
+		var config struct {
+			Filter string `json:"filter"`
+			Key    string `json:"key"`
+		}
+		decoder := json.NewDecoder(strings.NewReader(kv[1]))
+		decoder.DisallowUnknownFields()
+		if err = decoder.Decode(&config); err != nil {
+			err = fmt.Errorf("invalid table expression of: %s, error: %w", kv[0], err)
+			return
+		}
+		var expression TableExpression
+		if config.Filter != "" {
+			if expression.Filter, err = exprToTypedCelProgram(config.Filter, TableDeclarations, decls.Bool); err != nil {
+				err = fmt.Errorf("invalid filter expression of table: %s, error: %w", kv[0], err)
+				return
+			}
+		}
+		if config.Key != "" {
+			if expression.Key, err = exprToTypedCelProgram(config.Key, TableDeclarations, decls.String); err != nil {
+				err = fmt.Errorf("invalid key expression of table: %s, error: %w", kv[0], err)
+				return
+			}
+		}
+		expressions[kv[0]] = expression
+	}
+	if wildcardExpression, wildcardFound := expressions["*"]; wildcardFound {
+		finder = func(tableName string) (expression TableExpression, found bool) {
+			expression, found = expressions[tableName]
+			if !found {
+				expression = wildcardExpression
+				found = true
+			}
+			return
+		}
+	} else {
+		finder = func(tableName string) (expression TableExpression, found bool) {
+			expression, found = expressions[tableName]
+			return
+		}
+	}
+	return
+}
+
 func createCdcKeyExpressions(cdcExpression string) (finder ActionKeyExtractorFinder, err error) {
 	var cdcProgramByKeys map[string]cel.Program
 	cdcExpressionMap := make(map[string]string)
@@ -620,7 +788,7 @@ func (a *App) NewLegacyCtx(ctx context.Context, producer *kafka.Producer, header
 		},
 		NewStreamedAbiCodec,
 	)
-	sender = NewFastSender(ctx, producer, a.config.KafkaTopic, headers, abiCodec)
+	sender = NewFastSender(ctx, producer, a.config.KafkaTopic, headers, abiCodec, a.config.CloudEventsMode)
 	if err != nil {
 		return appCtx, err
 	}
@@ -815,48 +983,127 @@ func (c *Config) newABICodec(abiDecoder *ABIDecoder, getSchema MessageSchemaSupp
 	switch c.Codec {
 	case JsonCodec:
 		return NewJsonABICodec(abiDecoder, c.Account), nil
-	case AvroCodec:
-		schemaRegistryClient := srclient.CreateSchemaRegistryClient(c.SchemaRegistryURL)
+	case AvroCodec, AvroJsonCodec, ProtobufCodec, JsonSchemaCodec:
+		schemaRegistryClient, schemaRegistryURL, err := c.schemaRegistryClient()
+		if err != nil {
+			return nil, fmt.Errorf("creating schema registry client: %w", err)
+		}
 		compatibility, err := c.getCompatibility()
 		if err != nil {
 			return nil, fmt.Errorf("getting compatibility level: %w", err)
 		}
-		return construct(&DfuseAbiRepository{
+		var abiRepository AbiRepository = &DfuseAbiRepository{
 			overrides:   abiDecoder.overrides,
 			abiCodecCli: abiDecoder.abiCodecCli,
 			context:     abiDecoder.context,
-		}, getSchema, schemaRegistryClient, c.Account, c.SchemaRegistryURL, compatibility), nil
+		}
+		if abiDecoder.remote != nil {
+			abiRepository = abiDecoder.remote
+		}
+		if abiDecoder.abiCache != nil {
+			abiRepository = NewCachedAbiRepository(abiDecoder.abiCache, abiRepository)
+		}
+		options, err := c.streamedAbiCodecOptions(compatibility)
+		if err != nil {
+			return nil, err
+		}
+		return construct(abiRepository, getSchema, schemaRegistryClient, c.Account, schemaRegistryURL, compatibility, options...), nil
 	default:
 		return nil, fmt.Errorf("unsupported codec type: '%s'", c.Codec)
 	}
 }
 
+// dryRunSchemaRegistryURL is the URL of the in-memory schema registry of a dry
+// run, the ce_dataschema headers point to it
+const dryRunSchemaRegistryURL = "mock://dry-run"
+
+// schemaRegistryClient returns the schema registry client and its URL. A dry
+// run registers the schemas in memory and never writes in the schema registry,
+// unless it is read-only: the schemas are then looked up in the schema
+// registry.
+func (c *Config) schemaRegistryClient() (srclient.ISchemaRegistryClient, string, error) {
+	if c.DryRun && !c.SchemaRegistryReadOnly {
+		zlog.Info("dry run, register the schemas in memory", zap.String("schema_registry_url", dryRunSchemaRegistryURL))
+		return srclient.CreateMockSchemaRegistryClient(dryRunSchemaRegistryURL), dryRunSchemaRegistryURL, nil
+	}
+	client, err := newSchemaRegistryClient(c.SchemaRegistryURL, c.SchemaRegistryAuth)
+	if err != nil {
+		return nil, "", err
+	}
+	return client, c.SchemaRegistryURL, nil
+}
+
+// streamedAbiCodecOptions returns the subject naming and the schema registry
+// options of the StreamedAbiCodec
+func (c *Config) streamedAbiCodecOptions(compatibility srclient.CompatibilityLevel) ([]StreamedAbiCodecOption, error) {
+	if err := validateSubjectNameStrategy(c.SubjectNameStrategy, compatibility); err != nil {
+		return nil, err
+	}
+	subjectName, err := NewSubjectNameStrategy(c.SubjectNameStrategy, c.KafkaTopic)
+	if err != nil {
+		return nil, err
+	}
+	options := []StreamedAbiCodecOption{WithSubjectNameStrategy(subjectName)}
+	if c.SchemaReferences {
+		options = append(options, WithSchemaReferences())
+	}
+	if c.SchemaRegistryReadOnly {
+		options = append(options, WithReadOnlySchemas())
+	}
+	if c.CdCType == TABLES_CDC_TYPE && c.UndecodableDBOpPolicy == RawUndecodableDBOp {
+		options = append(options, WithUndecodedTableNotifications())
+	}
+	var codecOption StreamedAbiCodecOption
+	switch c.Codec {
+	case AvroJsonCodec:
+		// same registered schemas as the avro codec
+		options = append(options, WithAvroJsonCodec())
+	case ProtobufCodec:
+		codecOption = WithProtobufCodec()
+	case JsonSchemaCodec:
+		codecOption = WithJsonSchemaCodec()
+	}
+	if codecOption != nil {
+		if c.SchemaReferences || c.SchemaRegistryReadOnly {
+			return nil, fmt.Errorf("the %s codec supports neither the schema references nor the read-only schema registry", c.Codec)
+		}
+		options = append(options, codecOption)
+	}
+	return options, nil
+}
+
 type MessageSchemaGenerator struct {
-	Namespace    string
-	MajorVersion uint
-	Version      string
-	Account      string
+	Namespace       string
+	MajorVersion    uint
+	Version         string
+	Account         string
+	TypedActionData bool
+	Mapping         SchemaMappingProfile
 }
 
 func (msg MessageSchemaGenerator) getTableSchema(tableName string, abi *ABI) (MessageSchema, error) {
-	return GenerateTableSchema(msg.newNamedSchemaGenOptions(TABLES_CDC_TYPE, tableName, abi))
+	return GenerateTableSchema(msg.SchemaGenOptions(TABLES_CDC_TYPE, tableName, abi))
 }
 
 func (msg MessageSchemaGenerator) getActionSchema(actionName string, abi *ABI) (MessageSchema, error) {
-	return GenerateActionSchema(msg.newNamedSchemaGenOptions(ACTIONS_CDC_TYPE, actionName, abi))
+	return GenerateActionSchema(msg.SchemaGenOptions(ACTIONS_CDC_TYPE, actionName, abi))
 }
 
 func (msg MessageSchemaGenerator) getNoopSchema(name string, _ *ABI) (MessageSchema, error) {
 	return MessageSchema{}, fmt.Errorf("noop schema generator cannot produce schema for: %s", name)
 }
 
-func (msg MessageSchemaGenerator) newNamedSchemaGenOptions(kind string, name string, abi *ABI) NamedSchemaGenOptions {
+// SchemaGenOptions returns the generation options of the named table or action
+// with the namespace and the version used at runtime
+func (msg MessageSchemaGenerator) SchemaGenOptions(kind string, name string, abi *ABI) NamedSchemaGenOptions {
 	return NamedSchemaGenOptions{
-		Name:      name,
-		Namespace: msg.namespace(kind, abi.Account),
-		Version:   schemaVersion(msg.Version, msg.MajorVersion, abi.AbiBlockNum),
-		AbiSpec:   abi,
-		Domain:    abi.Account,
+		Name:            name,
+		Namespace:       msg.namespace(kind, abi.Account),
+		Version:         schemaVersion(msg.Version, msg.MajorVersion, abi.AbiBlockNum),
+		AbiSpec:         abi,
+		Domain:          abi.Account,
+		TypedActionData: msg.TypedActionData,
+		Mapping:         msg.Mapping,
 	}
 }
 
@@ -1,7 +1,12 @@
 package dkafka
 
 import (
+	"context"
+	"net/http"
+	"net/http/httptest"
 	"reflect"
+	"strings"
+	"sync"
 	"testing"
 
 	pbcodec "github.com/dfuse-io/dfuse-eosio/pb/dfuse/eosio/codec/v1"
@@ -164,6 +169,70 @@ func Test_buildTableKeyExtractorFinder(t *testing.T) {
 	}
 }
 
+func Test_buildTableExpressionFinder(t *testing.T) {
+	tests := []struct {
+		name       string
+		args       []string
+		wantTables []string
+		wantErr    bool
+	}{
+		{
+			name:    "missing expression",
+			args:    []string{"factory.a"},
+			wantErr: true,
+		},
+		{
+			name:    "invalid json",
+			args:    []string{"factory.a={filter}"},
+			wantErr: true,
+		},
+		{
+			name:    "unknown field",
+			args:    []string{`factory.a={"include":"true"}`},
+			wantErr: true,
+		},
+		{
+			name:    "filter not a bool",
+			args:    []string{`factory.a={"filter":"db_op.scope + 'a'"}`},
+			wantErr: true,
+		},
+		{
+			name:    "key not a string",
+			args:    []string{`factory.a={"key":"block_num"}`},
+			wantErr: true,
+		},
+		{
+			name:    "undeclared variable",
+			args:    []string{`factory.a={"key":"row.id"}`},
+			wantErr: true,
+		},
+		{
+			name:       "filter and key",
+			args:       []string{`factory.a={"filter":"db_op.operation != 3","key":"string(table.new.id)"}`},
+			wantTables: []string{"factory.a"},
+		},
+		{
+			name:       "wildcard",
+			args:       []string{`*={"key":"db_op.scope"}`, `factory.a={"filter":"step == 'NEW'"}`},
+			wantTables: []string{"factory.a", "factory.b"},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			gotFinder, err := buildTableExpressionFinder(tt.args)
+			if (err != nil) != tt.wantErr {
+				t.Errorf("buildTableExpressionFinder() error = %v, wantErr %v", err, tt.wantErr)
+				return
+			}
+			for _, table := range tt.wantTables {
+				if _, found := gotFinder(table); !found {
+					t.Errorf("TableExpressionFinder() not found for table= %s", table)
+				}
+			}
+		})
+	}
+}
+
 func Test_createCdcKeyExpressions(t *testing.T) {
 	tests := []struct {
 		name          string
@@ -579,3 +648,46 @@ func TestMessageSchemaGenerator_namespace(t *testing.T) {
 		})
 	}
 }
+
+func TestConfig_newABICodec_dryRun(t *testing.T) {
+	abiFiles, err := LoadABIFiles(map[string][]string{
+		"eosio.nft.ft": {"testdata/eosio.nft.ft.abi"},
+	})
+	if err != nil {
+		t.Fatalf("LoadABIFiles() error: %v", err)
+	}
+	var mu sync.Mutex
+	var requests []string
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		mu.Lock()
+		requests = append(requests, r.Method+" "+r.URL.Path)
+		mu.Unlock()
+		http.NotFound(w, r)
+	}))
+	defer server.Close()
+	c := &Config{
+		DryRun:            true,
+		Codec:             AvroCodec,
+		CdCType:           TABLES_CDC_TYPE,
+		Account:           "eosio.nft.ft",
+		SchemaRegistryURL: server.URL,
+	}
+	abiDecoder := NewABIDecoder(abiFiles, nil, context.Background())
+	getSchema := MessageSchemaGenerator{Namespace: "test", Account: "eosio.nft.ft"}.getTableSchema
+	abiCodec, err := c.newABICodec(abiDecoder, getSchema, NewStreamedAbiCodec)
+	if err != nil {
+		t.Fatalf("newABICodec() error: %v", err)
+	}
+	codec, err := abiCodec.GetCodec(CodecId{"eosio.nft.ft", "factory.a"}, 42)
+	if err != nil {
+		t.Fatalf("GetCodec() error: %v", err)
+	}
+	if dataSchema := findHeader("ce_dataschema", codec.GetHeaders()); !strings.HasPrefix(dataSchema, dryRunSchemaRegistryURL) {
+		t.Errorf("ce_dataschema = %s, want the %s schema registry", dataSchema, dryRunSchemaRegistryURL)
+	}
+	mu.Lock()
+	defer mu.Unlock()
+	if len(requests) > 0 {
+		t.Errorf("the dry run called the schema registry: %v", requests)
+	}
+}
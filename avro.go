@@ -179,6 +179,24 @@ func NewTimestampMillisType(eosType string) TypedSchema {
 	}
 }
 
+func NewTimestampMicrosType(eosType string) TypedSchema {
+	return TypedSchema{
+		Type:        "long",
+		LogicalType: "timestamp-micros",
+		EosType:     eosType,
+	}
+}
+
+// FixedSchema is a named fixed size binary type
+type FixedSchema struct {
+	Type      string `json:"type"`
+	Name      string `json:"name"`
+	Namespace string `json:"namespace,omitempty"`
+	Size      int    `json:"size"`
+	EosType   string `json:"eos.type,omitempty"`
+	Convert   string `json:"convert,omitempty"`
+}
+
 type DecimalLogicalType struct {
 	TypedSchema
 
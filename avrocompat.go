@@ -0,0 +1,354 @@
+package dkafka
+
+import (
+	"encoding/json"
+	"fmt"
+	"strings"
+
+	"github.com/riferrei/srclient"
+)
+
+var avroPrimitives = map[string]bool{
+	"null":    true,
+	"boolean": true,
+	"int":     true,
+	"long":    true,
+	"float":   true,
+	"double":  true,
+	"bytes":   true,
+	"string":  true,
+}
+
+// avroPromotions lists for each writer type the reader types it can be
+// promoted to according to the Avro schema resolution rules.
+var avroPromotions = map[string][]string{
+	"int":    {"long", "float", "double"},
+	"long":   {"float", "double"},
+	"float":  {"double"},
+	"string": {"bytes"},
+	"bytes":  {"string"},
+}
+
+// avroSchema is a parsed Avro schema with its named types indexed by full name.
+type avroSchema struct {
+	root  interface{}
+	names map[string]interface{}
+}
+
+func parseAvroSchema(schema Schema) (*avroSchema, error) {
+	bytes, err := json.Marshal(schema)
+	if err != nil {
+		return nil, fmt.Errorf("cannot encode avro schema: %w", err)
+	}
+	var root interface{}
+	if err := json.Unmarshal(bytes, &root); err != nil {
+		return nil, fmt.Errorf("cannot decode avro schema: %w", err)
+	}
+	s := &avroSchema{root: root, names: make(map[string]interface{})}
+	s.indexNames(root, "")
+	return s, nil
+}
+
+func avroFullName(name string, namespace string) string {
+	if strings.Contains(name, ".") || namespace == "" {
+		return name
+	}
+	return namespace + "." + name
+}
+
+func avroNamespace(fullName string) string {
+	if i := strings.LastIndex(fullName, "."); i >= 0 {
+		return fullName[:i]
+	}
+	return ""
+}
+
+// namedFullName returns the full name of a named type definition
+func namedFullName(def map[string]interface{}, namespace string) string {
+	name, _ := def["name"].(string)
+	if np, ok := def["namespace"].(string); ok && np != "" {
+		namespace = np
+	}
+	return avroFullName(name, namespace)
+}
+
+// logicalType returns the logical type of a primitive type with attributes
+func logicalType(schema interface{}) (string, string, bool) {
+	typed, ok := schema.(map[string]interface{})
+	if !ok {
+		return "", "", false
+	}
+	t, ok := typed["type"].(string)
+	if !ok || !avroPrimitives[t] {
+		return "", "", false
+	}
+	logical, _ := typed["logicalType"].(string)
+	return t, logical, true
+}
+
+func (s *avroSchema) indexNames(schema interface{}, namespace string) {
+	switch typed := schema.(type) {
+	case []interface{}:
+		for _, branch := range typed {
+			s.indexNames(branch, namespace)
+		}
+	case map[string]interface{}:
+		switch t := typed["type"].(type) {
+		case string:
+			switch t {
+			case "record", "error", "enum", "fixed":
+				name, _ := typed["name"].(string)
+				if np, ok := typed["namespace"].(string); ok && np != "" {
+					namespace = np
+				}
+				fullName := avroFullName(name, namespace)
+				s.names[fullName] = typed
+				namespace = avroNamespace(fullName)
+				if fields, ok := typed["fields"].([]interface{}); ok {
+					for _, field := range fields {
+						if f, ok := field.(map[string]interface{}); ok {
+							s.indexNames(f["type"], namespace)
+						}
+					}
+				}
+			case "array":
+				s.indexNames(typed["items"], namespace)
+			case "map":
+				s.indexNames(typed["values"], namespace)
+			}
+		default:
+			s.indexNames(t, namespace)
+		}
+	}
+}
+
+// resolve returns the definition of the given schema node, following the
+// named type references, with its type and the namespace of its children.
+func (s *avroSchema) resolve(schema interface{}, namespace string) (interface{}, string, string) {
+	switch typed := schema.(type) {
+	case string:
+		if avroPrimitives[typed] {
+			return typed, typed, namespace
+		}
+		for _, fullName := range []string{avroFullName(typed, namespace), typed} {
+			if def, found := s.names[fullName]; found {
+				return def, def.(map[string]interface{})["type"].(string), avroNamespace(fullName)
+			}
+		}
+		return typed, typed, namespace
+	case []interface{}:
+		return typed, "union", namespace
+	case map[string]interface{}:
+		switch t := typed["type"].(type) {
+		case string:
+			switch t {
+			case "record", "error", "enum", "fixed":
+				name, _ := typed["name"].(string)
+				if np, ok := typed["namespace"].(string); ok && np != "" {
+					namespace = np
+				}
+				return typed, t, avroNamespace(avroFullName(name, namespace))
+			case "array", "map":
+				return typed, t, namespace
+			default:
+				// primitive type with attributes like logicalType
+				return s.resolve(t, namespace)
+			}
+		default:
+			return s.resolve(t, namespace)
+		}
+	}
+	return schema, "", namespace
+}
+
+// AvroCanRead checks that data written with the writer schema can be read
+// with the reader schema according to the Avro schema resolution rules.
+// The returned error describes the first incompatibility found.
+func AvroCanRead(reader Schema, writer Schema) error {
+	r, err := parseAvroSchema(reader)
+	if err != nil {
+		return err
+	}
+	w, err := parseAvroSchema(writer)
+	if err != nil {
+		return err
+	}
+	c := avroCompatChecker{reader: r, writer: w, visited: make(map[string]bool)}
+	return c.check("", r.root, w.root, "", "")
+}
+
+type avroCompatChecker struct {
+	reader  *avroSchema
+	writer  *avroSchema
+	visited map[string]bool
+}
+
+func (c avroCompatChecker) check(path string, reader interface{}, writer interface{}, rns string, wns string) error {
+	reader, rType, rns := c.reader.resolve(reader, rns)
+	writer, wType, wns := c.writer.resolve(writer, wns)
+	if wType == "union" {
+		for _, branch := range writer.([]interface{}) {
+			if err := c.check(path, reader, branch, rns, wns); err != nil {
+				return err
+			}
+		}
+		return nil
+	}
+	if rType == "union" {
+		for _, branch := range reader.([]interface{}) {
+			if err := c.check(path, branch, writer, rns, wns); err == nil {
+				return nil
+			}
+		}
+		return fmt.Errorf("%s: writer type '%s' does not match any branch of the reader union", displayPath(path), wType)
+	}
+	if rType != wType {
+		for _, promoted := range avroPromotions[wType] {
+			if promoted == rType {
+				return nil
+			}
+		}
+		return fmt.Errorf("%s: writer type '%s' cannot be read as '%s'", displayPath(path), wType, rType)
+	}
+	if avroPrimitives[rType] {
+		return nil
+	}
+	r := reader.(map[string]interface{})
+	w := writer.(map[string]interface{})
+	switch rType {
+	case "array":
+		return c.check(path+"[]", r["items"], w["items"], rns, wns)
+	case "map":
+		return c.check(path+"{}", r["values"], w["values"], rns, wns)
+	case "fixed":
+		if err := checkSameName(path, r, w); err != nil {
+			return err
+		}
+		if r["size"] != w["size"] {
+			return fmt.Errorf("%s: fixed size changed from %v to %v", displayPath(path), w["size"], r["size"])
+		}
+	case "enum":
+		if err := checkSameName(path, r, w); err != nil {
+			return err
+		}
+		if _, hasDefault := r["default"]; hasDefault {
+			return nil
+		}
+		symbols := make(map[interface{}]bool)
+		for _, symbol := range r["symbols"].([]interface{}) {
+			symbols[symbol] = true
+		}
+		for _, symbol := range w["symbols"].([]interface{}) {
+			if !symbols[symbol] {
+				return fmt.Errorf("%s: enum symbol '%v' unknown by the reader", displayPath(path), symbol)
+			}
+		}
+	case "record", "error":
+		if err := checkSameName(path, r, w); err != nil {
+			return err
+		}
+		key := fmt.Sprintf("%s|%s|%s", rns, r["name"], w["name"])
+		if c.visited[key] {
+			return nil
+		}
+		c.visited[key] = true
+		writerFields := make(map[string]map[string]interface{})
+		for _, field := range w["fields"].([]interface{}) {
+			f := field.(map[string]interface{})
+			writerFields[f["name"].(string)] = f
+		}
+		for _, field := range r["fields"].([]interface{}) {
+			f := field.(map[string]interface{})
+			name := f["name"].(string)
+			fieldPath := name
+			if path != "" {
+				fieldPath = path + "." + name
+			}
+			writerField, found := writerFields[name]
+			if !found {
+				if _, hasDefault := f["default"]; !hasDefault {
+					return fmt.Errorf("%s: field missing in writer schema and without default value", fieldPath)
+				}
+				continue
+			}
+			if err := c.check(fieldPath, f["type"], writerField["type"], rns, wns); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+func checkSameName(path string, reader map[string]interface{}, writer map[string]interface{}) error {
+	rName := unqualifiedName(reader["name"])
+	wName := unqualifiedName(writer["name"])
+	if rName != wName {
+		return fmt.Errorf("%s: named type changed from '%s' to '%s'", displayPath(path), wName, rName)
+	}
+	return nil
+}
+
+func unqualifiedName(name interface{}) string {
+	s, _ := name.(string)
+	if i := strings.LastIndex(s, "."); i >= 0 {
+		return s[i+1:]
+	}
+	return s
+}
+
+func displayPath(path string) string {
+	if path == "" {
+		return "<root>"
+	}
+	return path
+}
+
+// AvroCompatibility holds the result of the compatibility check between two
+// versions of a schema.
+type AvroCompatibility struct {
+	// BackwardErr is not nil when the next schema cannot read data written
+	// with the previous one.
+	BackwardErr error
+	// ForwardErr is not nil when the previous schema cannot read data
+	// written with the next one.
+	ForwardErr error
+}
+
+// CheckAvroCompatibility evaluates the backward and forward compatibility of
+// the next version of a schema against the previous one.
+func CheckAvroCompatibility(previous Schema, next Schema) AvroCompatibility {
+	return AvroCompatibility{
+		BackwardErr: AvroCanRead(next, previous),
+		ForwardErr:  AvroCanRead(previous, next),
+	}
+}
+
+// Level returns the strongest compatibility level satisfied.
+func (c AvroCompatibility) Level() srclient.CompatibilityLevel {
+	switch {
+	case c.BackwardErr == nil && c.ForwardErr == nil:
+		return srclient.Full
+	case c.BackwardErr == nil:
+		return srclient.Backward
+	case c.ForwardErr == nil:
+		return srclient.Forward
+	default:
+		return srclient.None
+	}
+}
+
+// Satisfies reports whether the required compatibility level is met. The
+// transitive levels are checked as their non transitive form as only two
+// versions are compared.
+func (c AvroCompatibility) Satisfies(required srclient.CompatibilityLevel) bool {
+	switch required {
+	case srclient.Backward, srclient.BackwardTransitive:
+		return c.BackwardErr == nil
+	case srclient.Forward, srclient.ForwardTransitive:
+		return c.ForwardErr == nil
+	case srclient.Full, srclient.FullTransitive:
+		return c.BackwardErr == nil && c.ForwardErr == nil
+	default:
+		return true
+	}
+}
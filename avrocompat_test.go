@@ -0,0 +1,129 @@
+package dkafka
+
+import (
+	"testing"
+
+	"github.com/riferrei/srclient"
+)
+
+func TestAvroCanRead(t *testing.T) {
+	record := func(fields ...FieldSchema) RecordSchema {
+		return newRecordFQN("test", "Rec", fields)
+	}
+	tests := []struct {
+		name    string
+		reader  Schema
+		writer  Schema
+		wantErr bool
+	}{
+		{
+			name:   "same primitive",
+			reader: "long",
+			writer: "long",
+		},
+		{
+			name:   "promotion",
+			reader: "long",
+			writer: "int",
+		},
+		{
+			name:    "no demotion",
+			reader:  "int",
+			writer:  "long",
+			wantErr: true,
+		},
+		{
+			name:   "logical type on primitive",
+			reader: NewTimestampMillisType("block_timestamp_type"),
+			writer: "long",
+		},
+		{
+			name:   "writer branch in reader union",
+			reader: NewOptional("string"),
+			writer: "string",
+		},
+		{
+			name:    "reader cannot read null branch",
+			reader:  "string",
+			writer:  NewOptional("string"),
+			wantErr: true,
+		},
+		{
+			name:   "reader ignores removed field",
+			reader: record(FieldSchema{Name: "a", Type: "string"}),
+			writer: record(FieldSchema{Name: "a", Type: "string"}, FieldSchema{Name: "b", Type: "string"}),
+		},
+		{
+			name:   "added field with default",
+			reader: record(FieldSchema{Name: "a", Type: "string"}, NewOptionalField("b", "string")),
+			writer: record(FieldSchema{Name: "a", Type: "string"}),
+		},
+		{
+			name:    "added field without default",
+			reader:  record(FieldSchema{Name: "a", Type: "string"}, FieldSchema{Name: "b", Type: "string"}),
+			writer:  record(FieldSchema{Name: "a", Type: "string"}),
+			wantErr: true,
+		},
+		{
+			name:    "renamed record",
+			reader:  newRecordFQN("test", "Other", []FieldSchema{{Name: "a", Type: "string"}}),
+			writer:  record(FieldSchema{Name: "a", Type: "string"}),
+			wantErr: true,
+		},
+		{
+			name: "named type reference",
+			reader: record(
+				FieldSchema{Name: "a", Type: newRecordS("Inner", []FieldSchema{{Name: "x", Type: "long"}})},
+				FieldSchema{Name: "b", Type: NewArray("test.Inner")},
+			),
+			writer: record(
+				FieldSchema{Name: "a", Type: newRecordS("Inner", []FieldSchema{{Name: "x", Type: "int"}})},
+				FieldSchema{Name: "b", Type: NewArray("Inner")},
+			),
+		},
+		{
+			name: "incompatible named type reference",
+			reader: record(
+				FieldSchema{Name: "a", Type: newRecordS("Inner", []FieldSchema{{Name: "x", Type: "int"}})},
+				FieldSchema{Name: "b", Type: NewArray("Inner")},
+			),
+			writer: record(
+				FieldSchema{Name: "a", Type: newRecordS("Inner", []FieldSchema{{Name: "x", Type: "long"}})},
+				FieldSchema{Name: "b", Type: NewArray("Inner")},
+			),
+			wantErr: true,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if err := AvroCanRead(tt.reader, tt.writer); (err != nil) != tt.wantErr {
+				t.Errorf("AvroCanRead() error = %v, wantErr %v", err, tt.wantErr)
+			}
+		})
+	}
+}
+
+func TestAvroCompatibility_Level(t *testing.T) {
+	previous := newRecordFQN("test", "Rec", []FieldSchema{{Name: "a", Type: "string"}})
+	next := newRecordFQN("test", "Rec", []FieldSchema{{Name: "a", Type: "string"}, NewOptionalField("b", "string")})
+	strict := newRecordFQN("test", "Rec", []FieldSchema{{Name: "a", Type: "string"}, {Name: "b", Type: "string"}})
+	tests := []struct {
+		name     string
+		previous Schema
+		next     Schema
+		want     srclient.CompatibilityLevel
+	}{
+		{"same", previous, previous, srclient.Full},
+		{"optional field added", previous, next, srclient.Full},
+		{"required field added", previous, strict, srclient.Forward},
+		{"required field removed", strict, previous, srclient.Backward},
+		{"type changed", previous, newRecordFQN("test", "Rec", []FieldSchema{{Name: "a", Type: "long"}}), srclient.None},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := CheckAvroCompatibility(tt.previous, tt.next).Level(); got != tt.want {
+				t.Errorf("CheckAvroCompatibility().Level() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
@@ -1,17 +1,22 @@
 package dkafka
 
 import (
+	"bytes"
 	"encoding/json"
 	"fmt"
 	"reflect"
+	"strconv"
 	"strings"
 	"time"
 
 	pbcodec "github.com/dfuse-io/dfuse-eosio/pb/dfuse/eosio/codec/v1"
 	"github.com/google/cel-go/cel"
+	"github.com/google/cel-go/checker"
 	"github.com/google/cel-go/checker/decls"
 	"github.com/google/cel-go/interpreter"
 	"go.uber.org/zap"
+	exprpb "google.golang.org/genproto/googleapis/api/expr/v1alpha1"
+	"google.golang.org/protobuf/proto"
 )
 
 var Declarations = cel.Declarations(
@@ -103,6 +108,9 @@ var TableDeclarations = cel.Declarations(
 	decls.NewVar("table", decls.NewMapType(decls.String, decls.Any)),
 )
 
+// NewTableActivation returns the activation of a table expression. db_op is
+// the database operation and table the decoded rows: table.name, table.old and
+// table.new, the rows are only set when they exist for the operation.
 func NewTableActivation(stepName string, transaction *pbcodec.TransactionTrace, trace *pbcodec.ActionTrace, decodedDBOp *decodedDBOp) (interpreter.Activation, error) {
 	var activationMap = map[string]interface{}{
 		"block_num":         transaction.BlockNum,
@@ -126,49 +134,77 @@ func NewTableActivation(stepName string, transaction *pbcodec.TransactionTrace,
 		"account": trace.Account(),
 		"action":  trace.Name(),
 		"db_op": func() interface{} {
-			if trace.Receipt == nil {
-				return nil
+			dbOp := map[string]interface{}{
+				"operation":    int64(decodedDBOp.Operation),
+				"action_index": uint64(decodedDBOp.ActionIndex),
+				"code":         decodedDBOp.Code,
+				"scope":        decodedDBOp.Scope,
+				"table_name":   decodedDBOp.TableName,
+				"primary_key":  decodedDBOp.PrimaryKey,
+				"old_payer":    decodedDBOp.OldPayer,
+				"new_payer":    decodedDBOp.NewPayer,
 			}
-			// maybe we can have a look to https://github.com/mitchellh/mapstructure/blob/master/mapstructure.go
-			if rawJSON, err := json.Marshal(decodedDBOp); err != nil {
-				zlog.Fatal("invalid dpOp", zap.Error(err), zap.Uint64("globalSequence", trace.Receipt.GlobalSequence))
-			} else {
-				return rawJsonToMap(rawJSON)
-			}
-			return nil
+			addOptional(&dbOp, "old_json", rowToCelMap(decodedDBOp.OldJSON))
+			addOptional(&dbOp, "new_json", rowToCelMap(decodedDBOp.NewJSON))
+			return dbOp
 		},
 		"table": func() interface{} {
-			if trace.Receipt == nil {
-				return nil
-			}
-			switch op := decodedDBOp.Operation; op {
-			case pbcodec.DBOp_OPERATION_INSERT:
-				if rawJSON, err := json.Marshal(decodedDBOp.NewJSON); err != nil {
-					zlog.Fatal("invalid dpOp", zap.Error(err), zap.Uint64("globalSequence", trace.Receipt.GlobalSequence))
-				} else {
-					return rawJsonToMap(rawJSON)
-				}
-			case pbcodec.DBOp_OPERATION_UPDATE:
-				if rawJSON, err := json.Marshal(decodedDBOp.NewJSON); err != nil {
-					zlog.Fatal("invalid dpOp", zap.Error(err), zap.Uint64("globalSequence", trace.Receipt.GlobalSequence))
-				} else {
-					return rawJsonToMap(rawJSON)
-				}
-			case pbcodec.DBOp_OPERATION_REMOVE:
-				if rawJSON, err := json.Marshal(decodedDBOp.OldJSON); err != nil {
-					zlog.Fatal("invalid dpOp", zap.Error(err), zap.Uint64("globalSequence", trace.Receipt.GlobalSequence))
-				} else {
-					return rawJsonToMap(rawJSON)
-				}
-			default:
-				zlog.Fatal("unsupported operation type", zap.Int32("operation", int32(op)))
+			table := map[string]interface{}{
+				"name": decodedDBOp.TableName,
 			}
-			return nil
+			addOptional(&table, "old", rowToCelMap(decodedDBOp.OldJSON))
+			addOptional(&table, "new", rowToCelMap(decodedDBOp.NewJSON))
+			return table
 		},
 	}
 	return interpreter.NewActivation(activationMap)
 }
 
+// rowToCelMap returns the JSON representation of a decoded row where the
+// integers are CEL int or uint instead of double, so that they can be
+// compared with the integer literals.
+func rowToCelMap(row map[string]interface{}) map[string]interface{} {
+	if len(row) == 0 {
+		return nil
+	}
+	rawJSON, err := json.Marshal(row)
+	if err != nil {
+		zlog.Error("invalid row", zap.Error(err))
+		return nil
+	}
+	decoder := json.NewDecoder(bytes.NewReader(rawJSON))
+	decoder.UseNumber()
+	var out map[string]interface{}
+	if err := decoder.Decode(&out); err != nil {
+		zlog.Error("invalid json row", zap.Error(err), zap.String("json", string(rawJSON)))
+		return nil
+	}
+	return celNumbers(out).(map[string]interface{})
+}
+
+func celNumbers(value interface{}) interface{} {
+	switch v := value.(type) {
+	case json.Number:
+		if i, err := strconv.ParseInt(string(v), 10, 64); err == nil {
+			return i
+		}
+		if u, err := strconv.ParseUint(string(v), 10, 64); err == nil {
+			return u
+		}
+		f, _ := v.Float64()
+		return f
+	case map[string]interface{}:
+		for key, item := range v {
+			v[key] = celNumbers(item)
+		}
+	case []interface{}:
+		for i, item := range v {
+			v[i] = celNumbers(item)
+		}
+	}
+	return value
+}
+
 var ActionDeclarations = cel.Declarations(
 	decls.NewVar("receiver", decls.String), // eosio.account_name::receiver
 	decls.NewVar("account", decls.String),  // eosio.account_name::account
@@ -289,12 +325,48 @@ func evalStringArray(prog cel.Program, activation interface{}) ([]string, error)
 	return out.([]string), nil
 }
 
+var boolType = reflect.TypeOf(true)
+
+func evalBool(prog cel.Program, activation interface{}) (bool, error) {
+	res, _, err := prog.Eval(activation)
+	if err != nil {
+		return false, err
+	}
+	out, err := res.ConvertToNative(boolType)
+	if err != nil {
+		return false, err
+	}
+	return out.(bool), nil
+}
+
 func exprToCelProgram(stripped string) (prog cel.Program, err error) {
 	return exprToCelProgramWithEnv(stripped, Declarations)
 }
 
 func exprToCelProgramWithEnv(stripped string, declarations cel.EnvOption) (prog cel.Program, err error) {
-	env, err := cel.NewEnv(declarations)
+	env, err := cel.NewEnv(declarations, EOSIOLib())
+	if err != nil {
+		return nil, fmt.Errorf("creating new CEL environment: %w", err)
+	}
+
+	exprAst, issues := env.Compile(stripped)
+	if issues != nil && issues.Err() != nil {
+		return nil, fmt.Errorf("compiling AST expression %s: %w", stripped, issues.Err())
+	}
+
+	prog, err = env.Program(exprAst)
+	if err != nil {
+		return nil, fmt.Errorf("creating program from AST expression %s: %w", stripped, err)
+	}
+	return
+}
+
+// exprToTypedCelProgram compiles the expression like exprToCelProgramWithEnv
+// and checks that its result type is the expected one (decls.Bool,
+// decls.String, ...), dyn or any like the map values, those are only checked
+// at evaluation.
+func exprToTypedCelProgram(stripped string, declarations cel.EnvOption, resultType *exprpb.Type) (prog cel.Program, err error) {
+	env, err := cel.NewEnv(declarations, EOSIOLib())
 	if err != nil {
 		return nil, fmt.Errorf("creating new CEL environment: %w", err)
 	}
@@ -303,6 +375,9 @@ func exprToCelProgramWithEnv(stripped string, declarations cel.EnvOption) (prog
 	if issues != nil && issues.Err() != nil {
 		return nil, fmt.Errorf("compiling AST expression %s: %w", stripped, issues.Err())
 	}
+	if t := exprAst.ResultType(); !proto.Equal(t, resultType) && !proto.Equal(t, decls.Dyn) && !proto.Equal(t, decls.Any) {
+		return nil, fmt.Errorf("expression %s must return a %s but returns a %s", stripped, checker.FormatCheckedType(resultType), checker.FormatCheckedType(t))
+	}
 
 	prog, err = env.Program(exprAst)
 	if err != nil {
@@ -18,8 +18,8 @@ func Test_exprToCelProgram(t *testing.T) {
 	if err != nil {
 		t.Fatalf("Unmarshal() error: %v", err)
 	}
-	var localABIFiles = map[string]string{
-		"eosio.nft.ft": "testdata/eosio.nft.ft.abi",
+	var localABIFiles = map[string][]string{
+		"eosio.nft.ft": {"testdata/eosio.nft.ft.abi"},
 	}
 	abiFiles, err := LoadABIFiles(localABIFiles)
 	if err != nil {
@@ -196,8 +196,8 @@ func Benchmark_exprToCelProgram_activation(b *testing.B) {
 	if err != nil {
 		b.Fatalf("Unmarshal() error: %v", err)
 	}
-	var localABIFiles = map[string]string{
-		"eosio.nft.ft": "testdata/eosio.nft.ft.abi",
+	var localABIFiles = map[string][]string{
+		"eosio.nft.ft": {"testdata/eosio.nft.ft.abi"},
 	}
 	abiFiles, err := LoadABIFiles(localABIFiles)
 	if err != nil {
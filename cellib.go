@@ -0,0 +1,184 @@
+package dkafka
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"strconv"
+	"strings"
+
+	"github.com/eoscanada/eos-go"
+	"github.com/google/cel-go/cel"
+	"github.com/google/cel-go/checker/decls"
+	"github.com/google/cel-go/common/types"
+	"github.com/google/cel-go/common/types/ref"
+	"github.com/google/cel-go/common/types/traits"
+	"github.com/google/cel-go/interpreter/functions"
+	exprpb "google.golang.org/genproto/googleapis/api/expr/v1alpha1"
+)
+
+// EOSIOLib returns the CEL library of the EOSIO helpers available in all the
+// dkafka expressions:
+//   - name_to_uint(string) uint: the uint64 value of an account name
+//   - uint_to_name(uint) string: the account name of an uint64 value
+//   - asset_amount(string) int: the amount in the smallest unit of an asset, "1.0000 EOS" is 10000
+//   - asset_symbol(string) string: the symbol code of an asset, "1.0000 EOS" is "EOS"
+//   - asset_precision(string) uint: the precision of an asset, "1.0000 EOS" is 4
+//   - hex(string|bytes) string: the hexadecimal encoding
+//   - sha256(string|bytes) string: the hexadecimal SHA-256 digest
+//   - lookup(dyn, string, dyn) dyn: the value at the dot separated path of a
+//     map or list, like "0.new_json.owner", or the default when it is missing or null
+//   - has_auth(list(string), string) bool: if the authorizations contain the
+//     "actor@permission" or the actor
+func EOSIOLib() cel.EnvOption {
+	return cel.Lib(eosioLibrary{})
+}
+
+type eosioLibrary struct{}
+
+func (eosioLibrary) CompileOptions() []cel.EnvOption {
+	return []cel.EnvOption{
+		cel.Declarations(
+			decls.NewFunction("name_to_uint",
+				decls.NewOverload("name_to_uint_string", []*exprpb.Type{decls.String}, decls.Uint)),
+			decls.NewFunction("uint_to_name",
+				decls.NewOverload("uint_to_name_uint", []*exprpb.Type{decls.Uint}, decls.String)),
+			decls.NewFunction("asset_amount",
+				decls.NewOverload("asset_amount_string", []*exprpb.Type{decls.String}, decls.Int)),
+			decls.NewFunction("asset_symbol",
+				decls.NewOverload("asset_symbol_string", []*exprpb.Type{decls.String}, decls.String)),
+			decls.NewFunction("asset_precision",
+				decls.NewOverload("asset_precision_string", []*exprpb.Type{decls.String}, decls.Uint)),
+			decls.NewFunction("hex",
+				decls.NewOverload("hex_string", []*exprpb.Type{decls.String}, decls.String),
+				decls.NewOverload("hex_bytes", []*exprpb.Type{decls.Bytes}, decls.String)),
+			decls.NewFunction("sha256",
+				decls.NewOverload("sha256_string", []*exprpb.Type{decls.String}, decls.String),
+				decls.NewOverload("sha256_bytes", []*exprpb.Type{decls.Bytes}, decls.String)),
+			decls.NewFunction("lookup",
+				decls.NewOverload("lookup_dyn_string_dyn", []*exprpb.Type{decls.Dyn, decls.String, decls.Dyn}, decls.Dyn)),
+			decls.NewFunction("has_auth",
+				decls.NewOverload("has_auth_list_string", []*exprpb.Type{decls.NewListType(decls.String), decls.String}, decls.Bool)),
+		),
+	}
+}
+
+func (eosioLibrary) ProgramOptions() []cel.ProgramOption {
+	return []cel.ProgramOption{
+		cel.Functions(
+			&functions.Overload{Operator: "name_to_uint", Unary: celNameToUint},
+			&functions.Overload{Operator: "uint_to_name", Unary: celUintToName},
+			&functions.Overload{Operator: "asset_amount", Unary: celAsset(func(asset eos.Asset) ref.Val {
+				return types.Int(asset.Amount)
+			})},
+			&functions.Overload{Operator: "asset_symbol", Unary: celAsset(func(asset eos.Asset) ref.Val {
+				return types.String(asset.Symbol.Symbol)
+			})},
+			&functions.Overload{Operator: "asset_precision", Unary: celAsset(func(asset eos.Asset) ref.Val {
+				return types.Uint(asset.Symbol.Precision)
+			})},
+			&functions.Overload{Operator: "hex", Unary: celBytes(hex.EncodeToString)},
+			&functions.Overload{Operator: "sha256", Unary: celBytes(func(in []byte) string {
+				digest := sha256.Sum256(in)
+				return hex.EncodeToString(digest[:])
+			})},
+			&functions.Overload{Operator: "lookup", Function: celLookup},
+			&functions.Overload{Operator: "has_auth", Binary: celHasAuth},
+		),
+	}
+}
+
+func celNameToUint(value ref.Val) ref.Val {
+	name, ok := value.(types.String)
+	if !ok {
+		return types.MaybeNoSuchOverloadErr(value)
+	}
+	// StringToName does not validate the name, it must be the same once
+	// converted back
+	val, err := eos.StringToName(string(name))
+	if err != nil || eos.NameToString(val) != string(name) {
+		return types.NewErr("invalid name: '%s'", name)
+	}
+	return types.Uint(val)
+}
+
+func celUintToName(value ref.Val) ref.Val {
+	val, ok := value.(types.Uint)
+	if !ok {
+		return types.MaybeNoSuchOverloadErr(value)
+	}
+	return types.String(eos.NameToString(uint64(val)))
+}
+
+func celAsset(get func(eos.Asset) ref.Val) functions.UnaryOp {
+	return func(value ref.Val) ref.Val {
+		s, ok := value.(types.String)
+		if !ok {
+			return types.MaybeNoSuchOverloadErr(value)
+		}
+		asset, err := eos.NewAssetFromString(string(s))
+		if err != nil {
+			return types.NewErr("invalid asset: '%s', error: %v", s, err)
+		}
+		return get(asset)
+	}
+}
+
+func celBytes(encode func([]byte) string) functions.UnaryOp {
+	return func(value ref.Val) ref.Val {
+		switch v := value.(type) {
+		case types.String:
+			return types.String(encode([]byte(v)))
+		case types.Bytes:
+			return types.String(encode([]byte(v)))
+		default:
+			return types.MaybeNoSuchOverloadErr(value)
+		}
+	}
+}
+
+func celLookup(values ...ref.Val) ref.Val {
+	if len(values) != 3 {
+		return types.NewErr("no such overload: lookup expects 3 arguments")
+	}
+	path, ok := values[1].(types.String)
+	if !ok {
+		return types.MaybeNoSuchOverloadErr(values[1])
+	}
+	value, defaultValue := values[0], values[2]
+	if path == "" {
+		return value
+	}
+	for _, segment := range strings.Split(string(path), ".") {
+		switch container := value.(type) {
+		case traits.Mapper:
+			found, ok := container.Find(types.String(segment))
+			if !ok || types.IsError(found) {
+				return defaultValue
+			}
+			value = found
+		case traits.Lister:
+			index, err := strconv.Atoi(segment)
+			if err != nil || index < 0 || types.Int(index) >= container.Size().(types.Int) {
+				return defaultValue
+			}
+			value = container.Get(types.Int(index))
+		default:
+			return defaultValue
+		}
+	}
+	if value == nil || value.Type() == types.NullType {
+		return defaultValue
+	}
+	return value
+}
+
+func celHasAuth(auth ref.Val, authorization ref.Val) ref.Val {
+	list, ok := auth.(traits.Lister)
+	if !ok {
+		return types.MaybeNoSuchOverloadErr(auth)
+	}
+	if _, ok := authorization.(types.String); !ok {
+		return types.MaybeNoSuchOverloadErr(authorization)
+	}
+	return list.Contains(authorization)
+}
@@ -0,0 +1,190 @@
+package dkafka
+
+import (
+	"context"
+	"encoding/json"
+	"testing"
+
+	pbcodec "github.com/dfuse-io/dfuse-eosio/pb/dfuse/eosio/codec/v1"
+	"github.com/google/cel-go/cel"
+	"github.com/google/cel-go/checker/decls"
+)
+
+func TestEOSIOLib(t *testing.T) {
+	byteValue := readFileFromTestdata(t, "testdata/block-30080032.json")
+
+	block := &pbcodec.Block{}
+	if err := json.Unmarshal(byteValue, block); err != nil {
+		t.Fatalf("Unmarshal() error: %v", err)
+	}
+	abiFiles, err := LoadABIFiles(map[string][]string{
+		"eosio.nft.ft": {"testdata/eosio.nft.ft.abi"},
+	})
+	if err != nil {
+		t.Fatalf("LoadABIFiles() error: %v", err)
+	}
+	abiDecoder := NewABIDecoder(abiFiles, nil, context.Background())
+
+	transactionTrace := block.TransactionTraces()[0]
+	act := transactionTrace.ActionTraces[0]
+	decodedDBOps, err := abiDecoder.DecodeDBOps(transactionTrace.DBOpsForAction(act.ExecutionIndex), block.Number)
+	if err != nil {
+		t.Fatalf("DecodeDBOps() error: %v", err)
+	}
+	activation, err := NewActivation("NEW", transactionTrace, act, decodedDBOps)
+	if err != nil {
+		t.Fatalf("NewActivation() error: %v", err)
+	}
+
+	tests := []struct {
+		name           string
+		expression     string
+		wantString     string
+		wantCompileErr bool
+		wantErr        bool
+	}{
+		{
+			name:       "name_to_uint",
+			expression: "string(name_to_uint('eosio'))",
+			wantString: "6138663577826885632",
+		},
+		{
+			name:       "uint_to_name",
+			expression: "uint_to_name(6138663577826885632u)",
+			wantString: "eosio",
+		},
+		{
+			name:       "name round trip",
+			expression: "uint_to_name(name_to_uint(receiver))",
+			wantString: "eosio.nft.ft",
+		},
+		{
+			name:       "invalid name",
+			expression: "string(name_to_uint('Not.A.Name'))",
+			wantErr:    true,
+		},
+		{
+			name:       "asset_amount",
+			expression: "string(asset_amount('1.0000 EOS'))",
+			wantString: "10000",
+		},
+		{
+			name:       "asset_symbol",
+			expression: "asset_symbol(data.create.minimum_resell_price)",
+			wantString: "USD",
+		},
+		{
+			name:       "asset_precision",
+			expression: "string(asset_precision(data.create.minimum_resell_price))",
+			wantString: "8",
+		},
+		{
+			name:       "invalid asset",
+			expression: "string(asset_amount(data.create.memo))",
+			wantErr:    true,
+		},
+		{
+			name:           "asset_amount of an int",
+			expression:     "string(asset_amount(1))",
+			wantCompileErr: true,
+		},
+		{
+			name:       "hex",
+			expression: "hex('abc')",
+			wantString: "616263",
+		},
+		{
+			name:       "hex bytes",
+			expression: "hex(b'abc')",
+			wantString: "616263",
+		},
+		{
+			name:       "sha256",
+			expression: "sha256('abc')",
+			wantString: "ba7816bf8f01cfea414140de5dae2223b00361a396177a9cb410ff61f20015ad",
+		},
+		{
+			name:       "lookup",
+			expression: "lookup(data, 'create.memo', '')",
+			wantString: "b0b70c2f-5895-42b7-bfbf-0992bd815271",
+		},
+		{
+			name:       "lookup list index",
+			expression: "lookup(data, 'create.resale_shares.1.receiver', '')",
+			wantString: "vl1jt2hi3vd4",
+		},
+		{
+			name:       "lookup null",
+			expression: "lookup(data, 'create.stat', 'none')",
+			wantString: "none",
+		},
+		{
+			name:       "lookup missing",
+			expression: "lookup(data, 'create.missing.field', 'none')",
+			wantString: "none",
+		},
+		{
+			name:       "lookup out of range",
+			expression: "lookup(data, 'create.resale_shares.2.receiver', 'none')",
+			wantString: "none",
+		},
+		{
+			name:       "lookup db_ops",
+			expression: "lookup(db_ops, '0.table_name', '')",
+			wantString: "next.factory",
+		},
+		{
+			name:       "has_auth permission",
+			expression: "string(has_auth(auth, 'ultra.nft.ft@backend'))",
+			wantString: "true",
+		},
+		{
+			name:       "has_auth actor",
+			expression: "string(has_auth(auth, 'ultra.nft.ft'))",
+			wantString: "true",
+		},
+		{
+			name:       "has_auth other permission",
+			expression: "string(has_auth(auth, 'ultra.nft.ft@active'))",
+			wantString: "false",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			gotProg, err := exprToCelProgram(tt.expression)
+			if (err != nil) != tt.wantCompileErr {
+				t.Fatalf("exprToCelProgram() error = %v, wantCompileErr %v", err, tt.wantCompileErr)
+			}
+			if err != nil {
+				return
+			}
+			gotString, err := evalString(gotProg, activation)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("evalString() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if gotString != tt.wantString {
+				t.Errorf("evalString() = %v, want %v", gotString, tt.wantString)
+			}
+		})
+	}
+}
+
+func TestEOSIOLib_declarations(t *testing.T) {
+	// the library is available with all the declaration sets
+	tests := []struct {
+		name         string
+		expression   string
+		declarations cel.EnvOption
+	}{
+		{"default", "has_auth(auth, 'ultra.nft.ft@backend') && lookup(db_ops, '0.table_name', '') == 'next.factory'", Declarations},
+		{"actions", "has_auth(auth, 'ultra.nft.ft@backend') && asset_amount(lookup(data, 'quantity', '0 EOS')) > 0", ActionDeclarations},
+		{"tables", "name_to_uint(table.name) > 0u && lookup(table, 'new.owner', '') != ''", TableDeclarations},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if _, err := exprToTypedCelProgram(tt.expression, tt.declarations, decls.Bool); err != nil {
+				t.Errorf("exprToTypedCelProgram() error: %v", err)
+			}
+		})
+	}
+}
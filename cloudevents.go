@@ -0,0 +1,78 @@
+package dkafka
+
+import (
+	"encoding/json"
+	"fmt"
+	"strings"
+
+	"github.com/confluentinc/confluent-kafka-go/kafka"
+)
+
+const (
+	// BinaryCloudEventsMode writes the CloudEvents attributes as ce_* headers
+	// and the data as the message value
+	BinaryCloudEventsMode = "binary"
+	// StructuredCloudEventsMode writes the CloudEvents attributes and the data
+	// in a JSON envelope as the message value
+	StructuredCloudEventsMode = "structured"
+)
+
+const (
+	cloudEventsHeaderPrefix = "ce_"
+	cloudEventsContentType  = "application/cloudevents+json"
+)
+
+func ValidateCloudEventsMode(mode string) error {
+	switch mode {
+	case "", BinaryCloudEventsMode, StructuredCloudEventsMode:
+		return nil
+	default:
+		return fmt.Errorf("unsupported cloudevents mode: '%s'", mode)
+	}
+}
+
+// toStructuredCloudEvent moves the ce_* headers and the value of a binary mode
+// message into an application/cloudevents+json envelope. The JSON data is
+// embedded as is, any other data (avro, protobuf or schema registry framed
+// JSON) is base64 encoded in data_base64. The message key is the subject of
+// the event and the other headers, like the cursors, are kept.
+func toStructuredCloudEvent(msg *kafka.Message) error {
+	event := make(map[string]interface{})
+	headers := make([]kafka.Header, 0, len(msg.Headers))
+	for _, header := range msg.Headers {
+		switch {
+		case strings.HasPrefix(header.Key, cloudEventsHeaderPrefix):
+			event[strings.TrimPrefix(header.Key, cloudEventsHeaderPrefix)] = string(header.Value)
+		case header.Key == "content-type":
+			// replaced by the structured content type
+		default:
+			headers = append(headers, header)
+		}
+	}
+	if _, ok := event["subject"]; !ok && len(msg.Key) > 0 {
+		event["subject"] = string(msg.Key)
+	}
+	if len(msg.Value) > 0 {
+		contentType, _ := event["datacontenttype"].(string)
+		if isJSONContentType(contentType) && json.Valid(msg.Value) {
+			event["data"] = json.RawMessage(msg.Value)
+		} else {
+			event["data_base64"] = msg.Value
+		}
+	}
+	value, err := json.Marshal(event)
+	if err != nil {
+		return fmt.Errorf("cannot marshal structured cloudevent: %w", err)
+	}
+	msg.Headers = append(headers, kafka.Header{
+		Key:   "content-type",
+		Value: []byte(cloudEventsContentType),
+	})
+	msg.Value = value
+	return nil
+}
+
+func isJSONContentType(contentType string) bool {
+	mediaType := strings.TrimSpace(strings.SplitN(contentType, ";", 2)[0])
+	return mediaType == "application/json" || strings.HasSuffix(mediaType, "+json")
+}
@@ -0,0 +1,111 @@
+package dkafka
+
+import (
+	"encoding/json"
+	"testing"
+
+	"github.com/confluentinc/confluent-kafka-go/kafka"
+)
+
+func TestToStructuredCloudEvent(t *testing.T) {
+	tests := []struct {
+		name        string
+		contentType string
+		value       []byte
+		wantData    bool
+		wantBase64  interface{}
+	}{
+		{
+			name:        "json",
+			contentType: "application/json",
+			value:       []byte(`{"account":"eosio.token"}`),
+			wantData:    true,
+		},
+		{
+			name:        "avro",
+			contentType: "application/avro",
+			value:       []byte{0, 0, 0, 0, 42, 2},
+			wantBase64:  "AAAAACoC",
+		},
+		{
+			name:        "framed-json",
+			contentType: "application/json",
+			value:       append([]byte{0, 0, 0, 0, 42}, []byte(`{}`)...),
+			wantBase64:  "AAAAACp7fQ==",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			msg := &kafka.Message{
+				Key: []byte("eosio.token"),
+				Headers: []kafka.Header{
+					{Key: "ce_source", Value: []byte("dkafka")},
+					{Key: "ce_specversion", Value: []byte("1.0")},
+					{Key: "content-type", Value: []byte(tt.contentType)},
+					{Key: "ce_datacontenttype", Value: []byte(tt.contentType)},
+					{Key: "ce_dataschema", Value: []byte("mock://test/schemas/ids/42")},
+					{Key: "ce_id", Value: []byte("id-1")},
+					{Key: "ce_type", Value: []byte("TokenTransfer")},
+					{Key: "ce_time", Value: []byte("2022-01-01T00:00:00Z")},
+					{Key: "ce_blkstep", Value: []byte("NEW")},
+					{Key: CursorHeaderKey, Value: []byte("cursor")},
+				},
+				Value: tt.value,
+			}
+			if err := encodeCloudEvent(StructuredCloudEventsMode, msg); err != nil {
+				t.Fatalf("encodeCloudEvent() error: %v", err)
+			}
+			if got := findHeader("content-type", msg.Headers); got != cloudEventsContentType {
+				t.Errorf("content-type = %s, want %s", got, cloudEventsContentType)
+			}
+			if got := findHeader(CursorHeaderKey, msg.Headers); got != "cursor" {
+				t.Errorf("%s = %s, want cursor", CursorHeaderKey, got)
+			}
+			if got := findHeader("ce_id", msg.Headers); got != "" {
+				t.Errorf("ce_id header must be moved in the value: %s", got)
+			}
+			event := make(map[string]interface{})
+			if err := json.Unmarshal(msg.Value, &event); err != nil {
+				t.Fatalf("json.Unmarshal() error: %v", err)
+			}
+			for attribute, want := range map[string]string{
+				"id":              "id-1",
+				"source":          "dkafka",
+				"specversion":     "1.0",
+				"type":            "TokenTransfer",
+				"time":            "2022-01-01T00:00:00Z",
+				"subject":         "eosio.token",
+				"datacontenttype": tt.contentType,
+				"dataschema":      "mock://test/schemas/ids/42",
+				"blkstep":         "NEW",
+			} {
+				if event[attribute] != want {
+					t.Errorf("%s = %v, want %s", attribute, event[attribute], want)
+				}
+			}
+			if tt.wantData {
+				if got, _ := json.Marshal(event["data"]); string(got) != string(tt.value) {
+					t.Errorf("data = %s, want %s", got, tt.value)
+				}
+			} else if _, ok := event["data"]; ok {
+				t.Errorf("unexpected data: %v", event["data"])
+			}
+			if event["data_base64"] != tt.wantBase64 {
+				t.Errorf("data_base64 = %v, want %v", event["data_base64"], tt.wantBase64)
+			}
+		})
+	}
+}
+
+func TestEncodeCloudEvent_binary(t *testing.T) {
+	msg := &kafka.Message{
+		Headers: []kafka.Header{{Key: "ce_id", Value: []byte("id-1")}},
+		Value:   []byte(`{}`),
+	}
+	if err := encodeCloudEvent(BinaryCloudEventsMode, msg); err != nil {
+		t.Fatalf("encodeCloudEvent() error: %v", err)
+	}
+	if string(msg.Value) != `{}` || findHeader("ce_id", msg.Headers) != "id-1" {
+		t.Errorf("binary mode must not change the message: %v", msg)
+	}
+}
@@ -0,0 +1,224 @@
+package main
+
+import (
+	"fmt"
+
+	"github.com/dfuse-io/dkafka"
+	"github.com/riferrei/srclient"
+	"github.com/spf13/cobra"
+	"github.com/spf13/viper"
+	"go.uber.org/zap"
+)
+
+var AbiCmd = &cobra.Command{
+	Use:   "abi",
+	Short: "ABI management commands",
+	Long:  "",
+}
+
+var AbiCacheCmd = &cobra.Command{
+	Use:   "cache",
+	Short: "Manage the on-disk ABI cache",
+	Long:  "Manage the on-disk ABI cache populated by the cdc and publish commands when --abi-cache-dir is set",
+}
+
+var AbiCacheLsCmd = &cobra.Command{
+	Use:   "ls [account...]",
+	Short: "List the cached ABIs",
+	Long:  "List the cached ABI versions per account. If no account is given all the accounts are listed.",
+	RunE:  abiCacheLs,
+}
+
+var AbiCachePruneCmd = &cobra.Command{
+	Use:   "prune [account...]",
+	Short: "Remove old cached ABIs",
+	Long: `Remove the cached ABI versions activated before {before-block-num} while keeping
+the {keep} most recent versions of each account. If no account is given all the
+accounts are pruned.`,
+	RunE: abiCachePrune,
+}
+
+var AbiDiffCmd = &cobra.Command{
+	Use:   "diff [-n namespace] [--compatibility level] <account>:<old.abi> <account>:<new.abi>",
+	Short: "Compare two versions of an ABI and check the compatibility of their schemas",
+	Long: `Compare two versions of an ABI. List the added, removed and changed structs,
+fields, tables, actions and variants. Then generate the tables and actions
+avro schemas of both versions and report for each subject if the change is
+BACKWARD, FORWARD or FULL compatible under the Avro resolution rules.
+The command fails if a subject does not satisfy the {compatibility} level.`,
+	Args: cobra.ExactArgs(2),
+	RunE: abiDiff,
+}
+
+var diffCompatibilityTypes = NewEnumFlag(
+	srclient.Forward.String(), // Default compatibility
+	srclient.None.String(),
+	srclient.Full.String(),
+	srclient.Backward.String(),
+	srclient.BackwardTransitive.String(),
+	srclient.ForwardTransitive.String(),
+)
+
+var diffSubjectNameStrategies = NewEnumFlag(
+	dkafka.RecordNameStrategy, // Default strategy
+	dkafka.TopicNameStrategy,
+	dkafka.TopicRecordNameStrategy,
+)
+
+func init() {
+	RootCmd.AddCommand(AbiCmd)
+	AbiCmd.AddCommand(AbiDiffCmd)
+	AbiDiffCmd.Flags().StringP("namespace", "n", "", "namespace of the schema(s). Default: account name")
+	AbiDiffCmd.Flags().Var(diffCompatibilityTypes, "compatibility", diffCompatibilityTypes.Help("Compatibility level required for each subject."))
+	AbiDiffCmd.Flags().Var(diffSubjectNameStrategies, "subject-name-strategy", diffSubjectNameStrategies.Help(`Schema registry subject naming of the schemas, the same as the cdc command.
+The topic and topic-record strategies use the {kafka-topic}.`))
+
+	AbiCmd.AddCommand(AbiCacheCmd)
+	AbiCacheCmd.PersistentFlags().String("abi-cache-dir", "", "directory of the ABI cache")
+
+	AbiCacheCmd.AddCommand(AbiCacheLsCmd)
+	AbiCacheCmd.AddCommand(AbiCachePruneCmd)
+	AbiCachePruneCmd.Flags().Int("keep", 1, "number of most recent ABI versions to keep per account")
+	AbiCachePruneCmd.Flags().Uint32("before-block-num", 0, "only remove ABI versions activated before this block number (0 for no limit)")
+}
+
+func openAbiCache(args []string) (cache *dkafka.AbiCache, accounts []string, err error) {
+	dir := viper.GetString("abi-cache-global-abi-cache-dir")
+	if dir == "" {
+		return nil, nil, fmt.Errorf("missing --abi-cache-dir")
+	}
+	if cache, err = dkafka.NewAbiCache(dir); err != nil {
+		return
+	}
+	accounts = args
+	if len(accounts) == 0 {
+		accounts, err = cache.Accounts()
+	}
+	return
+}
+
+func abiCacheLs(cmd *cobra.Command, args []string) error {
+	SetupLogger()
+	cache, accounts, err := openAbiCache(args)
+	if err != nil {
+		return err
+	}
+	cmd.SilenceUsage = true
+	for _, account := range accounts {
+		blockNums, err := cache.BlockNums(account)
+		if err != nil {
+			return err
+		}
+		for _, blockNum := range blockNums {
+			entry, err := cache.Get(account, blockNum)
+			if err != nil {
+				return err
+			}
+			fmt.Printf("account: %s, abi_block_num: %d, checked_block_num: %d, tables: %d, actions: %d\n",
+				entry.Account, entry.AbiBlockNum, entry.CheckedBlockNum, len(entry.ABI.Tables), len(entry.ABI.Actions))
+		}
+	}
+	return nil
+}
+
+func abiCachePrune(cmd *cobra.Command, args []string) error {
+	SetupLogger()
+	cache, accounts, err := openAbiCache(args)
+	if err != nil {
+		return err
+	}
+	cmd.SilenceUsage = true
+	keep := viper.GetInt("abi-cache-prune-cmd-keep")
+	before := viper.GetUint32("abi-cache-prune-cmd-before-block-num")
+	for _, account := range accounts {
+		removed, err := cache.Prune(account, keep, before)
+		if err != nil {
+			return err
+		}
+		zlog.Info("abi cache pruned", zap.String("account", account), zap.Uint32s("abi_block_nums", removed))
+		for _, blockNum := range removed {
+			fmt.Printf("removed account: %s, abi_block_num: %d\n", account, blockNum)
+		}
+	}
+	return nil
+}
+
+func loadAbiSpec(spec string) (*dkafka.ABI, error) {
+	account, abiFile, err := dkafka.ParseABIFileSpec(spec)
+	if err != nil {
+		return nil, err
+	}
+	return dkafka.LoadABIFile(account, abiFile)
+}
+
+func printDiffEntries(kind string, entries dkafka.DiffEntries) {
+	for _, name := range entries.Added {
+		fmt.Printf("+ %s: %s\n", kind, name)
+	}
+	for _, name := range entries.Removed {
+		fmt.Printf("- %s: %s\n", kind, name)
+	}
+	for _, name := range entries.Changed {
+		fmt.Printf("~ %s: %s\n", kind, name)
+	}
+}
+
+func abiDiff(cmd *cobra.Command, args []string) error {
+	SetupLogger()
+	previous, err := loadAbiSpec(args[0])
+	if err != nil {
+		return err
+	}
+	next, err := loadAbiSpec(args[1])
+	if err != nil {
+		return err
+	}
+	if previous.Account != next.Account {
+		return fmt.Errorf("cannot compare ABIs of different accounts: %s and %s", previous.Account, next.Account)
+	}
+	cmd.SilenceUsage = true
+	required := srclient.CompatibilityLevel(viper.GetString("abi-diff-cmd-compatibility"))
+
+	diff := dkafka.DiffABI(previous.ABI, next.ABI)
+	if diff.IsEmpty() {
+		fmt.Println("no ABI change")
+	}
+	printDiffEntries("struct", diff.Structs)
+	printDiffEntries("field", diff.Fields)
+	printDiffEntries("table", diff.Tables)
+	printDiffEntries("action", diff.Actions)
+	printDiffEntries("variant", diff.Variants)
+
+	subjectName, err := dkafka.NewSubjectNameStrategy(viper.GetString("abi-diff-cmd-subject-name-strategy"), viper.GetString("global-kafka-topic"))
+	if err != nil {
+		return err
+	}
+	subjects, err := dkafka.CheckSchemasCompatibility(previous, next, viper.GetString("abi-diff-cmd-namespace"), subjectName)
+	if err != nil {
+		return err
+	}
+	var failures int
+	for _, subject := range subjects {
+		status := "OK"
+		if !subject.Satisfies(required) {
+			status = "FAIL"
+			failures++
+		}
+		switch subject.Status {
+		case dkafka.SubjectAdded, dkafka.SubjectRemoved:
+			fmt.Printf("%s subject: %s, %s: %s, %s\n", status, subject.Subject, subject.Kind, subject.Name, subject.Status)
+		default:
+			fmt.Printf("%s subject: %s, %s: %s, %s, compatibility: %s\n", status, subject.Subject, subject.Kind, subject.Name, subject.Status, subject.Compatibility.Level())
+			if err := subject.Compatibility.BackwardErr; err != nil {
+				fmt.Printf("    not backward: %v\n", err)
+			}
+			if err := subject.Compatibility.ForwardErr; err != nil {
+				fmt.Printf("    not forward: %v\n", err)
+			}
+		}
+	}
+	if failures > 0 {
+		return fmt.Errorf("%d subject(s) are not %s compatible", failures, required)
+	}
+	return nil
+}
@@ -1,7 +1,6 @@
 package main
 
 import (
-	"encoding/json"
 	"fmt"
 	"os"
 	"path/filepath"
@@ -16,7 +15,7 @@ import (
 	"go.uber.org/zap"
 )
 
-var codecTypes = NewEnumFlag(dkafka.JsonCodec, dkafka.AvroCodec)
+var codecTypes = NewEnumFlag(dkafka.JsonCodec, dkafka.AvroCodec, dkafka.AvroJsonCodec, dkafka.ProtobufCodec, dkafka.JsonSchemaCodec)
 var compatibilityTypes = NewEnumFlag(
 	srclient.Forward.String(), // Default compatibility
 	srclient.None.String(),
@@ -26,11 +25,44 @@ var compatibilityTypes = NewEnumFlag(
 	srclient.ForwardTransitive.String(),
 )
 
+var subjectNameStrategies = NewEnumFlag(
+	dkafka.RecordNameStrategy, // Default strategy
+	dkafka.TopicNameStrategy,
+	dkafka.TopicRecordNameStrategy,
+)
+
+var schemaFormats = NewEnumFlag(
+	dkafka.AvroSchemaFormat, // Default format
+	dkafka.JSONSchemaFormat,
+	dkafka.ProtobufSchemaFormat,
+)
+
+var rawGranularities = NewEnumFlag(
+	dkafka.TransactionRawGranularity, // Default granularity
+	dkafka.BlockRawGranularity,
+)
+
+var undecodableDBOpPolicies = NewEnumFlag(
+	dkafka.FailUndecodableDBOp, // Default policy
+	dkafka.SkipUndecodableDBOp,
+	dkafka.RawUndecodableDBOp,
+)
+
 type GenOptions struct {
-	namespace string
-	version   string
-	outputDir string
-	abiSpec   *dkafka.ABI
+	generator   dkafka.MessageSchemaGenerator
+	format      string
+	outputDir   string
+	abiSpec     *dkafka.ABI
+	subjectName dkafka.SubjectNameStrategy
+	deployment  *schemaDeployment
+}
+
+// schemaDeployment registers or checks the generated schemas against the
+// schema registry
+type schemaDeployment struct {
+	deployer *dkafka.SchemaDeployer
+	check    bool
+	failures int
 }
 
 var CdCCmd = &cobra.Command{
@@ -68,15 +100,31 @@ Produces one message per transaction in a block.`,
 	RunE: cdcOnTransactions,
 }
 
+var CdCRawCmd = &cobra.Command{
+	Use:   dkafka.RAW_CDC_TYPE,
+	Short: "Change Data Capture on the raw firehose transaction traces",
+	Long: `Change Data Capture on the raw firehose transaction traces.
+Produces the protobuf encoded firehose TransactionTrace of each transaction, or
+the Block with its transaction traces, without any ABI decoding. The protobuf
+definition is registered in the schema registry and --codec defaults to protobuf.
+The transactions can be selected with --dfuse-firehose-include-expr.`,
+	Args: cobra.ExactArgs(0),
+	RunE: cdcOnRaw,
+}
+
 var CdCSchemasCmd = &cobra.Command{
-	Use:   "schemas [-n namespace] [-V version] [-o output-dir] abi-file-def",
-	Short: "Generate all tables and actions messages avro schemas from ABI file definition",
-	Long: `Generate all tables and actions messages avro schemas from ABI file definition. The ABI file argument (abi-file-def)
+	Use:   "schemas [-n namespace] [-V version] [-o output-dir] [--format format] [--register|--check] abi-file-def",
+	Short: "Generate all tables and actions messages schemas from ABI file definition",
+	Long: `Generate all tables and actions messages schemas from ABI file definition. The ABI file argument (abi-file-def)
 must be in this format:
 '{account}:{path/to/filename}' (ex: 'eosio.token:/tmp/eosio_token.abi').
 The schema type (CamelCase) is for: 
 - table: <TableName>Notification
-- action: <ActionName>Notification`,
+- action: <ActionName>Notification
+The schemas get the namespace, version and subject they have at runtime with the
+same cdc flags. --register pushes them to the schema registry with the configured
+compatibility and --check prints the compatibility verdict of each subject without
+registering anything.`,
 	Args:      cobra.ExactArgs(1),
 	ValidArgs: []string{"abi-file-path"},
 	Run:       generateAllAvroSchema,
@@ -114,27 +162,75 @@ start streaming from this block number (if negative, relative to HEAD)`)
 
 	CdCCmd.PersistentFlags().Duration("delay-between-commits", time.Second*10, "no commits to kafka blow this delay, except un shutdown")
 	CdCCmd.PersistentFlags().String("event-source", "", "custom value for produced cloudevent source. If not specified then the host name will be used.")
+	CdCCmd.PersistentFlags().Var(cloudEventsModes, "cloudevents-mode", cloudEventsModes.Help(`CloudEvents content mode: 'binary' writes the attributes as ce_* headers and the data as value,
+'structured' writes them in an application/cloudevents+json value.`))
 
 	CdCCmd.PersistentFlags().Bool("executed", false, `Specify publish messages based only on executed actions => modify the state of the blockchain.
 This remove the error messages`)
 	CdCCmd.PersistentFlags().Bool("irreversible", false, "Specify publish messages based only on irreversible actions")
 	CdCCmd.PersistentFlags().Bool("force", false, "Will force the usage of the blocknumber provided instead of the saved cursor.")
 
-	CdCCmd.PersistentFlags().Var(codecTypes, "codec", codecTypes.Help("Specify the codec to use to encode the messages."))
+	CdCCmd.PersistentFlags().Var(codecTypes, "codec", codecTypes.Help(`Specify the codec to use to encode the messages. avro-json writes the avro JSON encoding
+of the registered avro schemas and is the default of --dry-run, where the schemas are registered in memory.`))
 	CdCCmd.PersistentFlags().String("schema-registry-url", "http://localhost:8081", "Schema registry url whose schemas are pushed to")
+	CdCCmd.PersistentFlags().String("schema-registry-username", "", "Schema registry basic authentication username")
+	CdCCmd.PersistentFlags().String("schema-registry-password", "", "Schema registry basic authentication password, prefer the DKAFKA_CDC_CMD_SCHEMA_REGISTRY_PASSWORD environment variable")
+	CdCCmd.PersistentFlags().String("schema-registry-bearer-token", "", "Schema registry bearer token, exclusive with the basic authentication, prefer the DKAFKA_CDC_CMD_SCHEMA_REGISTRY_BEARER_TOKEN environment variable")
+	CdCCmd.PersistentFlags().String("schema-registry-ca-file", "", "PEM file of the certificate authorities trusted, in addition to the system ones, to verify the schema registry certificate")
+	CdCCmd.PersistentFlags().String("schema-registry-cert-file", "", "PEM client certificate file for the schema registry mutual TLS authentication, requires --schema-registry-key-file")
+	CdCCmd.PersistentFlags().String("schema-registry-key-file", "", "PEM client key file for the schema registry mutual TLS authentication, requires --schema-registry-cert-file")
 	CdCCmd.PersistentFlags().Var(compatibilityTypes, "compatibility", compatibilityTypes.Help("Specify the compatibility mode for the schema registry subjects."))
+	CdCCmd.PersistentFlags().Var(subjectNameStrategies, "subject-name-strategy", subjectNameStrategies.Help(`Schema registry subject naming of the schemas.
+record is <namespace>.<record-name>, topic is <kafka-topic>-value and topic-record is <kafka-topic>-<namespace>.<record-name>.
+The topic strategy registers all the record types in the same subject and requires the NONE compatibility.`))
+	CdCCmd.PersistentFlags().Bool("schema-registry-read-only", false, `never register schemas nor change the subjects compatibility. The generated schemas must
+match, by fingerprint of their canonical form, a version registered under their subject beforehand`)
+	CdCCmd.PersistentFlags().Bool("schema-references", false, `register the shared records (io.dkafka, eosio and ecc namespaces) once under their own subject
+and reference them from the table and action schemas instead of inlining them`)
 
 	CdCCmd.PersistentFlags().StringP("namespace", "n", "", "namespace of the schema(s). Default: account name")
 	CdCCmd.PersistentFlags().Uint("major-version", 0, "Optional but strongly recommended if --version of the schema(s) is not used. It is used in conjunction with the ABI Block Number to version the schema in semver form: [Major].[ABIBlockNumber].0")
 	CdCCmd.PersistentFlags().StringP("version", "V", "", "Optional but strongly recommended version of the schema(s) in a semver form: 1.2.3.")
+	CdCCmd.PersistentFlags().StringSlice("schema-mapping", []string{}, `repeatable, lossless schema mapping of a built-in type in this format: '{type}={mapping}'.
+- uint64={long|decimal|fixed|string}: long (default) overflows above 2^63, decimal is a decimal(20,0),
+  fixed is the 8 bytes big-endian value and string its base 10 representation.
+- time_point={millis|micros}: millis (default) drops the microseconds, micros uses timestamp-micros.
+- asset={decimal|symbol}: decimal (default) is a decimal(32,8) amount, symbol formats the amount
+  as a string with the precision of the asset symbol.
+Changing the mapping of existing subjects produces incompatible schemas.
+Example: --schema-mapping=uint64=decimal,time_point=micros,asset=symbol`)
+	CdCCmd.PersistentFlags().String("variant-mapping-file", "", `JSON file of the ordered variant mapping rules in this format:
+'[{"match": "{variant name or pattern}", "strategy": "{union|tagged|json|catch-all}"}]'.
+- union (default): avro union of the variant types.
+- tagged: {type, value} record where type is the ABI type name of the value.
+- json: JSON string of the [type, value] variant.
+- catch-all: union of all the avro primitive types and an array of them.
+The first matching rule wins.`)
 	CdCCmd.PersistentFlags().StringSlice("local-abi-files", []string{}, `repeatable, ABI file definition in this format:
 '{account}:{path/to/filename}[:{block-number}]' (ex: 'eosio.token:/tmp/eosio_token.abi[:3]').
 ABIs are used to decode DB ops. Provided ABIs have highest priority and
-will never be fetched or updated. Block number, being the default value: 0, will be used for versioning in the ABI schema.`)
+will never be fetched or updated. Block number, being the default value: 0, will be used for versioning in the ABI schema.
+An account can have several ABI files, each one with its own activation block number
+(ex: 'eosio.token:/tmp/eosio_token-v1.abi,eosio.token:/tmp/eosio_token-v2.abi:1000').
+The first ABI is also used for the blocks before its activation. Two ABI files
+activated at the same block for the same account are rejected.`)
 	CdCCmd.PersistentFlags().String("abicodec-grpc-addr", "", "if set, will connect to this endpoint to fetch contract ABIs")
+	CdCCmd.PersistentFlags().String("abi-source", "", `if set, remote source of the contract ABIs in the '{abicodec|nodeos}:{address}' format.
+'abicodec:{grpc-address}' is equivalent to --abicodec-grpc-addr. 'nodeos:{url}' fetches the ABIs
+from the chain HTTP API of a nodeos node (ex: 'nodeos:http://localhost:8888'). Nodeos only knows
+the ABI active at its head block so it is considered active from the block it is first needed at.`)
+	CdCCmd.PersistentFlags().Duration("abi-source-timeout", 10*time.Second, "timeout of each call to the ABI source")
+	CdCCmd.PersistentFlags().Int("abi-source-retries", 3, "number of retries of a failed call to the ABI source")
+	CdCCmd.PersistentFlags().String("abi-cache-dir", "", `if set, ABIs are saved in this directory keyed by account and ABI block number.
+Cached ABIs are used on restart and when the abicodec endpoint is not reachable.
+Use the 'dkafka abi cache' commands to manage it.`)
 
 	CdCCmd.AddCommand(CdCActionsCmd)
 	CdCActionsCmd.Flags().String("actions-expr", "", "A JSON Object that associate the a name of an action to CEL expression for the message key extraction.")
+	CdCActionsCmd.Flags().Bool("decode-action-raw-data", false, `Decode the action parameters from the action raw data with the ABI instead of using
+the firehose JSON representation. Built-in types like asset, symbol, public_key,
+signature and time points get the same typed representation as the tables instead
+of strings. Changing this option on existing subjects produces incompatible schemas.`)
 	CdCActionsCmd.Flags().Bool("skip-dbops", false, "Will skip the generation of the DbOps array and generate an empty array. This is useful when you only want to capture the action and not the DbOps and when there is too many DbOps.")
 
 	CdCCmd.AddCommand(CdCTablesCmd)
@@ -149,12 +245,34 @@ a subset of tables. You can also specify key mapping with
 the wildcard.
 Example: --table-name=factory.a:k,token.a:k,*:s+k`)
 
+	CdCTablesCmd.Flags().StringArray("table-expr", []string{}, `CEL include filter and key expression of a table, evaluated on each DB operation
+of the table(s) selected by --table-name. The format is {<table-name>|*}={"filter":"<expr>","key":"<expr>"},
+both expressions are optional, the filter must return a bool and the key a string, it replaces
+the key extractor of the table. The expressions can use db_op and table: table.name, table.old and table.new
+are the decoded rows. table.new is missing on a REMOVE and table.old on an INSERT, reading a missing row fails: guard
+it with has(table.new). '*' applies to the tables without their own expression. Repeat the flag for several tables.
+The expressions are not evaluated on the undecoded DB operations of the raw --on-undecodable-db-op policy.
+Example: --table-expr='factory.a={"filter":"db_op.operation!=3","key":"string(table.new.id)"}'`)
 	CdCTablesCmd.Flags().StringSlice("inline-source", []string{}, `smart contract name(s) where inline action can DML your smart contract tables.
 Example: --inline-source=eosio.token,eosio.nft,ft`)
+	CdCTablesCmd.Flags().Var(undecodableDBOpPolicies, "on-undecodable-db-op", undecodableDBOpPolicies.Help(`Policy applied when a DB operation cannot be decoded with the ABI.
+fail stops the process, skip drops the operation and raw emits an UndecodedTableNotification
+with only the old_data/new_data bytes and the decoding error in the ce_dkafkaerror header.`))
 	CdCCmd.AddCommand(CdCSchemasCmd)
-	CdCSchemasCmd.Flags().StringP("output-dir", "o", "./", `Optional output directory for the avro schema. The file name pattern is
-	the <account>-<schema-type>.avsc in snake-case.`)
+	CdCSchemasCmd.Flags().StringP("output-dir", "o", "./", `Optional output directory for the schema. The file name pattern is
+	the <account>-<schema-type>.{avsc|schema.json|proto} in snake-case.`)
+	CdCSchemasCmd.Flags().Var(schemaFormats, "format", schemaFormats.Help(`Format of the generated schemas. jsonschema and protobuf are derived from
+the avro schemas: same records, optional fields and unions. Only avro schemas can be registered or checked`))
+	CdCSchemasCmd.Flags().Bool("decode-action-raw-data", false, "Generate the actions schemas for the 'cdc actions --decode-action-raw-data' mode")
+	CdCSchemasCmd.Flags().Bool("register", false, `Register the table, action and static schemas in the schema registry with the configured
+--compatibility, --subject-name-strategy and --schema-references`)
+	CdCSchemasCmd.Flags().Bool("check", false, `Check the table, action and static schemas against the latest version of their
+subject in the schema registry without registering them. Exits in error if one is incompatible.
+Exclusive with --register`)
 	CdCCmd.AddCommand(CdCTransactionsCmd)
+	CdCCmd.AddCommand(CdCRawCmd)
+	CdCRawCmd.Flags().Var(rawGranularities, "granularity", rawGranularities.Help(`Granularity of the raw messages: transaction produces one TransactionTrace
+message per transaction and block one Block message per block with transactions.`))
 }
 
 func cdcOnTransactions(cmd *cobra.Command, args []string) error {
@@ -165,6 +283,18 @@ func cdcOnTransactions(cmd *cobra.Command, args []string) error {
 	})
 }
 
+func cdcOnRaw(cmd *cobra.Command, args []string) error {
+	SetupLogger()
+	zlog.Debug("CDC on raw transaction traces")
+	return executeCdC(cmd, args, dkafka.RAW_CDC_TYPE, func(c *dkafka.Config, args []string) *dkafka.Config {
+		if !viper.IsSet("cdc-cmd-codec") {
+			c.Codec = dkafka.ProtobufCodec
+		}
+		c.RawGranularity = viper.GetString("cdc-raw-cmd-granularity")
+		return c
+	})
+}
+
 func cdcOnTables(cmd *cobra.Command, args []string) error {
 	SetupLogger()
 	account := args[0]
@@ -174,7 +304,9 @@ func cdcOnTables(cmd *cobra.Command, args []string) error {
 	)
 	return executeCdC(cmd, args, dkafka.TABLES_CDC_TYPE, configAccount(func(c *dkafka.Config) *dkafka.Config {
 		c.TableNames = viper.GetStringSlice("cdc-tables-cmd-table-name")
+		c.TableExpressions = viper.GetStringSlice("cdc-tables-cmd-table-expr")
 		c.InlineSources = viper.GetStringSlice("cdc-tables-cmd-inline-source")
+		c.UndecodableDBOpPolicy = viper.GetString("cdc-tables-cmd-on-undecodable-db-op")
 		return c
 	}))
 }
@@ -184,6 +316,7 @@ func cdcOnActions(cmd *cobra.Command, args []string) error {
 	return executeCdC(cmd, args, dkafka.ACTIONS_CDC_TYPE, configAccount(func(c *dkafka.Config) *dkafka.Config {
 		c.ActionExpressions = viper.GetString("cdc-actions-cmd-actions-expr")
 		c.SkipDbOps = viper.GetBool("cdc-actions-cmd-skip-dbops")
+		c.DecodeActionRawData = viper.GetBool("cdc-actions-cmd-decode-action-raw-data")
 		return c
 	}))
 }
@@ -223,11 +356,40 @@ func generateAllAvroSchema(cmd *cobra.Command, args []string) {
 
 	}
 	zlog.Info("generate static dkafka schemas")
-	if err = saveSchema(dkafka.CheckpointMessageSchema, "", opts.outputDir); err != nil {
-		zlog.Fatal("fail to saveSchema()", zap.String("schema", dkafka.CheckpointMessageSchema.Name), zap.Error(err))
+	for _, schema := range []dkafka.MessageSchema{
+		dkafka.CheckpointMessageSchema,
+		dkafka.AbiUpdatedMessageSchema,
+		dkafka.UndecodedTableNotificationMessageSchema,
+		dkafka.TransactionMessageSchema,
+	} {
+		if err = writeSchema(schema, "", opts); err != nil {
+			zlog.Fatal("fail to saveSchema()", zap.String("schema", schema.Name), zap.Error(err))
+		}
+	}
+	if opts.deployment != nil && opts.deployment.failures > 0 {
+		zlog.Fatal("schema deployment fail", zap.Int("failures", opts.deployment.failures))
+	}
+}
+
+func schemaMappingProfile() (mapping dkafka.SchemaMappingProfile, err error) {
+	mapping, err = dkafka.ParseSchemaMappingProfile(viper.GetStringSlice("cdc-cmd-schema-mapping"))
+	if err != nil {
+		return
+	}
+	if variantMappingFile := viper.GetString("cdc-cmd-variant-mapping-file"); variantMappingFile != "" {
+		mapping.Variants, err = dkafka.LoadVariantMapping(variantMappingFile)
 	}
-	if err = saveSchema(dkafka.TransactionMessageSchema, "", opts.outputDir); err != nil {
-		zlog.Fatal("fail to saveSchema()", zap.String("schema", dkafka.TransactionMessageSchema.Name), zap.Error(err))
+	return
+}
+
+func schemaRegistryAuth() dkafka.SchemaRegistryAuth {
+	return dkafka.SchemaRegistryAuth{
+		Username:    viper.GetString("cdc-cmd-schema-registry-username"),
+		Password:    viper.GetString("cdc-cmd-schema-registry-password"),
+		BearerToken: viper.GetString("cdc-cmd-schema-registry-bearer-token"),
+		CAFile:      viper.GetString("cdc-cmd-schema-registry-ca-file"),
+		CertFile:    viper.GetString("cdc-cmd-schema-registry-cert-file"),
+		KeyFile:     viper.GetString("cdc-cmd-schema-registry-key-file"),
 	}
 }
 
@@ -237,6 +399,10 @@ func executeCdC(cmd *cobra.Command, args []string,
 	if err != nil {
 		return err
 	}
+	schemaMapping, err := schemaMappingProfile()
+	if err != nil {
+		return err
+	}
 
 	conf := &dkafka.Config{
 		DfuseToken:        viper.GetString("global-dfuse-auth-token"),
@@ -266,20 +432,34 @@ func executeCdC(cmd *cobra.Command, args []string,
 		Capture:       viper.GetBool("cdc-cmd-capture"),
 		Force:         viper.GetBool("cdc-cmd-force"),
 
-		EventSource: viper.GetString("cdc-cmd-event-source"),
+		EventSource:     viper.GetString("cdc-cmd-event-source"),
+		CloudEventsMode: viper.GetString("cdc-cmd-cloudevents-mode"),
 
 		CdCType:      cdcType,
 		Irreversible: viper.GetBool("cdc-cmd-irreversible"),
 		Executed:     viper.GetBool("cdc-cmd-executed"),
 
-		Codec:              viper.GetString("cdc-cmd-codec"),
-		SchemaRegistryURL:  viper.GetString("cdc-cmd-schema-registry-url"),
-		SchemaNamespace:    viper.GetString("cdc-cmd-namespace"),
-		SchemaMajorVersion: viper.GetUint("cdc-cmd-major-version"),
-		SchemaVersion:      viper.GetString("cdc-cmd-version"),
-		Compatibility:      viper.GetString("cdc-cmd-compatibility"),
-		LocalABIFiles:      localABIFiles,
-		ABICodecGRPCAddr:   viper.GetString("cdc-cmd-abicodec-grpc-addr"),
+		Codec:                  viper.GetString("cdc-cmd-codec"),
+		SchemaRegistryURL:      viper.GetString("cdc-cmd-schema-registry-url"),
+		SchemaRegistryAuth:     schemaRegistryAuth(),
+		SchemaNamespace:        viper.GetString("cdc-cmd-namespace"),
+		SchemaMajorVersion:     viper.GetUint("cdc-cmd-major-version"),
+		SchemaVersion:          viper.GetString("cdc-cmd-version"),
+		Compatibility:          viper.GetString("cdc-cmd-compatibility"),
+		SchemaReferences:       viper.GetBool("cdc-cmd-schema-references"),
+		SubjectNameStrategy:    viper.GetString("cdc-cmd-subject-name-strategy"),
+		SchemaRegistryReadOnly: viper.GetBool("cdc-cmd-schema-registry-read-only"),
+		LocalABIFiles:          localABIFiles,
+		ABICodecGRPCAddr:       viper.GetString("cdc-cmd-abicodec-grpc-addr"),
+		AbiSource:              viper.GetString("cdc-cmd-abi-source"),
+		AbiSourceTimeout:       viper.GetDuration("cdc-cmd-abi-source-timeout"),
+		AbiSourceRetries:       viper.GetInt("cdc-cmd-abi-source-retries"),
+		AbiCacheDir:            viper.GetString("cdc-cmd-abi-cache-dir"),
+		SchemaMapping:          schemaMapping,
+	}
+	if conf.DryRun && !viper.IsSet("cdc-cmd-codec") {
+		// the dry run prints the avro JSON encoding of the registered schemas
+		conf.Codec = dkafka.AvroJsonCodec
 	}
 	conf = f(conf, args)
 	cmd.SilenceUsage = true
@@ -329,55 +509,140 @@ func genOptions(cmd *cobra.Command, args []string) (opts GenOptions, err error)
 		return
 	}
 
+	mapping, err := schemaMappingProfile()
+	if err != nil {
+		return
+	}
+
+	subjectName, err := dkafka.NewSubjectNameStrategy(viper.GetString("cdc-cmd-subject-name-strategy"), viper.GetString("global-kafka-topic"))
+	if err != nil {
+		return
+	}
+
+	format := viper.GetString("cdc-schemas-cmd-format")
+	deployment, err := newSchemaDeployment()
+	if err != nil {
+		return
+	}
+	if deployment != nil && format != dkafka.AvroSchemaFormat {
+		err = fmt.Errorf("only avro schemas can be registered or checked, got format: %s", format)
+		return
+	}
+
 	return GenOptions{
-		namespace: namespace,
-		version:   version,
-		outputDir: outputDir,
-		abiSpec:   abiSpec,
+		generator: dkafka.MessageSchemaGenerator{
+			Namespace:       namespace,
+			MajorVersion:    viper.GetUint("cdc-cmd-major-version"),
+			Version:         version,
+			Account:         abiSpec.Account,
+			TypedActionData: viper.GetBool("cdc-schemas-cmd-decode-action-raw-data"),
+			Mapping:         mapping,
+		},
+		format:      format,
+		outputDir:   outputDir,
+		abiSpec:     abiSpec,
+		subjectName: subjectName,
+		deployment:  deployment,
 	}, nil
 }
 
+func newSchemaDeployment() (*schemaDeployment, error) {
+	register := viper.GetBool("cdc-schemas-cmd-register")
+	check := viper.GetBool("cdc-schemas-cmd-check")
+	if !register && !check {
+		return nil, nil
+	}
+	if register && check {
+		return nil, fmt.Errorf("--register and --check are mutually exclusive")
+	}
+	deployer, err := dkafka.NewSchemaDeployer(&dkafka.Config{
+		KafkaTopic:          viper.GetString("global-kafka-topic"),
+		SchemaRegistryURL:   viper.GetString("cdc-cmd-schema-registry-url"),
+		SchemaRegistryAuth:  schemaRegistryAuth(),
+		Compatibility:       viper.GetString("cdc-cmd-compatibility"),
+		SchemaReferences:    viper.GetBool("cdc-cmd-schema-references"),
+		SubjectNameStrategy: viper.GetString("cdc-cmd-subject-name-strategy"),
+	})
+	if err != nil {
+		return nil, err
+	}
+	return &schemaDeployment{deployer: deployer, check: check}, nil
+}
+
 func doGenActionAvroSchema(name string, opts GenOptions) error {
 	zlog.Info("generate schema for:", zap.String("account", opts.abiSpec.Account), zap.String("action", name))
-	return doGenAvroSchema(name, opts, dkafka.GenerateActionSchema)
+	return doGenAvroSchema(dkafka.ACTIONS_CDC_TYPE, name, opts, dkafka.GenerateActionSchema)
 }
 
 func doGenTableAvroSchema(name string, opts GenOptions) error {
 	zlog.Info("generate schema for:", zap.String("account", opts.abiSpec.Account), zap.String("table", name))
-	return doGenAvroSchema(name, opts, dkafka.GenerateTableSchema)
+	return doGenAvroSchema(dkafka.TABLES_CDC_TYPE, name, opts, dkafka.GenerateTableSchema)
 }
 
-func doGenAvroSchema(name string, opts GenOptions, f func(dkafka.NamedSchemaGenOptions) (dkafka.MessageSchema, error)) error {
-	schema, err := f(dkafka.NamedSchemaGenOptions{
-		Name:      name,
-		Namespace: opts.namespace,
-		Version:   opts.version,
-		AbiSpec:   opts.abiSpec,
-		Domain:    opts.abiSpec.Account,
-	})
+func doGenAvroSchema(kind string, name string, opts GenOptions, f func(dkafka.NamedSchemaGenOptions) (dkafka.MessageSchema, error)) error {
+	schema, err := f(opts.generator.SchemaGenOptions(kind, name, opts.abiSpec))
 	if err != nil {
 		return fmt.Errorf("generation error: %v", err)
 	}
 	zlog.Debug("dkafka.GenerateActionSchema()", zap.Any("schema", schema), zap.Error(err))
-	err = saveSchema(schema, opts.abiSpec.Account, opts.outputDir)
+	err = writeSchema(schema, opts.abiSpec.Account, opts)
 	if err != nil {
 		return fmt.Errorf("saveSchema() error: %v", err)
 	}
 	return nil
 }
 
-func saveSchema(schema dkafka.MessageSchema, prefix string, outputDir string) error {
+// writeSchema saves the schema and prints its subject or, when deployed, its
+// registration or check verdict
+func writeSchema(schema dkafka.MessageSchema, prefix string, opts GenOptions) error {
+	filePath, err := saveSchema(schema, prefix, opts.format, opts.outputDir)
+	if err != nil {
+		return err
+	}
+	if opts.deployment == nil {
+		fmt.Printf("subject: %s, schema: %s\n", opts.subjectName(schema), filePath)
+		return nil
+	}
+	opts.deployment.deploy(schema, filePath)
+	return nil
+}
+
+func (d *schemaDeployment) deploy(schema dkafka.MessageSchema, filePath string) {
+	if d.check {
+		subject, verdict, err := d.deployer.Check(schema)
+		switch {
+		case err != nil:
+			d.failures++
+			fmt.Printf("FAIL subject: %s, schema: %s, error: %v\n", subject, filePath, err)
+		case verdict == dkafka.IncompatibleSchema:
+			d.failures++
+			fmt.Printf("FAIL subject: %s, schema: %s, verdict: %s\n", subject, filePath, verdict)
+		default:
+			fmt.Printf("OK   subject: %s, schema: %s, verdict: %s\n", subject, filePath, verdict)
+		}
+		return
+	}
+	subject, registered, err := d.deployer.Register(schema)
+	if err != nil {
+		d.failures++
+		fmt.Printf("FAIL subject: %s, schema: %s, error: %v\n", subject, filePath, err)
+		return
+	}
+	fmt.Printf("OK   subject: %s, schema: %s, id: %d\n", subject, filePath, registered.ID())
+}
+
+func saveSchema(schema dkafka.MessageSchema, prefix string, format string, outputDir string) (string, error) {
+	content, extension, err := dkafka.MarshalSchema(format, schema)
+	if err != nil {
+		return "", fmt.Errorf("cannot convert schema to %s error: %v", format, err)
+	}
 	fileName := strcase.ToKebab(fmt.Sprintf("%s%s", prefix, schema.Name))
-	fileName = fmt.Sprintf("%s.avsc", fileName)
+	fileName = fmt.Sprintf("%s%s", fileName, extension)
 	filePath := filepath.Join(outputDir, fileName)
 	zlog.Info("save schema", zap.String("path", filePath))
-	jsonString, err := json.Marshal(schema)
-	if err != nil {
-		return fmt.Errorf("cannot convert schema to json error: %v", err)
-	}
-	err = os.WriteFile(filePath, jsonString, 0664)
+	err = os.WriteFile(filePath, content, 0664)
 	if err != nil {
-		return fmt.Errorf("cannot write schema to '%s', error: %v", filePath, err)
+		return "", fmt.Errorf("cannot write schema to '%s', error: %v", filePath, err)
 	}
-	return nil
+	return filePath, nil
 }
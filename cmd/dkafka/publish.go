@@ -19,6 +19,7 @@ var PublishCmd = &cobra.Command{
 }
 
 var compressionTypes = NewEnumFlag("none", "gzip", "snappy", "lz4", "zstd")
+var cloudEventsModes = NewEnumFlag(dkafka.BinaryCloudEventsMode, dkafka.StructuredCloudEventsMode)
 
 func init() {
 	RootCmd.AddCommand(PublishCmd)
@@ -46,6 +47,8 @@ match your producers (same apply for consumers)
 	PublishCmd.Flags().Duration("delay-between-commits", time.Second*10, "no commits to kafka blow this delay, except un shutdown")
 
 	PublishCmd.Flags().String("event-source", "dkafka", "custom value for produced cloudevent source")
+	PublishCmd.Flags().Var(cloudEventsModes, "cloudevents-mode", cloudEventsModes.Help(`CloudEvents content mode: 'binary' writes the attributes as ce_* headers and the data as value,
+'structured' writes them in an application/cloudevents+json value.`))
 	PublishCmd.Flags().String("event-keys-expr", "[account]", `CEL expression defining the event keys. More then one key will result in multiple
 events being sent. Must resolve to an array of strings`)
 	PublishCmd.Flags().String("event-type-expr", "(notif?'!':'')+account+'/'+action", "CEL expression defining the event type. Must resolve to a string")
@@ -121,6 +124,10 @@ start streaming from this block number (if negative, relative to HEAD)`)
 ABIs are used to decode DB ops. Provided ABIs have highest priority and
 will never be fetched or updated`)
 	PublishCmd.Flags().String("abicodec-grpc-addr", "", "if set, will connect to this endpoint to fetch contract ABIs")
+	PublishCmd.Flags().String("abi-source", "", "if set, remote source of the contract ABIs in the '{abicodec|nodeos}:{address}' format (ex: 'nodeos:http://localhost:8888')")
+	PublishCmd.Flags().Duration("abi-source-timeout", 10*time.Second, "timeout of each call to the ABI source")
+	PublishCmd.Flags().Int("abi-source-retries", 3, "number of retries of a failed call to the ABI source")
+	PublishCmd.Flags().String("abi-cache-dir", "", "if set, ABIs are saved in this directory and used when the abicodec endpoint is not reachable")
 	PublishCmd.Flags().Bool("fail-on-undecodable-db-op", false, `If true, program will fail and exit when a db OP cannot be decoded
 (ex: missing or incompatible ABI file or invalid ABI fetched from abicodec`)
 }
@@ -155,10 +162,11 @@ func publishRunE(cmd *cobra.Command, args []string) error {
 		KafkaMessageMaxBytes:       viper.GetInt("publish-cmd-kafka-message-max-bytes"),
 		CommitMinDelay:             viper.GetDuration("publish-cmd-delay-between-commits"),
 
-		EventSource:   viper.GetString("publish-cmd-event-source"),
-		EventKeysExpr: viper.GetString("publish-cmd-event-keys-expr"),
-		EventTypeExpr: viper.GetString("publish-cmd-event-type-expr"),
-		ActionsExpr:   viper.GetString("publish-cmd-actions-expr"),
+		EventSource:     viper.GetString("publish-cmd-event-source"),
+		CloudEventsMode: viper.GetString("publish-cmd-cloudevents-mode"),
+		EventKeysExpr:   viper.GetString("publish-cmd-event-keys-expr"),
+		EventTypeExpr:   viper.GetString("publish-cmd-event-type-expr"),
+		ActionsExpr:     viper.GetString("publish-cmd-actions-expr"),
 
 		BatchMode:     viper.GetBool("publish-cmd-batch-mode"),
 		StartBlockNum: viper.GetInt64("publish-cmd-start-block-num"),
@@ -168,6 +176,10 @@ func publishRunE(cmd *cobra.Command, args []string) error {
 
 		LocalABIFiles:         localABIFiles,
 		ABICodecGRPCAddr:      viper.GetString("publish-cmd-abicodec-grpc-addr"),
+		AbiSource:             viper.GetString("publish-cmd-abi-source"),
+		AbiSourceTimeout:      viper.GetDuration("publish-cmd-abi-source-timeout"),
+		AbiSourceRetries:      viper.GetInt("publish-cmd-abi-source-retries"),
+		AbiCacheDir:           viper.GetString("publish-cmd-abi-cache-dir"),
 		FailOnUndecodableDBOP: viper.GetBool("publish-cmd-fail-on-undecodable-db-op"),
 	}
 
@@ -15,9 +15,12 @@ import (
 type CodecType = string
 
 const (
-	AvroCodec   CodecType = "avro"
-	JsonCodec   CodecType = "json"
-	schemaByIDs           = "/schemas/ids/"
+	AvroCodec       CodecType = "avro"
+	AvroJsonCodec   CodecType = "avro-json"
+	JsonCodec       CodecType = "json"
+	ProtobufCodec   CodecType = "protobuf"
+	JsonSchemaCodec CodecType = "jsonschema"
+	schemaByIDs               = "/schemas/ids/"
 )
 
 type Codec interface {
@@ -145,3 +148,63 @@ func (c KafkaAvroCodec) GetHeaders() []kafka.Header {
 		Value: []byte(u),
 	})
 }
+
+// NewKafkaAvroJsonCodec returns the codec of the avro JSON encoding of the
+// registered avro schema. The values are normalized by the binary encoding so
+// that the eos types get the same conversions as with the avro codec.
+func NewKafkaAvroJsonCodec(schemaRegistryURL string, schema *srclient.Schema, codec *goavro.Codec) Codec {
+	u, _ := url.Parse(schemaRegistryURL)
+	u, _ = u.Parse(schemaByIDs)
+	t := fmt.Sprint(u, "%d")
+	return KafkaAvroJsonCodec{
+		schemaURLTemplate: t,
+		schema: RegisteredSchema{
+			id:      uint32(schema.ID()),
+			schema:  schema.Schema(),
+			version: schema.Version(),
+			codec:   codec,
+		},
+	}
+}
+
+type KafkaAvroJsonCodec struct {
+	schemaURLTemplate string
+	schema            RegisteredSchema
+}
+
+func (c KafkaAvroJsonCodec) Marshal(buf []byte, value interface{}) ([]byte, error) {
+	zlog.Debug("marshal value to avro json", zap.Uint32("schema_id", c.schema.id))
+	avroBytes, err := c.schema.codec.BinaryFromNative(nil, value)
+	if err != nil {
+		return buf, err
+	}
+	native, _, err := c.schema.codec.NativeFromBinary(avroBytes)
+	if err != nil {
+		return buf, err
+	}
+	return c.schema.codec.TextualFromNative(buf, native)
+}
+
+func (c KafkaAvroJsonCodec) Unmarshal(buf []byte) (interface{}, error) {
+	value, _, err := c.schema.codec.NativeFromTextual(buf)
+	return value, err
+}
+
+var avroJsonKafkaHeader []kafka.Header = []kafka.Header{
+	{
+		Key:   "content-type",
+		Value: []byte("application/avro+json"),
+	},
+	{
+		Key:   "ce_datacontenttype",
+		Value: []byte("application/avro+json"),
+	},
+}
+
+func (c KafkaAvroJsonCodec) GetHeaders() []kafka.Header {
+	u := fmt.Sprintf(c.schemaURLTemplate, c.schema.id)
+	return append(avroJsonKafkaHeader[:len(avroJsonKafkaHeader):len(avroJsonKafkaHeader)], kafka.Header{
+		Key:   "ce_dataschema",
+		Value: []byte(u),
+	})
+}
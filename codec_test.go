@@ -848,3 +848,88 @@ func marshalSchema(t *testing.T, schema Schema) string {
 	}
 	return string(bytes)
 }
+
+func TestKafkaAvroJsonCodec_TableNotification(t *testing.T) {
+	abi, err := LoadABIFile("eosio.nft.ft", "testdata/eosio.nft.ft.abi")
+	if err != nil {
+		t.Fatalf("LoadABIFile() error: %v", err)
+	}
+	gc := newActionContext4Test(t)
+	registry := srclient.CreateMockSchemaRegistryClient("mock://TestKafkaAvroJsonCodec_TableNotification")
+	abiCodec := NewStreamedAbiCodec(
+		&AbiRepositoryStub{abi: abi},
+		MessageSchemaGenerator{Namespace: "test", Account: "eosio.nft.ft"}.getTableSchema,
+		registry,
+		"eosio.nft.ft",
+		"mock://TestKafkaAvroJsonCodec_TableNotification",
+		srclient.Forward,
+		WithAvroJsonCodec(),
+	)
+	codec, err := abiCodec.GetCodec(CodecId{"eosio.nft.ft", "resale.a"}, 42)
+	if err != nil {
+		t.Fatalf("GetCodec() error: %v", err)
+	}
+	avroJsonCodec := codec.(KafkaAvroJsonCodec)
+	schema, err := registry.GetSchema(int(avroJsonCodec.schema.id))
+	if err != nil {
+		t.Fatalf("GetSchema() error: %v", err)
+	}
+	if schemaType := schema.SchemaType(); schemaType != nil && *schemaType != srclient.Avro {
+		t.Errorf("registered schema type = %v, want %s", *schemaType, srclient.Avro)
+	}
+	if contentType := findHeader("ce_datacontenttype", codec.GetHeaders()); contentType != "application/avro+json" {
+		t.Errorf("ce_datacontenttype = %s, want application/avro+json", contentType)
+	}
+	if dataSchema := findHeader("ce_dataschema", codec.GetHeaders()); dataSchema == "" {
+		t.Errorf("missing ce_dataschema header")
+	}
+
+	finder, _ := buildTableKeyExtractorFinder([]string{"*:k"})
+	g := TableGenerator{
+		getExtractKey:   finder,
+		abiCodec:        abiCodec,
+		targetedAccount: "eosio.nft.ft",
+	}
+	messages, err := g.Apply(gc)
+	if err != nil {
+		t.Fatalf("Apply() error: %v", err)
+	}
+	if len(messages) != 1 {
+		t.Fatalf("Apply() expected 1 message got: %d", len(messages))
+	}
+	var document map[string]interface{}
+	if err := json.Unmarshal(messages[0].Value, &document); err != nil {
+		t.Fatalf("the avro json value is not a JSON document: %v, value: %s", err, messages[0].Value)
+	}
+	dbOp := document["db_op"].(map[string]interface{})
+	// the unions are wrapped in the avro JSON encoding
+	if tableName := dbOp["table_name"]; !reflect.DeepEqual(tableName, map[string]interface{}{"string": "resale.a"}) {
+		t.Errorf("db_op.table_name = %v, want {string: resale.a}", tableName)
+	}
+	if dbOp["old_json"] != nil {
+		t.Errorf("db_op.old_json = %v, want nil", dbOp["old_json"])
+	}
+	if _, found := dbOp["new_json"].(map[string]interface{})["test.eosio.nft.ft.tables.v0.ResaleATableOp"]; !found {
+		t.Errorf("db_op.new_json = %v, want the ResaleATableOp branch", dbOp["new_json"])
+	}
+
+	// the value is decoded with the registered schema
+	value, err := codec.Unmarshal(messages[0].Value)
+	if err != nil {
+		t.Fatalf("Unmarshal() error: %v", err)
+	}
+	textual, err := avroJsonCodec.schema.codec.TextualFromNative(nil, value)
+	if err != nil {
+		t.Fatalf("TextualFromNative() error: %v", err)
+	}
+	var got, want interface{}
+	if err := json.Unmarshal(textual, &got); err != nil {
+		t.Fatalf("json.Unmarshal() error: %v", err)
+	}
+	if err := json.Unmarshal(messages[0].Value, &want); err != nil {
+		t.Fatalf("json.Unmarshal() error: %v", err)
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("textual round trip = %s, want %s", textual, messages[0].Value)
+	}
+}
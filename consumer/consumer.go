@@ -0,0 +1,68 @@
+package consumer
+
+import (
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/confluentinc/confluent-kafka-go/kafka"
+)
+
+// Consumer reads the notifications of a dkafka topic. The checkpoints are
+// used to follow the cursors chain but are not returned.
+type Consumer struct {
+	consumer *kafka.Consumer
+	decoder  *Decoder
+	tracker  *CursorTracker
+}
+
+// NewConsumer subscribes to the topic with the kafka consumer configuration.
+func NewConsumer(config kafka.ConfigMap, topic string, decoder *Decoder) (*Consumer, error) {
+	consumer, err := kafka.NewConsumer(&config)
+	if err != nil {
+		return nil, fmt.Errorf("cannot create kafka consumer: %w", err)
+	}
+	if err := consumer.Subscribe(topic, nil); err != nil {
+		consumer.Close()
+		return nil, fmt.Errorf("cannot subscribe to topic: %s, error: %w", topic, err)
+	}
+	return &Consumer{
+		consumer: consumer,
+		decoder:  decoder,
+		tracker:  NewCursorTracker(DefaultCursorHistory),
+	}, nil
+}
+
+// Next returns the next notification with its linkage in the cursors chain or
+// nil when no notification is received before the timeout.
+func (c *Consumer) Next(timeout time.Duration) (*Notification, error) {
+	for {
+		msg, err := c.consumer.ReadMessage(timeout)
+		if err != nil {
+			var kafkaErr kafka.Error
+			if errors.As(err, &kafkaErr) && kafkaErr.Code() == kafka.ErrTimedOut {
+				return nil, nil
+			}
+			return nil, err
+		}
+		n, err := c.decoder.Decode(msg)
+		if err != nil {
+			return nil, err
+		}
+		n.Linkage = c.tracker.Track(n)
+		if n.IsCheckpoint() {
+			continue
+		}
+		return n, nil
+	}
+}
+
+// Commit commits the offset of the notification.
+func (c *Consumer) Commit(n *Notification) error {
+	_, err := c.consumer.CommitMessage(n.Message)
+	return err
+}
+
+func (c *Consumer) Close() error {
+	return c.consumer.Close()
+}
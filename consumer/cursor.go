@@ -0,0 +1,109 @@
+package consumer
+
+// Linkage is the position of a message in the cursors chain. Each message
+// holds the cursor of its block and the cursor of the previous block processed
+// by dkafka, so the chain is only complete on a topic with a single partition
+// where each block produces a message or a checkpoint.
+type Linkage int
+
+const (
+	// Unlinked is the linkage of the first message or of a message without
+	// cursor
+	Unlinked Linkage = iota
+	// Linked is the linkage of a message of the last block or of the next one
+	Linked
+	// Gap is the linkage of a message whose previous block was not received,
+	// it is missing or it did not produce any message
+	Gap
+	// Duplicate is the linkage of a message of a block already received, like
+	// the last block replayed by dkafka on restart
+	Duplicate
+)
+
+func (l Linkage) String() string {
+	switch l {
+	case Linked:
+		return "linked"
+	case Gap:
+		return "gap"
+	case Duplicate:
+		return "duplicate"
+	default:
+		return "unlinked"
+	}
+}
+
+// DefaultCursorHistory is the number of block cursors remembered to detect
+// the duplicates
+const DefaultCursorHistory = 1000
+
+// CursorTracker follows the cursors chain of the messages. The messages of a
+// block share its cursor, a replayed message of the last block is detected by
+// its id as dkafka computes the same id for the same notification. All the
+// messages of an older replayed block are duplicates.
+type CursorTracker struct {
+	last      string
+	replaying bool
+	ids       map[string]struct{}
+	seen      map[string]struct{}
+	history   []string
+	next      int
+}
+
+// NewCursorTracker returns a tracker remembering the given number of block
+// cursors.
+func NewCursorTracker(history int) *CursorTracker {
+	if history <= 0 {
+		history = DefaultCursorHistory
+	}
+	return &CursorTracker{
+		ids:     make(map[string]struct{}),
+		seen:    make(map[string]struct{}, history),
+		history: make([]string, history),
+	}
+}
+
+// Track returns the linkage of the notification and moves the chain to its
+// block.
+func (t *CursorTracker) Track(n *Notification) Linkage {
+	cursor := n.Cursor
+	if cursor == "" {
+		return Unlinked
+	}
+	if cursor == t.last {
+		if t.replaying {
+			return Duplicate
+		}
+		if _, found := t.ids[n.ID]; found && n.ID != "" {
+			return Duplicate
+		}
+		t.ids[n.ID] = struct{}{}
+		return Linked
+	}
+	t.ids = map[string]struct{}{n.ID: {}}
+	if _, found := t.seen[cursor]; found {
+		t.last = cursor
+		t.replaying = true
+		return Duplicate
+	}
+	linkage := Gap
+	switch {
+	case t.last == "":
+		linkage = Unlinked
+	case n.PreviousCursor == t.last:
+		linkage = Linked
+	}
+	t.remember(cursor)
+	t.last = cursor
+	t.replaying = false
+	return linkage
+}
+
+func (t *CursorTracker) remember(cursor string) {
+	if evicted := t.history[t.next]; evicted != "" {
+		delete(t.seen, evicted)
+	}
+	t.history[t.next] = cursor
+	t.seen[cursor] = struct{}{}
+	t.next = (t.next + 1) % len(t.history)
+}
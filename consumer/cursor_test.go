@@ -0,0 +1,100 @@
+package consumer
+
+import "testing"
+
+func TestCursorTracker_Track(t *testing.T) {
+	tests := []struct {
+		name          string
+		notifications []Notification
+		want          []Linkage
+	}{
+		{
+			name: "chain",
+			notifications: []Notification{
+				{ID: "1", Cursor: "c1", PreviousCursor: "c0"},
+				{ID: "2", Cursor: "c1", PreviousCursor: "c0"},
+				{ID: "3", Cursor: "c2", PreviousCursor: "c1"},
+				{ID: "4", Cursor: "c3", PreviousCursor: "c2"},
+			},
+			want: []Linkage{Unlinked, Linked, Linked, Linked},
+		},
+		{
+			name: "gap",
+			notifications: []Notification{
+				{ID: "1", Cursor: "c1", PreviousCursor: "c0"},
+				{ID: "3", Cursor: "c3", PreviousCursor: "c2"},
+				{ID: "4", Cursor: "c4", PreviousCursor: "c3"},
+			},
+			want: []Linkage{Unlinked, Gap, Linked},
+		},
+		{
+			name: "replayed last block",
+			notifications: []Notification{
+				{ID: "1", Cursor: "c1", PreviousCursor: "c0"},
+				{ID: "2", Cursor: "c2", PreviousCursor: "c1"},
+				{ID: "3", Cursor: "c2", PreviousCursor: "c1"},
+				{ID: "2", Cursor: "c2", PreviousCursor: "c1"},
+				{ID: "3", Cursor: "c2", PreviousCursor: "c1"},
+				{ID: "4", Cursor: "c3", PreviousCursor: "c2"},
+			},
+			want: []Linkage{Unlinked, Linked, Linked, Duplicate, Duplicate, Linked},
+		},
+		{
+			name: "replayed interrupted block",
+			notifications: []Notification{
+				{ID: "1", Cursor: "c1", PreviousCursor: "c0"},
+				{ID: "2", Cursor: "c2", PreviousCursor: "c1"},
+				{ID: "2", Cursor: "c2", PreviousCursor: "c1"},
+				{ID: "3", Cursor: "c2", PreviousCursor: "c1"},
+			},
+			want: []Linkage{Unlinked, Linked, Duplicate, Linked},
+		},
+		{
+			name: "replayed blocks",
+			notifications: []Notification{
+				{ID: "1", Cursor: "c1", PreviousCursor: "c0"},
+				{ID: "2", Cursor: "c2", PreviousCursor: "c1"},
+				{ID: "3", Cursor: "c3", PreviousCursor: "c2"},
+				{ID: "2", Cursor: "c2", PreviousCursor: "c1"},
+				{ID: "3", Cursor: "c3", PreviousCursor: "c2"},
+				{ID: "4", Cursor: "c4", PreviousCursor: "c3"},
+			},
+			want: []Linkage{Unlinked, Linked, Linked, Duplicate, Duplicate, Linked},
+		},
+		{
+			name: "without cursor",
+			notifications: []Notification{
+				{ID: "0"},
+				{ID: "1", Cursor: "c1"},
+				{ID: "2"},
+				{ID: "3", Cursor: "c2", PreviousCursor: "c1"},
+			},
+			want: []Linkage{Unlinked, Unlinked, Unlinked, Linked},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			tracker := NewCursorTracker(0)
+			for i := range tt.notifications {
+				n := &tt.notifications[i]
+				if got := tracker.Track(n); got != tt.want[i] {
+					t.Errorf("Track(%s, %s) notification: %d, got: %s, want: %s", n.ID, n.Cursor, i, got, tt.want[i])
+				}
+			}
+		})
+	}
+}
+
+func TestCursorTracker_History(t *testing.T) {
+	tracker := NewCursorTracker(2)
+	tracker.Track(&Notification{ID: "1", Cursor: "c1"})
+	tracker.Track(&Notification{ID: "2", Cursor: "c2", PreviousCursor: "c1"})
+	tracker.Track(&Notification{ID: "3", Cursor: "c3", PreviousCursor: "c2"})
+	if got := tracker.Track(&Notification{ID: "2", Cursor: "c2", PreviousCursor: "c1"}); got != Duplicate {
+		t.Errorf("Track(c2) got: %s, want: %s", got, Duplicate)
+	}
+	// c1 is evicted from the history
+	if got := tracker.Track(&Notification{ID: "1", Cursor: "c1"}); got != Gap {
+		t.Errorf("Track(c1) got: %s, want: %s", got, Gap)
+	}
+}
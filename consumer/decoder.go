@@ -0,0 +1,205 @@
+package consumer
+
+import (
+	"encoding/binary"
+	"encoding/json"
+	"fmt"
+	"path"
+	"strconv"
+	"strings"
+	"sync"
+
+	"github.com/confluentinc/confluent-kafka-go/kafka"
+	"github.com/dfuse-io/dkafka"
+	"github.com/linkedin/goavro/v2"
+	"github.com/riferrei/srclient"
+)
+
+const (
+	cloudEventsHeaderPrefix = "ce_"
+	cloudEventsContentType  = "application/cloudevents+json"
+)
+
+// Decoder decodes the dkafka messages. The avro data is decoded with the
+// schema registered under the ID of the message, fetched once from the schema
+// registry, and the converters of the eos types used by dkafka.
+type Decoder struct {
+	schemaRegistryClient srclient.ISchemaRegistryClient
+	mu                   sync.Mutex
+	codecs               map[uint32]*goavro.Codec
+}
+
+// NewDecoder returns a decoder fetching the avro schemas from the schema
+// registry client.
+func NewDecoder(schemaRegistryClient srclient.ISchemaRegistryClient) *Decoder {
+	return &Decoder{
+		schemaRegistryClient: schemaRegistryClient,
+		codecs:               make(map[uint32]*goavro.Codec),
+	}
+}
+
+// Decode returns the notification of a binary or structured CloudEvents
+// message with an avro, avro JSON or JSON data.
+func (d *Decoder) Decode(msg *kafka.Message) (*Notification, error) {
+	n := &Notification{
+		Key:     msg.Key,
+		Message: msg,
+	}
+	data := msg.Value
+	structured := false
+	for _, header := range msg.Headers {
+		switch {
+		case strings.HasPrefix(header.Key, cloudEventsHeaderPrefix):
+			if err := n.setAttribute(strings.TrimPrefix(header.Key, cloudEventsHeaderPrefix), string(header.Value)); err != nil {
+				return nil, err
+			}
+		case header.Key == dkafka.CursorHeaderKey:
+			n.Cursor = string(header.Value)
+		case header.Key == dkafka.PreviousCursorHeaderKey:
+			n.PreviousCursor = string(header.Value)
+		case header.Key == "content-type":
+			structured = string(header.Value) == cloudEventsContentType
+		}
+	}
+	if structured {
+		var err error
+		if data, err = n.setEnvelope(msg.Value); err != nil {
+			return nil, err
+		}
+	}
+	if len(data) == 0 {
+		return n, nil
+	}
+	if err := d.decodeData(n, data); err != nil {
+		return nil, fmt.Errorf("cannot decode data of message: %s, type: %s, error: %w", n.ID, n.Type, err)
+	}
+	return n, nil
+}
+
+// setEnvelope sets the attributes of a structured mode message and returns
+// its data
+func (n *Notification) setEnvelope(value []byte) ([]byte, error) {
+	var envelope map[string]json.RawMessage
+	if err := json.Unmarshal(value, &envelope); err != nil {
+		return nil, fmt.Errorf("invalid structured cloudevent: %w", err)
+	}
+	var data []byte
+	for name, raw := range envelope {
+		switch name {
+		case "data":
+			data = raw
+		case "data_base64":
+			if err := json.Unmarshal(raw, &data); err != nil {
+				return nil, fmt.Errorf("invalid data_base64: %w", err)
+			}
+		default:
+			var attribute string
+			if err := json.Unmarshal(raw, &attribute); err != nil {
+				return nil, fmt.Errorf("invalid attribute: %s, error: %w", name, err)
+			}
+			if err := n.setAttribute(name, attribute); err != nil {
+				return nil, err
+			}
+		}
+	}
+	return data, nil
+}
+
+func (d *Decoder) decodeData(n *Notification, data []byte) error {
+	switch contentType := strings.TrimSpace(strings.SplitN(n.DataContentType, ";", 2)[0]); contentType {
+	case "application/avro":
+		id, payload, err := schemaID(data)
+		if err != nil {
+			return err
+		}
+		codec, err := d.codec(id)
+		if err != nil {
+			return err
+		}
+		native, _, err := codec.NativeFromBinary(payload)
+		if err != nil {
+			return err
+		}
+		record, ok := native.(map[string]interface{})
+		if !ok {
+			return fmt.Errorf("unsupported avro value type: %T", native)
+		}
+		n.Data = record
+		n.avroData = true
+	case "application/avro+json":
+		id, err := dataSchemaID(n.DataSchema)
+		if err != nil {
+			return err
+		}
+		codec, err := d.codec(id)
+		if err != nil {
+			return err
+		}
+		native, _, err := codec.NativeFromTextual(data)
+		if err != nil {
+			return err
+		}
+		record, ok := native.(map[string]interface{})
+		if !ok {
+			return fmt.Errorf("unsupported avro value type: %T", native)
+		}
+		n.Data = record
+		n.avroData = true
+	case "", "application/json":
+		if data[0] == 0 {
+			// JSON Schema codec
+			_, payload, err := schemaID(data)
+			if err != nil {
+				return err
+			}
+			data = payload
+		}
+		n.Data = make(map[string]interface{})
+		if err := json.Unmarshal(data, &n.Data); err != nil {
+			return err
+		}
+	default:
+		return fmt.Errorf("unsupported data content type: '%s'", contentType)
+	}
+	return nil
+}
+
+// schemaID returns the schema ID and the payload of the schema registry wire
+// format
+func schemaID(data []byte) (uint32, []byte, error) {
+	if len(data) < 5 || data[0] != 0 {
+		return 0, nil, fmt.Errorf("invalid schema registry wire format")
+	}
+	return binary.BigEndian.Uint32(data[1:5]), data[5:], nil
+}
+
+// dataSchemaID returns the schema ID of the schema registry URL of the
+// dataschema attribute: {schema-registry-url}/schemas/ids/{id}
+func dataSchemaID(dataSchema string) (uint32, error) {
+	id, err := strconv.ParseUint(path.Base(dataSchema), 10, 32)
+	if err != nil {
+		return 0, fmt.Errorf("invalid dataschema: '%s', error: %w", dataSchema, err)
+	}
+	return uint32(id), nil
+}
+
+func (d *Decoder) codec(id uint32) (*goavro.Codec, error) {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	if codec, found := d.codecs[id]; found {
+		return codec, nil
+	}
+	schema, err := d.schemaRegistryClient.GetSchema(int(id))
+	if err != nil {
+		return nil, fmt.Errorf("cannot get schema: %d, error: %w", id, err)
+	}
+	if len(schema.References()) > 0 {
+		return nil, fmt.Errorf("schema: %d, with references is not supported", id)
+	}
+	codec, err := dkafka.NewAvroCodec(schema.Schema())
+	if err != nil {
+		return nil, fmt.Errorf("invalid schema: %d, error: %w", id, err)
+	}
+	d.codecs[id] = codec
+	return codec, nil
+}
@@ -0,0 +1,298 @@
+package consumer
+
+import (
+	"encoding/binary"
+	"encoding/json"
+	"fmt"
+	"testing"
+	"time"
+
+	"github.com/confluentinc/confluent-kafka-go/kafka"
+	pbcodec "github.com/dfuse-io/dfuse-eosio/pb/dfuse/eosio/codec/v1"
+	"github.com/dfuse-io/dkafka"
+	"github.com/eoscanada/eos-go"
+	"github.com/riferrei/srclient"
+)
+
+func TestDecoder_AvroTableNotification(t *testing.T) {
+	registry := srclient.CreateMockSchemaRegistryClient("mock://TestDecoder_AvroTableNotification")
+	msg := newResaleMessage(t, registry, "NEW")
+
+	n, err := NewDecoder(registry).Decode(msg)
+	if err != nil {
+		t.Fatalf("Decode() error: %v", err)
+	}
+	if n.Type != "ResaleATableNotification" || n.Step != StepNew || n.Cursor != "cursor-42" || n.PreviousCursor != "cursor-41" {
+		t.Errorf("Decode() attributes type: %s, step: %s, cursor: %s, previous cursor: %s", n.Type, n.Step, n.Cursor, n.PreviousCursor)
+	}
+	if n.IsCheckpoint() || n.IsUndo() {
+		t.Errorf("Decode() IsCheckpoint: %t, IsUndo: %t, want false", n.IsCheckpoint(), n.IsUndo())
+	}
+	context, err := n.Context()
+	if err != nil {
+		t.Fatalf("Context() error: %v", err)
+	}
+	if context.BlockNum != 42 || context.BlockID != "block-42" || !context.Executed || context.Correlation != nil {
+		t.Errorf("Context() = %+v", context)
+	}
+	if want := time.Date(2022, 1, 1, 0, 0, 0, 0, time.UTC); !context.Time.Equal(want) {
+		t.Errorf("Context().Time = %v, want %v", context.Time, want)
+	}
+	action, err := n.Action()
+	if err != nil {
+		t.Fatalf("Action() error: %v", err)
+	}
+	if action.Account != "eosio.nft.ft" || action.Name != "resale" || len(action.Authorizations) != 1 {
+		t.Errorf("Action() = %+v", action)
+	}
+	dbOps, err := n.DBOps()
+	if err != nil {
+		t.Fatalf("DBOps() error: %v", err)
+	}
+	if len(dbOps) != 1 {
+		t.Fatalf("DBOps() expected 1 operation got: %d", len(dbOps))
+	}
+	op := dbOps[0]
+	if op.Operation != pbcodec.DBOp_OPERATION_INSERT || op.TableName != "resale.a" || op.PrimaryKey != "104" || op.NewPayer != "owner" {
+		t.Errorf("DBOps()[0] = %+v", op)
+	}
+	if op.OldJSON != nil || op.NewJSON["owner"] != "owner" {
+		t.Errorf("DBOps()[0] old_json: %v, new_json: %v", op.OldJSON, op.NewJSON)
+	}
+	if string(op.NewData) != "new-data" {
+		t.Errorf("DBOps()[0].NewData = %s, want new-data", op.NewData)
+	}
+}
+
+func TestDecoder_UndoAppliedDBOps(t *testing.T) {
+	registry := srclient.CreateMockSchemaRegistryClient("mock://TestDecoder_UndoAppliedDBOps")
+	n, err := NewDecoder(registry).Decode(newResaleMessage(t, registry, "UNDO"))
+	if err != nil {
+		t.Fatalf("Decode() error: %v", err)
+	}
+	if !n.IsUndo() {
+		t.Fatalf("IsUndo() = false, want true")
+	}
+	dbOps, err := n.AppliedDBOps()
+	if err != nil {
+		t.Fatalf("AppliedDBOps() error: %v", err)
+	}
+	op := dbOps[0]
+	if op.Operation != pbcodec.DBOp_OPERATION_REMOVE || op.OldPayer != "owner" || op.NewPayer != "" {
+		t.Errorf("AppliedDBOps()[0] = %+v", op)
+	}
+	if op.NewJSON != nil || op.OldJSON["owner"] != "owner" {
+		t.Errorf("AppliedDBOps()[0] old_json: %v, new_json: %v", op.OldJSON, op.NewJSON)
+	}
+}
+
+func TestDecoder_AvroJSON(t *testing.T) {
+	registry := srclient.CreateMockSchemaRegistryClient("mock://TestDecoder_AvroJSON")
+	msg := newResaleMessage(t, registry, "NEW")
+	// re-encode the avro message with the avro JSON encoding
+	id := binary.BigEndian.Uint32(msg.Value[1:5])
+	schema, err := registry.GetSchema(int(id))
+	if err != nil {
+		t.Fatalf("GetSchema() error: %v", err)
+	}
+	codec, err := dkafka.NewAvroCodec(schema.Schema())
+	if err != nil {
+		t.Fatalf("NewAvroCodec() error: %v", err)
+	}
+	native, _, err := codec.NativeFromBinary(msg.Value[5:])
+	if err != nil {
+		t.Fatalf("NativeFromBinary() error: %v", err)
+	}
+	if msg.Value, err = codec.TextualFromNative(nil, native); err != nil {
+		t.Fatalf("TextualFromNative() error: %v", err)
+	}
+	for i, header := range msg.Headers {
+		if header.Key == "ce_datacontenttype" {
+			msg.Headers[i].Value = []byte("application/avro+json")
+		}
+	}
+	msg.Headers = append(msg.Headers, kafka.Header{Key: "ce_dataschema", Value: []byte(fmt.Sprintf("mock://TestDecoder_AvroJSON/schemas/ids/%d", id))})
+
+	n, err := NewDecoder(registry).Decode(msg)
+	if err != nil {
+		t.Fatalf("Decode() error: %v", err)
+	}
+	context, err := n.Context()
+	if err != nil {
+		t.Fatalf("Context() error: %v", err)
+	}
+	if context.BlockNum != 42 || context.BlockStep != "NEW" || !context.Time.Equal(time.Date(2022, 1, 1, 0, 0, 0, 0, time.UTC)) {
+		t.Errorf("Context() = %+v", context)
+	}
+	dbOps, err := n.DBOps()
+	if err != nil {
+		t.Fatalf("DBOps() error: %v", err)
+	}
+	op := dbOps[0]
+	if op.TableName != "resale.a" || op.NewPayer != "owner" || string(op.NewData) != "new-data" || op.NewJSON["owner"] != "owner" {
+		t.Errorf("DBOps()[0] = %+v", op)
+	}
+}
+
+func TestDecoder_StructuredJSON(t *testing.T) {
+	data := `{"context":{"block_num":43,"block_id":"block-43","block_step":"NEW","time":"2022-01-01T00:00:01Z","correlation":{"payer":"payer","id":"1"}},` +
+		`"act_info":{"account":"eosio.nft.ft","name":"transfer","json_data":{"from":"alice"},` +
+		`"db_ops":[{"operation":2,"table_name":"resale.a","primary_key":"104","old_data":"b2xk","new_json":{"owner":"bob"}},{"operation":3,"table_name":"resale.a","primary_key":"105"}]}}`
+	value, err := json.Marshal(map[string]interface{}{
+		"id":              "id-43",
+		"type":            "TransferActionNotification",
+		"source":          "dkafka",
+		"specversion":     "1.0",
+		"blkstep":         "NEW",
+		"datacontenttype": "application/json",
+		"data":            json.RawMessage(data),
+	})
+	if err != nil {
+		t.Fatalf("Marshal() error: %v", err)
+	}
+	msg := &kafka.Message{
+		Key:   []byte("104"),
+		Value: value,
+		Headers: []kafka.Header{
+			{Key: "content-type", Value: []byte("application/cloudevents+json")},
+			{Key: dkafka.CursorHeaderKey, Value: []byte("cursor-43")},
+		},
+	}
+	n, err := NewDecoder(nil).Decode(msg)
+	if err != nil {
+		t.Fatalf("Decode() error: %v", err)
+	}
+	if n.ID != "id-43" || n.Type != "TransferActionNotification" || n.Cursor != "cursor-43" {
+		t.Errorf("Decode() attributes id: %s, type: %s, cursor: %s", n.ID, n.Type, n.Cursor)
+	}
+	context, err := n.Context()
+	if err != nil {
+		t.Fatalf("Context() error: %v", err)
+	}
+	if context.BlockNum != 43 || context.Correlation == nil || context.Correlation.Payer != "payer" {
+		t.Errorf("Context() = %+v", context)
+	}
+	action, err := n.Action()
+	if err != nil {
+		t.Fatalf("Action() error: %v", err)
+	}
+	if action.Name != "transfer" || action.JSONData["from"] != "alice" {
+		t.Errorf("Action() = %+v", action)
+	}
+	dbOps, err := n.DBOps()
+	if err != nil {
+		t.Fatalf("DBOps() error: %v", err)
+	}
+	if len(dbOps) != 2 {
+		t.Fatalf("DBOps() expected 2 operations got: %d", len(dbOps))
+	}
+	if dbOps[0].Operation != pbcodec.DBOp_OPERATION_UPDATE || string(dbOps[0].OldData) != "old" || dbOps[0].NewJSON["owner"] != "bob" {
+		t.Errorf("DBOps()[0] = %+v", dbOps[0])
+	}
+	if dbOps[1].Operation != pbcodec.DBOp_OPERATION_REMOVE || dbOps[1].PrimaryKey != "105" {
+		t.Errorf("DBOps()[1] = %+v", dbOps[1])
+	}
+}
+
+func TestDecoder_Checkpoint(t *testing.T) {
+	msg := &kafka.Message{
+		Headers: []kafka.Header{
+			{Key: "ce_type", Value: []byte(dkafka.CheckpointSchema.Name)},
+			{Key: dkafka.CursorHeaderKey, Value: []byte("cursor-44")},
+		},
+	}
+	n, err := NewDecoder(nil).Decode(msg)
+	if err != nil {
+		t.Fatalf("Decode() error: %v", err)
+	}
+	if !n.IsCheckpoint() {
+		t.Errorf("IsCheckpoint() = false, want true")
+	}
+	if _, err := n.DBOps(); err == nil {
+		t.Errorf("DBOps() expected error on a checkpoint")
+	}
+}
+
+// newResaleMessage returns the avro encoded insert notification of the
+// resale.a table in the given step, an UNDO message holds the same operation
+func newResaleMessage(t *testing.T, registry srclient.ISchemaRegistryClient, step string) *kafka.Message {
+	t.Helper()
+	abi, err := dkafka.LoadABIFile("eosio.nft.ft", "../testdata/eosio.nft.ft.abi")
+	if err != nil {
+		t.Fatalf("LoadABIFile() error: %v", err)
+	}
+	messageSchema, err := dkafka.GenerateTableSchema(dkafka.MessageSchemaGenerator{Namespace: "test", Account: "eosio.nft.ft"}.SchemaGenOptions("table", "resale.a", abi))
+	if err != nil {
+		t.Fatalf("GenerateTableSchema() error: %v", err)
+	}
+	jsonSchema, err := json.Marshal(messageSchema)
+	if err != nil {
+		t.Fatalf("Marshal() error: %v", err)
+	}
+	schema, err := registry.CreateSchema("test.eosio.nft.ft.table.v0.ResaleATableNotification", string(jsonSchema), srclient.Avro)
+	if err != nil {
+		t.Fatalf("CreateSchema() error: %v", err)
+	}
+	codec, err := dkafka.NewAvroCodec(string(jsonSchema))
+	if err != nil {
+		t.Fatalf("NewAvroCodec() error: %v", err)
+	}
+	value := map[string]interface{}{
+		"context": map[string]interface{}{
+			"block_num":  int64(42),
+			"block_id":   "block-42",
+			"status":     "EXECUTED",
+			"executed":   true,
+			"block_step": step,
+			"trx_id":     "trx-1",
+			"time":       time.Date(2022, 1, 1, 0, 0, 0, 0, time.UTC),
+			"cursor":     "cursor-42",
+		},
+		"action": map[string]interface{}{
+			"account":                "eosio.nft.ft",
+			"receiver":               "eosio.nft.ft",
+			"name":                   "resale",
+			"global_seq":             int64(1),
+			"authorizations":         []interface{}{"owner@active"},
+			"action_ordinal":         int64(1),
+			"creator_action_ordinal": int64(0),
+			"closest_unnotified_ancestor_action_ordinal": int64(0),
+			"execution_index": int64(0),
+		},
+		"db_op": map[string]interface{}{
+			"operation":   int32(pbcodec.DBOp_OPERATION_INSERT),
+			"index":       int32(0),
+			"code":        "eosio.nft.ft",
+			"scope":       "eosio.nft.ft",
+			"table_name":  "resale.a",
+			"primary_key": "104",
+			"new_payer":   "owner",
+			"new_data":    []byte("new-data"),
+			"new_json": map[string]interface{}{
+				"token_id":             uint64(104),
+				"owner":                "owner",
+				"price":                eos.Asset{Amount: 150_000_000, Symbol: eos.Symbol{Precision: 8, Symbol: "UOS"}},
+				"promoter_basis_point": int32(5),
+			},
+		},
+	}
+	payload, err := codec.BinaryFromNative(nil, value)
+	if err != nil {
+		t.Fatalf("BinaryFromNative() error: %v", err)
+	}
+	header := make([]byte, 5)
+	binary.BigEndian.PutUint32(header[1:5], uint32(schema.ID()))
+	return &kafka.Message{
+		Key:   []byte("104"),
+		Value: append(header, payload...),
+		Headers: []kafka.Header{
+			{Key: "ce_id", Value: []byte("id-42")},
+			{Key: "ce_type", Value: []byte("ResaleATableNotification")},
+			{Key: "ce_time", Value: []byte("2022-01-01T00:00:00Z")},
+			{Key: "ce_blkstep", Value: []byte(step)},
+			{Key: "ce_datacontenttype", Value: []byte("application/avro")},
+			{Key: dkafka.CursorHeaderKey, Value: []byte("cursor-42")},
+			{Key: dkafka.PreviousCursorHeaderKey, Value: []byte("cursor-41")},
+		},
+	}
+}
@@ -0,0 +1,377 @@
+// Package consumer reads the messages produced by dkafka: it decodes the
+// CloudEvents envelope and the data of the messages, gives a typed access to
+// the notification context, action and database operations, and follows the
+// cursors chain to detect the missing or replayed blocks.
+package consumer
+
+import (
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/confluentinc/confluent-kafka-go/kafka"
+	pbcodec "github.com/dfuse-io/dfuse-eosio/pb/dfuse/eosio/codec/v1"
+	"github.com/dfuse-io/dkafka"
+)
+
+// Block steps of the ce_blkstep attribute
+const (
+	StepNew          = "NEW"
+	StepUndo         = "UNDO"
+	StepIrreversible = "IRREVERSIBLE"
+)
+
+// Notification is a dkafka message with its CloudEvents attributes, its
+// cursors and its decoded data.
+type Notification struct {
+	ID              string
+	Source          string
+	SpecVersion     string
+	Type            string
+	Subject         string
+	Time            time.Time
+	DataContentType string
+	DataSchema      string
+	// Step is the block step of the ce_blkstep extension
+	Step string
+	// ParentID is the correlation id of the ce_parentid extension
+	ParentID string
+	// Extensions are the other CloudEvents extensions, like dkafkaerror
+	Extensions map[string]string
+
+	// Cursor is the firehose cursor of the block of the message
+	Cursor string
+	// PreviousCursor is the cursor of the previous block processed by dkafka
+	PreviousCursor string
+
+	Key []byte
+	// Data is the decoded data, nil for an empty message value
+	Data map[string]interface{}
+	// Linkage is the position of the message in the cursors chain when read
+	// by a Consumer
+	Linkage Linkage
+	// Message is the kafka message of the notification
+	Message *kafka.Message
+
+	// avroData tells if the data is decoded from avro
+	avroData bool
+}
+
+// IsCheckpoint tells if the notification is a checkpoint saving the position
+// of dkafka instead of a chain notification.
+func (n *Notification) IsCheckpoint() bool {
+	return n.Type == dkafka.CheckpointSchema.Name
+}
+
+// IsUndo tells if the notification reverts a notification of a forked block.
+func (n *Notification) IsUndo() bool {
+	return strings.EqualFold(n.Step, StepUndo)
+}
+
+func (n *Notification) setAttribute(name string, value string) error {
+	switch name {
+	case "id":
+		n.ID = value
+	case "source":
+		n.Source = value
+	case "specversion":
+		n.SpecVersion = value
+	case "type":
+		n.Type = value
+	case "subject":
+		n.Subject = value
+	case "time":
+		t, err := time.Parse(time.RFC3339, value)
+		if err != nil {
+			return fmt.Errorf("invalid time attribute: %w", err)
+		}
+		n.Time = t
+	case "datacontenttype":
+		n.DataContentType = value
+	case "dataschema":
+		n.DataSchema = value
+	case "blkstep":
+		n.Step = value
+	case "parentid":
+		n.ParentID = value
+	default:
+		if n.Extensions == nil {
+			n.Extensions = make(map[string]string)
+		}
+		n.Extensions[name] = value
+	}
+	return nil
+}
+
+// Context is the notification context shared by the table and action
+// notifications.
+type Context struct {
+	BlockNum      uint32
+	BlockID       string
+	Status        string
+	Executed      bool
+	BlockStep     string
+	Correlation   *Correlation
+	TransactionID string
+	Time          time.Time
+	Cursor        string
+}
+
+type Correlation struct {
+	Payer string
+	ID    string
+}
+
+// ActionInfo is the action of a table notification or the act_info of an
+// action notification.
+type ActionInfo struct {
+	Account                                string
+	Receiver                               string
+	Name                                   string
+	GlobalSequence                         uint64
+	Authorizations                         []string
+	ActionOrdinal                          uint32
+	CreatorActionOrdinal                   uint32
+	ClosestUnnotifiedAncestorActionOrdinal uint32
+	ExecutionIndex                         uint32
+	// JSONData is the decoded action parameters of an action notification
+	JSONData map[string]interface{}
+}
+
+// DBOp is a database operation of a table or action notification.
+type DBOp struct {
+	Operation   pbcodec.DBOp_Operation
+	ActionIndex uint32
+	Index       int
+	Code        string
+	Scope       string
+	TableName   string
+	PrimaryKey  string
+	OldPayer    string
+	NewPayer    string
+	OldData     []byte
+	NewData     []byte
+	// OldJSON and NewJSON are the decoded rows of a table notification
+	OldJSON map[string]interface{}
+	NewJSON map[string]interface{}
+}
+
+// Undo returns the operation reverting the database operation: an insert
+// becomes a remove, a remove an insert and the old and new rows are swapped.
+func (op DBOp) Undo() DBOp {
+	switch op.Operation {
+	case pbcodec.DBOp_OPERATION_INSERT:
+		op.Operation = pbcodec.DBOp_OPERATION_REMOVE
+	case pbcodec.DBOp_OPERATION_REMOVE:
+		op.Operation = pbcodec.DBOp_OPERATION_INSERT
+	}
+	op.OldPayer, op.NewPayer = op.NewPayer, op.OldPayer
+	op.OldData, op.NewData = op.NewData, op.OldData
+	op.OldJSON, op.NewJSON = op.NewJSON, op.OldJSON
+	return op
+}
+
+// Context returns the notification context of a table or action notification.
+func (n *Notification) Context() (*Context, error) {
+	context, err := n.record("context")
+	if err != nil {
+		return nil, err
+	}
+	result := &Context{
+		BlockNum:      uint32(number(context["block_num"])),
+		BlockID:       text(context["block_id"]),
+		Status:        text(context["status"]),
+		Executed:      context["executed"] == true,
+		BlockStep:     text(context["block_step"]),
+		TransactionID: text(context["trx_id"]),
+		Cursor:        text(context["cursor"]),
+	}
+	if result.Time, err = timestamp(context["time"]); err != nil {
+		return nil, err
+	}
+	if correlation, ok := n.union(context["correlation"]).(map[string]interface{}); ok {
+		result.Correlation = &Correlation{
+			Payer: text(correlation["payer"]),
+			ID:    text(correlation["id"]),
+		}
+	}
+	return result, nil
+}
+
+// Action returns the action of a table notification or the act_info of an
+// action notification.
+func (n *Notification) Action() (*ActionInfo, error) {
+	action, err := n.record("action", "act_info")
+	if err != nil {
+		return nil, err
+	}
+	result := &ActionInfo{
+		Account:                                text(action["account"]),
+		Receiver:                               text(action["receiver"]),
+		Name:                                   text(action["name"]),
+		GlobalSequence:                         number(action["global_seq"]),
+		ActionOrdinal:                          uint32(number(action["action_ordinal"])),
+		CreatorActionOrdinal:                   uint32(number(action["creator_action_ordinal"])),
+		ClosestUnnotifiedAncestorActionOrdinal: uint32(number(action["closest_unnotified_ancestor_action_ordinal"])),
+		ExecutionIndex:                         uint32(number(action["execution_index"])),
+	}
+	authorizations, _ := action["authorizations"].([]interface{})
+	for _, authorization := range authorizations {
+		result.Authorizations = append(result.Authorizations, text(authorization))
+	}
+	result.JSONData, _ = action["json_data"].(map[string]interface{})
+	return result, nil
+}
+
+// DBOps returns the db_op of a table notification or the db_ops of an action
+// notification in the order of the message.
+func (n *Notification) DBOps() ([]DBOp, error) {
+	if dbOp, ok := n.Data["db_op"].(map[string]interface{}); ok {
+		op, err := n.dbOp(dbOp)
+		if err != nil {
+			return nil, err
+		}
+		return []DBOp{op}, nil
+	}
+	action, err := n.record("act_info")
+	if err != nil {
+		return nil, fmt.Errorf("notification of type: '%s' has no database operations", n.Type)
+	}
+	dbOps, _ := action["db_ops"].([]interface{})
+	result := make([]DBOp, 0, len(dbOps))
+	for _, dbOp := range dbOps {
+		fields, ok := dbOp.(map[string]interface{})
+		if !ok {
+			return nil, fmt.Errorf("unsupported db_ops item type: %T", dbOp)
+		}
+		op, err := n.dbOp(fields)
+		if err != nil {
+			return nil, err
+		}
+		result = append(result, op)
+	}
+	return result, nil
+}
+
+// AppliedDBOps returns the database operations as they must be applied on a
+// copy of the tables. The operations of an UNDO notification are reverted and
+// in the reverse order.
+func (n *Notification) AppliedDBOps() ([]DBOp, error) {
+	dbOps, err := n.DBOps()
+	if err != nil || !n.IsUndo() {
+		return dbOps, err
+	}
+	reverted := make([]DBOp, len(dbOps))
+	for i, dbOp := range dbOps {
+		reverted[len(dbOps)-1-i] = dbOp.Undo()
+	}
+	return reverted, nil
+}
+
+func (n *Notification) record(names ...string) (map[string]interface{}, error) {
+	for _, name := range names {
+		if record, ok := n.Data[name].(map[string]interface{}); ok {
+			return record, nil
+		}
+	}
+	return nil, fmt.Errorf("notification of type: '%s' has no %s", n.Type, strings.Join(names, " or "))
+}
+
+func (n *Notification) dbOp(fields map[string]interface{}) (DBOp, error) {
+	op := DBOp{
+		Operation:   pbcodec.DBOp_Operation(number(n.union(fields["operation"]))),
+		ActionIndex: uint32(number(n.union(fields["action_index"]))),
+		Index:       int(number(fields["index"])),
+		Code:        text(n.union(fields["code"])),
+		Scope:       text(n.union(fields["scope"])),
+		TableName:   text(n.union(fields["table_name"])),
+		PrimaryKey:  text(n.union(fields["primary_key"])),
+		OldPayer:    text(n.union(fields["old_payer"])),
+		NewPayer:    text(n.union(fields["new_payer"])),
+	}
+	var err error
+	if op.OldData, err = bytesValue(n.union(fields["old_data"])); err != nil {
+		return op, fmt.Errorf("invalid old_data: %w", err)
+	}
+	if op.NewData, err = bytesValue(n.union(fields["new_data"])); err != nil {
+		return op, fmt.Errorf("invalid new_data: %w", err)
+	}
+	op.OldJSON, _ = n.union(fields["old_json"]).(map[string]interface{})
+	op.NewJSON, _ = n.union(fields["new_json"]).(map[string]interface{})
+	return op, nil
+}
+
+// union returns the value of an optional field, the avro decoded unions
+// are wrapped in a map keyed by the type of the value
+func (n *Notification) union(value interface{}) interface{} {
+	if !n.avroData {
+		return value
+	}
+	if union, ok := value.(map[string]interface{}); ok && len(union) == 1 {
+		for _, v := range union {
+			return v
+		}
+	}
+	return value
+}
+
+func text(value interface{}) string {
+	if value == nil {
+		return ""
+	}
+	if s, ok := value.(string); ok {
+		return s
+	}
+	return fmt.Sprint(value)
+}
+
+func number(value interface{}) uint64 {
+	switch v := value.(type) {
+	case int:
+		return uint64(v)
+	case int32:
+		return uint64(v)
+	case int64:
+		return uint64(v)
+	case uint32:
+		return uint64(v)
+	case uint64:
+		return v
+	case float64:
+		return uint64(v)
+	case json.Number:
+		n, _ := v.Int64()
+		return uint64(n)
+	default:
+		return 0
+	}
+}
+
+func timestamp(value interface{}) (time.Time, error) {
+	switch v := value.(type) {
+	case nil:
+		return time.Time{}, nil
+	case time.Time:
+		return v, nil
+	case string:
+		return time.Parse(time.RFC3339, v)
+	default:
+		return time.Time{}, fmt.Errorf("unsupported time value type: %T", value)
+	}
+}
+
+// bytesValue returns the bytes of an avro value or of a base64 JSON value
+func bytesValue(value interface{}) ([]byte, error) {
+	switch v := value.(type) {
+	case nil:
+		return nil, nil
+	case []byte:
+		return v, nil
+	case string:
+		return base64.StdEncoding.DecodeString(v)
+	default:
+		return nil, fmt.Errorf("unsupported bytes value type: %T", value)
+	}
+}
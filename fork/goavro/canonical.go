@@ -24,13 +24,19 @@ type pcfProcessor func(s interface{}) (string, error)
 // JSON structure of a valid Avro schema, or an error describing the schema
 // error.
 func parsingCanonicalForm(schema interface{}, parentNamespace string, typeLookup map[string]string) (string, error) {
+	return canonicalForm(schema, parentNamespace, typeLookup, fieldOrder)
+}
+
+// canonicalForm returns the canonical form of a parsed JSON structure that
+// only keeps the attributes of the given order.
+func canonicalForm(schema interface{}, parentNamespace string, typeLookup map[string]string, order map[string]int) (string, error) {
 	switch val := schema.(type) {
 	case map[string]interface{}:
 		// JSON objects are decoded as a map of strings to empty interfaces
-		return pcfObject(val, parentNamespace, typeLookup)
+		return pcfObject(val, parentNamespace, typeLookup, order)
 	case []interface{}:
 		// JSON arrays are decoded as a slice of empty interfaces
-		return pcfArray(val, parentNamespace, typeLookup)
+		return pcfArray(val, parentNamespace, typeLookup, order)
 	case string:
 		// JSON string values are decoded as a Go string
 		return pcfString(val, typeLookup)
@@ -56,10 +62,10 @@ func pcfString(val string, typeLookup map[string]string) (string, error) {
 }
 
 // pcfArray returns the parsing canonical form for a JSON array.
-func pcfArray(val []interface{}, parentNamespace string, typeLookup map[string]string) (string, error) {
+func pcfArray(val []interface{}, parentNamespace string, typeLookup map[string]string, order map[string]int) (string, error) {
 	items := make([]string, len(val))
 	for i, el := range val {
-		p, err := parsingCanonicalForm(el, parentNamespace, typeLookup)
+		p, err := canonicalForm(el, parentNamespace, typeLookup, order)
 		if err != nil {
 			return "", err
 		}
@@ -69,7 +75,7 @@ func pcfArray(val []interface{}, parentNamespace string, typeLookup map[string]s
 }
 
 // pcfObject returns the parsing canonical form for a JSON object.
-func pcfObject(jsonMap map[string]interface{}, parentNamespace string, typeLookup map[string]string) (string, error) {
+func pcfObject(jsonMap map[string]interface{}, parentNamespace string, typeLookup map[string]string, order map[string]int) (string, error) {
 	pairs := make(stringPairs, 0, len(jsonMap))
 
 	// Remember the namespace to fully qualify names later
@@ -98,7 +104,7 @@ func pcfObject(jsonMap map[string]interface{}, parentNamespace string, typeLooku
 		}
 
 		// Only keep relevant attributes (strip 'doc', 'alias', 'namespace')
-		if _, ok := fieldOrder[k]; !ok {
+		if _, ok := order[k]; !ok {
 			continue
 		}
 
@@ -124,11 +130,11 @@ func pcfObject(jsonMap map[string]interface{}, parentNamespace string, typeLooku
 			}
 		}
 
-		pk, err := parsingCanonicalForm(k, parentNamespace, typeLookup)
+		pk, err := canonicalForm(k, parentNamespace, typeLookup, order)
 		if err != nil {
 			return "", err
 		}
-		pv, err := parsingCanonicalForm(v, parentNamespace, typeLookup)
+		pv, err := canonicalForm(v, parentNamespace, typeLookup, order)
 		if err != nil {
 			return "", err
 		}
@@ -137,7 +143,9 @@ func pcfObject(jsonMap map[string]interface{}, parentNamespace string, typeLooku
 	}
 
 	// Sort keys by their order in specification.
-	sort.Sort(byAvroFieldOrder(pairs))
+	sort.Slice(pairs, func(i, j int) bool {
+		return order[pairs[i].A] < order[pairs[j].A]
+	})
 	return "{" + strings.Join(pairs.Bs(), ",") + "}", nil
 }
 
@@ -170,19 +178,3 @@ var fieldOrder = map[string]int{
 	"values":  6,
 	"size":    7,
 }
-
-// byAvroFieldOrder is equipped with a sort order of fields according to the
-// specification.
-type byAvroFieldOrder []stringPair
-
-func (s byAvroFieldOrder) Len() int {
-	return len(s)
-}
-
-func (s byAvroFieldOrder) Swap(i, j int) {
-	s[i], s[j] = s[j], s[i]
-}
-
-func (s byAvroFieldOrder) Less(i, j int) bool {
-	return fieldOrder[s[i].A] < fieldOrder[s[j].A]
-}
@@ -1,5 +1,11 @@
 package goavro
 
+import (
+	"encoding/json"
+	"fmt"
+	"strings"
+)
+
 var eosToAvro map[string]string
 
 func init() {
@@ -30,3 +36,70 @@ func init() {
 		"symbol_code":          "string",
 	}
 }
+
+// eosIndex resolves the union member of an eos variant value from the eos
+// type name of the value. It is, by order of precedence: the member annotated
+// with the same "eos.type", the avro primitive of the eos built-in type, the
+// array for the eos array types, the named type (record, enum, fixed) whose
+// name matches the eos struct name.
+func (cr *codecInfo) eosIndex(eosTypeName string) (int, bool) {
+	if index, ok := cr.indexFromEosType[eosTypeName]; ok {
+		return index, true
+	}
+	if avroTypeName, ok := eosToAvro[eosTypeName]; ok {
+		index, ok := cr.indexFromName[avroTypeName]
+		return index, ok
+	}
+	if strings.HasSuffix(eosTypeName, "[]") {
+		index, ok := cr.indexFromName["array"]
+		return index, ok
+	}
+	index, ok := cr.indexFromEosName[eosNameKey(eosTypeName)]
+	return index, ok
+}
+
+// nullableConverted is true for a nullable union whose non-null member has a
+// converter.
+func (cr *codecInfo) nullableConverted() bool {
+	if !cr.nullable() {
+		return false
+	}
+	return cr.converted[0] || cr.converted[1]
+}
+
+// eosNameKey matches an eos struct name (snake case) with its avro name (camel
+// case)
+func eosNameKey(name string) string {
+	return strings.ToLower(strings.NewReplacer("_", "", ".", "").Replace(name))
+}
+
+// normalizedFieldOrder extends the canonical form attributes with the logical
+// type ones, they change the meaning of the encoded values.
+var normalizedFieldOrder = map[string]int{
+	"name":        1,
+	"type":        2,
+	"fields":      3,
+	"symbols":     4,
+	"items":       5,
+	"values":      6,
+	"size":        7,
+	"logicalType": 8,
+	"precision":   9,
+	"scale":       10,
+}
+
+// NormalizedSchemaForm returns the parsing canonical form of the schema that
+// keeps the logicalType, precision and scale attributes, and its Rabin
+// fingerprint, without building its codec. The named types defined outside of
+// the schema, like the schema registry references, are kept as is.
+func NormalizedSchemaForm(schemaSpecification string) (string, uint64, error) {
+	var schema interface{}
+	if err := json.Unmarshal([]byte(schemaSpecification), &schema); err != nil {
+		return "", 0, fmt.Errorf("cannot unmarshal schema JSON: %s", err)
+	}
+	normalized, err := canonicalForm(schema, "", make(map[string]string), normalizedFieldOrder)
+	if err != nil {
+		return "", 0, err
+	}
+	return normalized, rabin([]byte(normalized)), nil
+}
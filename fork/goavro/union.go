@@ -24,6 +24,10 @@ type codecInfo struct {
 	codecFromIndex []*Codec
 	codecFromName  map[string]*Codec
 	indexFromName  map[string]int
+	// eos variant lookups, see eosIndex
+	indexFromEosType map[string]int
+	indexFromEosName map[string]int
+	converted        []bool
 }
 
 func (cr *codecInfo) nullable() bool {
@@ -70,6 +74,9 @@ func makeCodecInfo(converters map[string]ConvertBuild, st map[string]*Codec, enc
 	codecFromIndex := make([]*Codec, len(schemaArray))
 	codecFromName := make(map[string]*Codec, len(schemaArray))
 	indexFromName := make(map[string]int, len(schemaArray))
+	indexFromEosType := make(map[string]int)
+	indexFromEosName := make(map[string]int)
+	converted := make([]bool, len(schemaArray))
 
 	for i, unionMemberSchema := range schemaArray {
 		unionMemberCodec, err := buildCodec(converters, st, enclosingNamespace, unionMemberSchema, cb)
@@ -84,13 +91,25 @@ func makeCodecInfo(converters map[string]ConvertBuild, st map[string]*Codec, enc
 		codecFromIndex[i] = unionMemberCodec
 		codecFromName[fullName] = unionMemberCodec
 		indexFromName[fullName] = i
+		if schemaMap, ok := unionMemberSchema.(map[string]interface{}); ok {
+			if eosType, ok := schemaMap["eos.type"].(string); ok {
+				indexFromEosType[eosType] = i
+			}
+			_, converted[i] = schemaMap["convert"]
+		}
+		if unionMemberCodec.isNamedType {
+			indexFromEosName[eosNameKey(unionMemberCodec.typeName.short())] = i
+		}
 	}
 
 	return codecInfo{
-		allowedTypes:   allowedTypes,
-		codecFromIndex: codecFromIndex,
-		codecFromName:  codecFromName,
-		indexFromName:  indexFromName,
+		allowedTypes:     allowedTypes,
+		codecFromIndex:   codecFromIndex,
+		codecFromName:    codecFromName,
+		indexFromName:    indexFromName,
+		indexFromEosType: indexFromEosType,
+		indexFromEosName: indexFromEosName,
+		converted:        converted,
 	}, nil
 
 }
@@ -149,10 +168,14 @@ func binaryFromNative(cr *codecInfo) func(buf []byte, datum interface{}) ([]byte
 		case []interface{}:
 			if len(v) == 2 {
 				// EOS specific variant serialization
+				if cr.nullableConverted() {
+					// the converter of the non-null member expects the eos variant as is
+					break
+				}
 				if eosTypeName, ok := v[0].(string); ok {
 					value := v[1]
-					if avroTypeName, ok := eosToAvro[eosTypeName]; ok {
-						return binaryFromNative(cr)(buf, Union(avroTypeName, value))
+					if index, ok := cr.eosIndex(eosTypeName); ok {
+						return doBinaryFromNative(cr, index, buf, value)
 					} else {
 						err = fmt.Errorf("cannot encode binary union on eos input: the eos type is not supported %s; avro union definition: %v; received: %T", eosTypeName, cr.allowedTypes, datum)
 					}
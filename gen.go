@@ -6,6 +6,7 @@ import (
 
 	"github.com/confluentinc/confluent-kafka-go/kafka"
 	pbcodec "github.com/dfuse-io/dfuse-eosio/pb/dfuse/eosio/codec/v1"
+	"github.com/eoscanada/eos-go"
 	"github.com/google/cel-go/cel"
 	pbbstream "github.com/streamingfast/pbgo/dfuse/bstream/v1"
 	"go.uber.org/zap"
@@ -57,6 +58,8 @@ type generation struct {
 	CeId       []byte      `json:"ce_id,omitempty"`
 	Key        string      `json:"key,omitempty"`
 	Value      interface{} `json:"value,omitempty"`
+	// Error is set when the value could not be decoded and is emitted raw
+	Error error `json:"-"`
 }
 
 type DecodeDBOp func(in *pbcodec.DBOp, blockNum uint32) (decodedDBOps *decodedDBOp, err error)
@@ -99,10 +102,23 @@ type TableKeyExtractorFinder func(string) (ExtractKey, bool)
 
 type ActionKeyExtractorFinder func(string) (cel.Program, bool)
 
+// TableExpression is the CEL include filter and key expression of a table,
+// both optional.
+type TableExpression struct {
+	Filter cel.Program
+	Key    cel.Program
+}
+
+type TableExpressionFinder func(string) (TableExpression, bool)
+
 type TableGenerator struct {
 	getExtractKey   TableKeyExtractorFinder
 	abiCodec        ABICodec
 	targetedAccount string
+	// undecodablePolicy is one of fail (default), skip or raw
+	undecodablePolicy string
+	// getExpression is optional, the key expression overrides the key extractor
+	getExpression TableExpressionFinder
 }
 
 type void struct{}
@@ -124,12 +140,19 @@ func (tg TableGenerator) Apply(gc ActionContext) (generations []Generation2, err
 			zlog.Debug("fail fast on codec.Marshal()", zap.Error(err))
 			return nil, err
 		}
+		headers := codec.GetHeaders()
+		if g.Error != nil {
+			headers = append(headers, kafka.Header{
+				Key:   undecodableErrorHeader,
+				Value: []byte(g.Error.Error()),
+			})
+		}
 		generations = append(generations, Generation2{
 			CeType:  g.CeType,
 			CeId:    g.CeId,
 			Key:     g.Key,
 			Value:   value,
-			Headers: codec.GetHeaders(),
+			Headers: headers,
 		})
 	}
 	zlog.Debug("return messages after marshal operation", zap.Any("nb_messages", len(generations)))
@@ -155,9 +178,28 @@ func (tg TableGenerator) doApply(gc ActionContext) ([]generation, error) {
 		}
 		decodedDBOp, err := tg.abiCodec.DecodeDBOp(dbOp, gc.block.Number)
 		if err != nil {
-			return nil, err
+			if !tg.tolerateUndecodable(dbOp, gc.block.Number, err) {
+				return nil, err
+			}
+			if tg.undecodablePolicy == SkipUndecodableDBOp {
+				continue
+			}
 		}
 		key := extractKey(dbOp)
+		// the expressions need the decoded rows, an undecoded operation of the
+		// raw policy keeps the key of the extractor
+		if expression, found := tg.findExpression(dbOp.TableName); found && err == nil {
+			include, expressionKey, exprErr := expression.eval(gc, decodedDBOp)
+			if exprErr != nil {
+				return nil, fmt.Errorf("fail to evaluate expression of table: %s, error: %w", dbOp.TableName, exprErr)
+			}
+			if !include {
+				continue
+			}
+			if expression.Key != nil {
+				key = expressionKey
+			}
+		}
 		tableCamelCase, ceType := tableCeType(dbOp.TableName)
 		ceId := hashString(fmt.Sprintf(
 			"%s%s%d%d%s",
@@ -167,20 +209,32 @@ func (tg TableGenerator) doApply(gc ActionContext) ([]generation, error) {
 			dbOpIndex,
 			gc.stepName,
 		))
-		value := newTableNotification(
-			notificationContextMap(gc),
-			actionInfoBasicMap(gc),
-			decodedDBOp.asMap(dbOpRecordName(tableCamelCase), dbOpIndex),
-		)
 		generation := generation{
 			CeId:       ceId,
 			CeType:     ceType,
 			Key:        key,
-			Value:      value,
 			EntityType: Table,
 			EntityName: dbOp.TableName,
 			Account:    dbOp.Code,
 		}
+		if err != nil {
+			// raw policy: only the old_data/new_data bytes are available
+			generation.Value = newUndecodedTableNotification(
+				notificationContextMap(gc),
+				actionInfoBasicMap(gc),
+				newDBOpBasic(dbOp, dbOpIndex),
+				err,
+			)
+			generation.Account = UndecodedTableNotificationSchema.Namespace
+			generation.EntityName = UndecodedTableNotificationSchema.Name
+			generation.Error = err
+		} else {
+			generation.Value = newTableNotification(
+				notificationContextMap(gc),
+				actionInfoBasicMap(gc),
+				decodedDBOp.asMap(dbOpRecordName(tableCamelCase), dbOpIndex),
+			)
+		}
 		zlog.Debug("generated table message", zap.Any("generation", generation))
 		generations = append(generations, generation)
 	}
@@ -188,10 +242,59 @@ func (tg TableGenerator) doApply(gc ActionContext) ([]generation, error) {
 	return generations, nil
 }
 
+func (tg TableGenerator) findExpression(tableName string) (TableExpression, bool) {
+	if tg.getExpression == nil {
+		return TableExpression{}, false
+	}
+	return tg.getExpression(tableName)
+}
+
+// eval returns if the database operation is included and its key, the key is
+// only evaluated for the included operations with a key expression.
+func (te TableExpression) eval(gc ActionContext, dbOp *decodedDBOp) (include bool, key string, err error) {
+	activation, err := NewTableActivation(gc.stepName, gc.transaction, gc.actionTrace, dbOp)
+	if err != nil {
+		return
+	}
+	include = true
+	if te.Filter != nil {
+		if include, err = evalBool(te.Filter, activation); err != nil || !include {
+			return
+		}
+	}
+	if te.Key != nil {
+		key, err = evalString(te.Key, activation)
+	}
+	return
+}
+
+// tolerateUndecodable counts the DB operation that cannot be decoded and
+// returns true when the policy allows to go on without failing.
+func (tg TableGenerator) tolerateUndecodable(dbOp *pbcodec.DBOp, blockNum uint32, err error) bool {
+	policy := tg.undecodablePolicy
+	if policy == "" {
+		policy = FailUndecodableDBOp
+	}
+	undecodableDBOps.WithLabelValues(dbOp.Code, dbOp.TableName, policy).Inc()
+	if policy == FailUndecodableDBOp {
+		return false
+	}
+	zlog.Warn("cannot decode db op",
+		zap.String("policy", policy),
+		zap.String("account", dbOp.Code),
+		zap.String("table", dbOp.TableName),
+		zap.String("primary_key", dbOp.PrimaryKey),
+		zap.Uint32("block_num", blockNum),
+		zap.Error(err),
+	)
+	return true
+}
+
 type ActionGenerator2 struct {
 	keyExtractors ActionKeyExtractorFinder
 	abiCodec      ABICodec
 	skipDbOps     bool
+	decodeRawData bool
 }
 
 func (ag ActionGenerator2) Apply(gc ActionContext) ([]Generation2, error) {
@@ -247,12 +350,9 @@ func (ag ActionGenerator2) doApply(gc ActionContext) ([]generation, error) {
 		gc.actionTrace.ExecutionIndex,
 		gc.stepName,
 	))
-	jsonData := make(map[string]interface{})
-	if stringData := gc.actionTrace.Action.JsonData; stringData != "" {
-		err = json.Unmarshal(json.RawMessage(stringData), &jsonData)
-		if err != nil {
-			return nil, err
-		}
+	jsonData, err := ag.actionData(gc)
+	if err != nil {
+		return nil, err
 	}
 	var dbOpsGen []map[string]interface{}
 	if ag.skipDbOps {
@@ -282,6 +382,34 @@ func (ag ActionGenerator2) doApply(gc ActionContext) ([]generation, error) {
 	}}, nil
 }
 
+// actionData returns the action parameters decoded from the raw data with
+// the ABI when decodeRawData is set or from the firehose JSON otherwise.
+func (ag ActionGenerator2) actionData(gc ActionContext) (map[string]interface{}, error) {
+	action := gc.actionTrace.Action
+	if ag.decodeRawData {
+		abi, err := ag.abiCodec.GetABI(action.Account, gc.block.Number)
+		if err != nil {
+			return nil, fmt.Errorf("fail to get ABI for decoding action: %s in block: %d, error: %w", action.Name, gc.block.Number, err)
+		}
+		actionDef := abi.ActionForName(eos.ActionName(action.Name))
+		if actionDef == nil {
+			return nil, fmt.Errorf("action %s not present in ABI for contract %s at block: %d", action.Name, action.Account, gc.block.Number)
+		}
+		data, err := abi.DecodeTableRowTypedNative(actionDef.Type, action.RawData)
+		if err != nil {
+			return nil, fmt.Errorf("fail to decode action: %s raw data at block: %d, error: %w", action.Name, gc.block.Number, err)
+		}
+		return data, nil
+	}
+	jsonData := make(map[string]interface{})
+	if stringData := action.JsonData; stringData != "" {
+		if err := json.Unmarshal(json.RawMessage(stringData), &jsonData); err != nil {
+			return nil, err
+		}
+	}
+	return jsonData, nil
+}
+
 func notificationContextMap(gc ActionContext) map[string]interface{} {
 	status := sanitizeStatus(gc.transaction.Receipt.Status.String())
 
@@ -328,6 +456,8 @@ type transaction2ActionsGenerator struct {
 	headers              []kafka.Header
 	topic                string
 	account              string
+	// schemaGenerator generates the schemas compared on an ABI update
+	schemaGenerator MessageSchemaGenerator
 }
 
 func (t transaction2ActionsGenerator) isThisSmartContractABIUpdated(action *pbcodec.Action) bool {
@@ -370,8 +500,13 @@ func (t transaction2ActionsGenerator) Apply(genContext TransactionContext) ([]*k
 			continue
 		}
 		if t.isThisSmartContractABIUpdated(act.Action) {
-			zlog.Info("new abi published defer clear ABI cache at end of this block parsing", zap.Uint32("block_num", genContext.block.Number), zap.Int("trx_index", int(trx.Index)), zap.String("trx_id", trx.Id))
-			t.abiCodec.UpdateABI(genContext.block.Number, genContext.step, trx.Id, act)
+			zlog.Info("new abi published", zap.Uint32("block_num", genContext.block.Number), zap.Int("trx_index", int(trx.Index)), zap.String("trx_id", trx.Id), zap.String("step", genContext.stepName))
+			msg, err := t.abiUpdated(genContext, act)
+			if err != nil {
+				zlog.Error("fail to publish abi update", zap.Uint32("block_num", genContext.block.Number), zap.String("trx_id", trx.Id), zap.Error(err))
+				return nil, fmt.Errorf("fail to update abi of block: %d, trx: %s, error: %w", genContext.block.Number, trx.Id, err)
+			}
+			msgs = append(msgs, msg)
 			continue
 		}
 		actionTracesReceived.Inc()
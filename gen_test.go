@@ -1,10 +1,13 @@
 package dkafka
 
 import (
+	"encoding/json"
 	"fmt"
+	"reflect"
 	"testing"
 
 	pbcodec "github.com/dfuse-io/dfuse-eosio/pb/dfuse/eosio/codec/v1"
+	pbbstream "github.com/streamingfast/pbgo/dfuse/bstream/v1"
 )
 
 const emptyName = "............."
@@ -165,3 +168,110 @@ func Test_extractPrimaryKey(t *testing.T) {
 		})
 	}
 }
+
+func TestTableGenerator_Expressions(t *testing.T) {
+	tests := []struct {
+		name     string
+		exprs    []string
+		wantKeys []string
+	}{
+		{
+			name:     "key",
+			exprs:    []string{`factory.a={"key":"string(table.new.id)"}`},
+			wantKeys: []string{"26"},
+		},
+		{
+			name:     "filter-out",
+			exprs:    []string{`factory.a={"filter":"db_op.operation != 1"}`},
+			wantKeys: nil,
+		},
+		{
+			name:     "wildcard",
+			exprs:    []string{`*={"filter":"table.new.id == 26 && step == 'NEW'","key":"db_op.scope + ':' + table.name"}`},
+			wantKeys: []string{"eosio.nft.ft:factory.a"},
+		},
+		{
+			name:     "other-table",
+			exprs:    []string{`factory.b={"filter":"false"}`},
+			wantKeys: []string{"eosio.nft.ft:...........1e"},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			block := &pbcodec.Block{}
+			if err := json.Unmarshal(readFileFromTestdata(t, "testdata/block-30080032.json"), block); err != nil {
+				t.Fatalf("Unmarshal() error: %v", err)
+			}
+			generator := newTableGen4Test(t, "factory.a")
+			finder, err := buildTableExpressionFinder(tt.exprs)
+			if err != nil {
+				t.Fatalf("buildTableExpressionFinder() error: %v", err)
+			}
+			generator.getExpression = finder
+			m := &CdCAdapter{
+				topic:     "test.topic",
+				saveBlock: saveBlockNoop,
+				generator: transaction2ActionsGenerator{
+					actionLevelGenerator: generator,
+					topic:                "test.topic",
+					headers:              default_headers,
+				},
+				headers: default_headers,
+			}
+			got, err := m.Adapt(BlockStep{blk: block, step: pbbstream.ForkStep_STEP_NEW, cursor: "123"})
+			if err != nil {
+				t.Fatalf("CdCAdapter.Adapt() error: %v", err)
+			}
+			var keys []string
+			for _, msg := range got {
+				keys = append(keys, string(msg.Key))
+			}
+			if !reflect.DeepEqual(keys, tt.wantKeys) {
+				t.Errorf("CdCAdapter.Adapt() keys = %v, want %v", keys, tt.wantKeys)
+			}
+		})
+	}
+}
+
+func TestTableGenerator_ExpressionsUndecoded(t *testing.T) {
+	gc := newActionContext4Test(t, &pbcodec.DBOp{
+		Operation:  pbcodec.DBOp_OPERATION_INSERT,
+		Code:       "eosio.nft.ft",
+		Scope:      "eosio.nft.ft",
+		TableName:  "factory.a",
+		PrimaryKey: "1",
+		NewData:    []byte{1, 2, 3},
+	})
+	tests := []struct {
+		name     string
+		policy   string
+		wantErr  bool
+		wantKeys []string
+	}{
+		{name: "fail", policy: FailUndecodableDBOp, wantErr: true},
+		{name: "skip", policy: SkipUndecodableDBOp, wantKeys: nil},
+		{name: "raw", policy: RawUndecodableDBOp, wantKeys: []string{"eosio.nft.ft:1"}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			generator := newTableGen4Test(t, "factory.a")
+			generator.undecodablePolicy = tt.policy
+			finder, err := buildTableExpressionFinder([]string{`factory.a={"filter":"table.new.id == 26","key":"string(table.new.id)"}`})
+			if err != nil {
+				t.Fatalf("buildTableExpressionFinder() error: %v", err)
+			}
+			generator.getExpression = finder
+			got, err := generator.doApply(gc)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("doApply() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			var keys []string
+			for _, g := range got {
+				keys = append(keys, g.Key)
+			}
+			if !reflect.DeepEqual(keys, tt.wantKeys) {
+				t.Errorf("doApply() keys = %v, want %v", keys, tt.wantKeys)
+			}
+		})
+	}
+}
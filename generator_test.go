@@ -254,8 +254,8 @@ func Test_ActionGenerator_Apply(t *testing.T) {
 			if err != nil {
 				t.Fatalf("Unmarshal() error: %v", err)
 			}
-			var localABIFiles = map[string]string{
-				"eosio.nft.ft": "testdata/eosio.nft.ft.abi",
+			var localABIFiles = map[string][]string{
+				"eosio.nft.ft": {"testdata/eosio.nft.ft.abi"},
 			}
 			abiFiles, err := LoadABIFiles(localABIFiles)
 			if err != nil {
@@ -0,0 +1,154 @@
+package dkafka
+
+import (
+	"fmt"
+)
+
+// JSONSchemaDialect is the JSON Schema version of the generated schemas
+const JSONSchemaDialect = "https://json-schema.org/draft/2020-12/schema"
+
+// decimalPattern matches the string representation of an avro decimal
+const decimalPattern = `^-?[0-9]+(\.[0-9]+)?$`
+
+// GenerateJSONSchema converts the avro message schema into the JSON Schema of
+// the JSON representation of the same messages. The named records are
+// defined once in $defs and referenced by their avro full name.
+// Lossy JSON types follow the avro logical types:
+// - timestamps are date-time strings
+// - decimals are strings of the decimal number
+// - bytes and fixed are base64 strings
+func GenerateJSONSchema(messageSchema MessageSchema) (map[string]interface{}, error) {
+	avro, err := parseAvroSchema(messageSchema.RecordSchema)
+	if err != nil {
+		return nil, err
+	}
+	root := avro.root.(map[string]interface{})
+	fullName := namedFullName(root, "")
+	g := jsonSchemaGenerator{avro: avro, root: fullName, defs: make(map[string]interface{})}
+	schema, err := g.object(root, avroNamespace(fullName))
+	if err != nil {
+		return nil, err
+	}
+	schema["$schema"] = JSONSchemaDialect
+	schema["title"] = fullName
+	if len(g.defs) > 0 {
+		schema["$defs"] = g.defs
+	}
+	return schema, nil
+}
+
+type jsonSchemaGenerator struct {
+	avro *avroSchema
+	root string
+	defs map[string]interface{}
+}
+
+func (g jsonSchemaGenerator) convert(schema interface{}, namespace string) (map[string]interface{}, error) {
+	if t, logical, ok := logicalType(schema); ok {
+		return jsonSchemaPrimitive(t, logical), nil
+	}
+	def, t, childNamespace := g.avro.resolve(schema, namespace)
+	switch t {
+	case "union":
+		branches := def.([]interface{})
+		anyOf := make([]interface{}, len(branches))
+		for i, branch := range branches {
+			converted, err := g.convert(branch, namespace)
+			if err != nil {
+				return nil, err
+			}
+			anyOf[i] = converted
+		}
+		return map[string]interface{}{"anyOf": anyOf}, nil
+	case "array":
+		items, err := g.convert(def.(map[string]interface{})["items"], namespace)
+		if err != nil {
+			return nil, err
+		}
+		return map[string]interface{}{"type": "array", "items": items}, nil
+	case "map":
+		values, err := g.convert(def.(map[string]interface{})["values"], namespace)
+		if err != nil {
+			return nil, err
+		}
+		return map[string]interface{}{"type": "object", "additionalProperties": values}, nil
+	case "fixed":
+		return jsonSchemaPrimitive("bytes", ""), nil
+	case "enum":
+		return map[string]interface{}{"enum": def.(map[string]interface{})["symbols"]}, nil
+	case "record", "error":
+		record := def.(map[string]interface{})
+		fullName := namedFullName(record, namespace)
+		if fullName == g.root {
+			// the root record is defined inline
+			return map[string]interface{}{"$ref": "#"}, nil
+		}
+		if _, defined := g.defs[fullName]; !defined {
+			// reserve the name for the recursive references
+			g.defs[fullName] = nil
+			object, err := g.object(record, childNamespace)
+			if err != nil {
+				return nil, err
+			}
+			g.defs[fullName] = object
+		}
+		return map[string]interface{}{"$ref": "#/$defs/" + fullName}, nil
+	}
+	if avroPrimitives[t] {
+		return jsonSchemaPrimitive(t, ""), nil
+	}
+	return nil, fmt.Errorf("unsupported avro type: '%s'", t)
+}
+
+func (g jsonSchemaGenerator) object(record map[string]interface{}, namespace string) (map[string]interface{}, error) {
+	properties := make(map[string]interface{})
+	required := []string{}
+	fields, _ := record["fields"].([]interface{})
+	for _, field := range fields {
+		f := field.(map[string]interface{})
+		name := f["name"].(string)
+		property, err := g.convert(f["type"], namespace)
+		if err != nil {
+			return nil, fmt.Errorf("field: '%s' of record: '%v', error: %w", name, record["name"], err)
+		}
+		if doc, ok := f["doc"].(string); ok && doc != "" {
+			property["description"] = doc
+		}
+		properties[name] = property
+		if _, hasDefault := f["default"]; !hasDefault {
+			required = append(required, name)
+		}
+	}
+	object := map[string]interface{}{
+		"type":                 "object",
+		"properties":           properties,
+		"required":             required,
+		"additionalProperties": false,
+	}
+	if doc, ok := record["doc"].(string); ok && doc != "" {
+		object["description"] = doc
+	}
+	return object, nil
+}
+
+func jsonSchemaPrimitive(t string, logical string) map[string]interface{} {
+	switch logical {
+	case "timestamp-millis", "timestamp-micros":
+		return map[string]interface{}{"type": "string", "format": "date-time"}
+	case "decimal":
+		return map[string]interface{}{"type": "string", "pattern": decimalPattern}
+	}
+	switch t {
+	case "boolean":
+		return map[string]interface{}{"type": "boolean"}
+	case "int", "long":
+		return map[string]interface{}{"type": "integer"}
+	case "float", "double":
+		return map[string]interface{}{"type": "number"}
+	case "bytes":
+		return map[string]interface{}{"type": "string", "contentEncoding": "base64"}
+	default:
+		// null and string
+		return map[string]interface{}{"type": t}
+	}
+}
@@ -0,0 +1,50 @@
+package dkafka
+
+import (
+	"testing"
+
+	"github.com/go-test/deep"
+)
+
+func TestGenerateJSONSchema(t *testing.T) {
+	abi, err := LoadABIFile("eosio.nft.ft", "testdata/eosio.nft.ft.abi")
+	if err != nil {
+		t.Fatalf("LoadABIFile() error: %v", err)
+	}
+	schema, err := MessageSchemaGenerator{Namespace: "test", Account: "eosio.nft.ft"}.getTableSchema("factory.a", abi)
+	if err != nil {
+		t.Fatalf("getTableSchema() error: %v", err)
+	}
+	got, err := GenerateJSONSchema(schema)
+	if err != nil {
+		t.Fatalf("GenerateJSONSchema() error: %v", err)
+	}
+	if got["$schema"] != JSONSchemaDialect {
+		t.Errorf("$schema = %v", got["$schema"])
+	}
+	if got["title"] != "test.eosio.nft.ft.tables.v0.FactoryATableNotification" {
+		t.Errorf("title = %v", got["title"])
+	}
+	properties := got["properties"].(map[string]interface{})
+	if diff := deep.Equal(properties["context"], map[string]interface{}{"$ref": "#/$defs/io.dkafka.NotificationContext"}); diff != nil {
+		t.Errorf("context diff: %v", diff)
+	}
+	defs := got["$defs"].(map[string]interface{})
+	context := defs["io.dkafka.NotificationContext"].(map[string]interface{})["properties"].(map[string]interface{})
+	if diff := deep.Equal(context["time"], map[string]interface{}{"type": "string", "format": "date-time"}); diff != nil {
+		t.Errorf("time diff: %v", diff)
+	}
+	dbOp := defs["test.eosio.nft.ft.tables.v0.FactoryATableOpInfo"].(map[string]interface{})
+	dbOpProperties := dbOp["properties"].(map[string]interface{})
+	if diff := deep.Equal(dbOpProperties["code"], map[string]interface{}{"anyOf": []interface{}{
+		map[string]interface{}{"type": "null"},
+		map[string]interface{}{"type": "string"},
+	}}); diff != nil {
+		t.Errorf("code diff: %v", diff)
+	}
+	for _, required := range dbOp["required"].([]string) {
+		if required == "code" {
+			t.Errorf("optional field code is required")
+		}
+	}
+}
@@ -0,0 +1,205 @@
+package dkafka
+
+import (
+	"encoding/base64"
+	"encoding/binary"
+	"encoding/json"
+	"fmt"
+	"math/big"
+	"net/url"
+	"strings"
+	"time"
+
+	"github.com/confluentinc/confluent-kafka-go/kafka"
+	"github.com/linkedin/goavro/v2"
+	"github.com/riferrei/srclient"
+	"github.com/santhosh-tekuri/jsonschema/v5"
+	"go.uber.org/zap"
+)
+
+// NewKafkaJsonSchemaCodec returns the codec of the JSON representation of the
+// messages validated by the registered JSON Schema. The values are normalized
+// by the avro codec of the same message schema so that the eos types get the
+// same conversions as with the avro codec.
+func NewKafkaJsonSchemaCodec(schemaRegistryURL string, schema *srclient.Schema, avroSchema Schema, codec *goavro.Codec) (Codec, error) {
+	avro, err := parseAvroSchema(avroSchema)
+	if err != nil {
+		return nil, err
+	}
+	compiler := jsonschema.NewCompiler()
+	compiler.Draft = jsonschema.Draft2020
+	compiler.AssertFormat = true
+	if err := compiler.AddResource("schema.json", strings.NewReader(schema.Schema())); err != nil {
+		return nil, fmt.Errorf("invalid json schema: %w", err)
+	}
+	validator, err := compiler.Compile("schema.json")
+	if err != nil {
+		return nil, fmt.Errorf("cannot compile json schema: %w", err)
+	}
+	u, _ := url.Parse(schemaRegistryURL)
+	u, _ = u.Parse(schemaByIDs)
+	t := fmt.Sprint(u, "%d")
+	return KafkaJsonSchemaCodec{
+		schemaURLTemplate: t,
+		id:                uint32(schema.ID()),
+		validator:         validator,
+		avro:              avro,
+		codec:             codec,
+	}, nil
+}
+
+type KafkaJsonSchemaCodec struct {
+	schemaURLTemplate string
+	id                uint32
+	validator         *jsonschema.Schema `deep:"-"`
+	avro              *avroSchema        `deep:"-"`
+	codec             *goavro.Codec      `deep:"-"`
+}
+
+func (c KafkaJsonSchemaCodec) Marshal(buf []byte, value interface{}) ([]byte, error) {
+	zlog.Debug("marshal value to json", zap.Uint32("schema_id", c.id))
+	avroBytes, err := c.codec.BinaryFromNative(nil, value)
+	if err != nil {
+		return buf, err
+	}
+	native, _, err := c.codec.NativeFromBinary(avroBytes)
+	if err != nil {
+		return buf, err
+	}
+	root := c.avro.root.(map[string]interface{})
+	jsonValue, err := c.jsonValue(root, avroNamespace(namedFullName(root, "")), native)
+	if err != nil {
+		return buf, err
+	}
+	if err := c.validator.Validate(jsonValue); err != nil {
+		return buf, fmt.Errorf("value does not match its json schema: %w", err)
+	}
+	bytes, err := json.Marshal(jsonValue)
+	if err != nil {
+		return buf, err
+	}
+	// magic byte 0 and schema id in int32 bigendian
+	header := make([]byte, 5)
+	binary.BigEndian.PutUint32(header[1:5], c.id)
+	buf = append(buf, header...)
+	return append(buf, bytes...), nil
+}
+
+func (c KafkaJsonSchemaCodec) Unmarshal(buf []byte) (interface{}, error) {
+	if len(buf) < 5 {
+		return nil, fmt.Errorf("invalid byte buffer it must at least have a length of 5 but: %d", len(buf))
+	}
+	if buf[0] != byte(0) {
+		return nil, fmt.Errorf("invalid magic byte at the beginning of the buffer must be 0 but: %d", buf[0])
+	}
+	if schemaId := binary.BigEndian.Uint32(buf[1:5]); c.id != schemaId {
+		return nil, fmt.Errorf("invalid schema id at the beginning of the buffer must be %d but: %d", c.id, schemaId)
+	}
+	value := make(map[string]interface{})
+	if err := json.Unmarshal(buf[5:], &value); err != nil {
+		return nil, err
+	}
+	return value, nil
+}
+
+func (c KafkaJsonSchemaCodec) GetHeaders() []kafka.Header {
+	u := fmt.Sprintf(c.schemaURLTemplate, c.id)
+	return append(jsonKafkaHeader[:len(jsonKafkaHeader):len(jsonKafkaHeader)], kafka.Header{
+		Key:   "ce_dataschema",
+		Value: []byte(u),
+	})
+}
+
+// jsonValue converts the avro native value by walking the avro schema with
+// the rules of GenerateJSONSchema.
+func (c KafkaJsonSchemaCodec) jsonValue(schema interface{}, namespace string, value interface{}) (interface{}, error) {
+	if value == nil {
+		return nil, nil
+	}
+	if _, _, ok := logicalType(schema); ok {
+		switch v := value.(type) {
+		case time.Time:
+			return v.UTC().Format(time.RFC3339Nano), nil
+		case *big.Rat:
+			return decimalString(schema, v), nil
+		}
+		return jsonPrimitive(value), nil
+	}
+	def, t, childNamespace := c.avro.resolve(schema, namespace)
+	switch t {
+	case "union":
+		union, ok := value.(map[string]interface{})
+		if !ok || len(union) != 1 {
+			return nil, fmt.Errorf("unsupported union value type: %T", value)
+		}
+		branches := nonNullBranches(def, t)
+		for key, v := range union {
+			i, ok := unionBranch(c.avro, branches, namespace, key)
+			if !ok {
+				return nil, fmt.Errorf("unknown union branch: %s", key)
+			}
+			return c.jsonValue(branches[i], namespace, v)
+		}
+	case "array":
+		items, ok := value.([]interface{})
+		if !ok {
+			return nil, fmt.Errorf("unsupported array value type: %T", value)
+		}
+		itemSchema := def.(map[string]interface{})["items"]
+		array := make([]interface{}, len(items))
+		for i, item := range items {
+			v, err := c.jsonValue(itemSchema, namespace, item)
+			if err != nil {
+				return nil, err
+			}
+			array[i] = v
+		}
+		return array, nil
+	case "map":
+		values, ok := value.(map[string]interface{})
+		if !ok {
+			return nil, fmt.Errorf("unsupported map value type: %T", value)
+		}
+		valueSchema := def.(map[string]interface{})["values"]
+		object := make(map[string]interface{}, len(values))
+		for key, item := range values {
+			v, err := c.jsonValue(valueSchema, namespace, item)
+			if err != nil {
+				return nil, err
+			}
+			object[key] = v
+		}
+		return object, nil
+	case "record", "error":
+		fields, ok := value.(map[string]interface{})
+		if !ok {
+			return nil, fmt.Errorf("unsupported record value type: %T", value)
+		}
+		record := def.(map[string]interface{})
+		avroFields, _ := record["fields"].([]interface{})
+		object := make(map[string]interface{}, len(avroFields))
+		for _, field := range avroFields {
+			f := field.(map[string]interface{})
+			name := f["name"].(string)
+			v, err := c.jsonValue(f["type"], childNamespace, fields[name])
+			if err != nil {
+				return nil, fmt.Errorf("field: '%s' of record: '%v', error: %w", name, record["name"], err)
+			}
+			object[name] = v
+		}
+		return object, nil
+	}
+	// enum, fixed and primitives
+	return jsonPrimitive(value), nil
+}
+
+func jsonPrimitive(value interface{}) interface{} {
+	switch v := value.(type) {
+	case []byte:
+		return base64.StdEncoding.EncodeToString(v)
+	case float32:
+		return float64(v)
+	default:
+		return value
+	}
+}
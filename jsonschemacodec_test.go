@@ -0,0 +1,178 @@
+package dkafka
+
+import (
+	"encoding/binary"
+	"testing"
+	"time"
+
+	pbcodec "github.com/dfuse-io/dfuse-eosio/pb/dfuse/eosio/codec/v1"
+	"github.com/eoscanada/eos-go"
+	"github.com/riferrei/srclient"
+	"github.com/santhosh-tekuri/jsonschema/v5"
+)
+
+func TestKafkaJsonSchemaCodec_TableNotification(t *testing.T) {
+	abi, err := LoadABIFile("eosio.nft.ft", "testdata/eosio.nft.ft.abi")
+	if err != nil {
+		t.Fatalf("LoadABIFile() error: %v", err)
+	}
+	registry := srclient.CreateMockSchemaRegistryClient("mock://TestKafkaJsonSchemaCodec_TableNotification")
+	abiCodec := NewStreamedAbiCodec(
+		&AbiRepositoryStub{abi: abi},
+		MessageSchemaGenerator{Namespace: "test", Account: "eosio.nft.ft"}.getTableSchema,
+		registry,
+		"eosio.nft.ft",
+		"mock://TestKafkaJsonSchemaCodec_TableNotification",
+		srclient.Forward,
+		WithJsonSchemaCodec(),
+	)
+	codec, err := abiCodec.GetCodec(CodecId{"eosio.nft.ft", "resale.a"}, 42)
+	if err != nil {
+		t.Fatalf("GetCodec() error: %v", err)
+	}
+	id := codec.(KafkaJsonSchemaCodec).id
+	schema, err := registry.GetSchema(int(id))
+	if err != nil {
+		t.Fatalf("GetSchema() error: %v", err)
+	}
+	if schemaType := schema.SchemaType(); schemaType == nil || *schemaType != srclient.Json {
+		t.Errorf("registered schema type = %v, want %s", schemaType, srclient.Json)
+	}
+	if contentType := findHeader("content-type", codec.GetHeaders()); contentType != "application/json" {
+		t.Errorf("content-type = %s, want application/json", contentType)
+	}
+
+	resale := func(price eos.Int64) []byte {
+		data, err := eos.MarshalBinary(struct {
+			TokenID            uint64
+			Owner              eos.Name
+			Price              eos.Asset
+			PromoterBasisPoint uint16
+		}{104, "owner", eos.Asset{Amount: price, Symbol: eos.Symbol{Precision: 8, Symbol: "UOS"}}, 5})
+		if err != nil {
+			t.Fatalf("MarshalBinary() error: %v", err)
+		}
+		return data
+	}
+	tests := []struct {
+		name         string
+		operation    pbcodec.DBOp_Operation
+		oldData      []byte
+		newData      []byte
+		wantOldPrice interface{}
+		wantNewPrice interface{}
+	}{
+		{
+			name:         "insert",
+			operation:    pbcodec.DBOp_OPERATION_INSERT,
+			newData:      resale(150_000_000),
+			wantNewPrice: "1.50000000",
+		},
+		{
+			name:         "update",
+			operation:    pbcodec.DBOp_OPERATION_UPDATE,
+			oldData:      resale(150_000_000),
+			newData:      resale(200_000_000),
+			wantOldPrice: "1.50000000",
+			wantNewPrice: "2.00000000",
+		},
+		{
+			name:         "remove",
+			operation:    pbcodec.DBOp_OPERATION_REMOVE,
+			oldData:      resale(150_000_000),
+			wantOldPrice: "1.50000000",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			gc := newActionContext4Test(t, &pbcodec.DBOp{
+				Operation:  tt.operation,
+				Code:       "eosio.nft.ft",
+				Scope:      "eosio.nft.ft",
+				TableName:  "resale.a",
+				PrimaryKey: "104",
+				OldData:    tt.oldData,
+				NewData:    tt.newData,
+			})
+			finder, _ := buildTableKeyExtractorFinder([]string{"*:k"})
+			g := TableGenerator{
+				getExtractKey:   finder,
+				abiCodec:        abiCodec,
+				targetedAccount: "eosio.nft.ft",
+			}
+			messages, err := g.Apply(gc)
+			if err != nil {
+				t.Fatalf("Apply() error: %v", err)
+			}
+			if len(messages) != 1 {
+				t.Fatalf("Apply() expected 1 message got: %d", len(messages))
+			}
+			bytes := messages[0].Value
+			if bytes[0] != 0 || binary.BigEndian.Uint32(bytes[1:5]) != id {
+				t.Errorf("invalid wire format header: %v, want [0 %d]", bytes[:5], id)
+			}
+			got, err := codec.Unmarshal(bytes)
+			if err != nil {
+				t.Fatalf("Unmarshal() error: %v", err)
+			}
+			dbOp := got.(map[string]interface{})["db_op"].(map[string]interface{})
+			if dbOp["table_name"] != "resale.a" {
+				t.Errorf("db_op.table_name = %v, want resale.a", dbOp["table_name"])
+			}
+			if time := got.(map[string]interface{})["context"].(map[string]interface{})["time"]; time != "2022-01-01T00:00:00Z" {
+				t.Errorf("context.time = %v, want 2022-01-01T00:00:00Z", time)
+			}
+			if price := jsonSchemaPrice(dbOp["old_json"]); price != tt.wantOldPrice {
+				t.Errorf("db_op.old_json.price.amount = %v, want %v", price, tt.wantOldPrice)
+			}
+			if price := jsonSchemaPrice(dbOp["new_json"]); price != tt.wantNewPrice {
+				t.Errorf("db_op.new_json.price.amount = %v, want %v", price, tt.wantNewPrice)
+			}
+		})
+	}
+}
+
+// jsonSchemaPrice returns the price amount of a resale.a row, nil without row
+func jsonSchemaPrice(row interface{}) interface{} {
+	if row == nil {
+		return nil
+	}
+	return row.(map[string]interface{})["price"].(map[string]interface{})["amount"]
+}
+
+func TestKafkaJsonSchemaCodec_Validation(t *testing.T) {
+	abiCodec := NewStreamedAbiCodec(
+		nil,
+		nil,
+		srclient.CreateMockSchemaRegistryClient("mock://TestKafkaJsonSchemaCodec_Validation"),
+		"",
+		"mock://TestKafkaJsonSchemaCodec_Validation",
+		srclient.Forward,
+		WithJsonSchemaCodec(),
+	)
+	codec, err := abiCodec.GetCodec(CheckpointMessageSchema.AsCodecId(), 0)
+	if err != nil {
+		t.Fatalf("GetCodec() error: %v", err)
+	}
+	checkpoint := map[string]interface{}{
+		"step":                  1,
+		"block":                 map[string]interface{}{"id": "block-42", "num": uint64(42)},
+		"headBlock":             map[string]interface{}{"id": "block-43", "num": uint64(43)},
+		"lastIrreversibleBlock": map[string]interface{}{"id": "block-41", "num": uint64(41)},
+		"time":                  time.Date(2022, 1, 1, 0, 0, 1, 0, time.UTC),
+	}
+	bytes, err := codec.Marshal(nil, checkpoint)
+	if err != nil {
+		t.Fatalf("Marshal() error: %v", err)
+	}
+	if _, err := codec.Unmarshal(append([]byte{0, 0, 0, 0, 0}, bytes[5:]...)); err == nil {
+		t.Errorf("Unmarshal() expected an error on an unknown schema id")
+	}
+
+	// the JSON value is validated against the registered schema
+	invalid := codec.(KafkaJsonSchemaCodec)
+	invalid.validator = jsonschema.MustCompileString("invalid.json", `{"type": "object", "required": ["missing"]}`)
+	if _, err := invalid.Marshal(nil, checkpoint); err == nil {
+		t.Errorf("Marshal() expected a validation error")
+	}
+}
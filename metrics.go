@@ -26,6 +26,14 @@ var (
 		Name: "dkafka_received_blocks",
 		Help: "The total number of blocks receivedfrom firehose",
 	})
+	undecodableDBOps = promauto.NewCounterVec(prometheus.CounterOpts{
+		Name: "dkafka_undecodable_db_ops",
+		Help: "The total number of DB operations that cannot be decoded with the ABI",
+	}, []string{"account", "table", "policy"})
+	abiHistoryDepth = promauto.NewGaugeVec(prometheus.GaugeOpts{
+		Name: "dkafka_abi_history_depth",
+		Help: "The number of ABI versions kept in memory per account",
+	}, []string{"account"})
 )
 
 func startPrometheusMetrics(path string, listenAddr string) {
@@ -0,0 +1,245 @@
+package dkafka
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/eoscanada/eos-go"
+	"go.uber.org/zap"
+)
+
+const (
+	AbicodecAbiSource = "abicodec"
+	NodeosAbiSource   = "nodeos"
+)
+
+// ParseAbiSource splits an ABI source definition '{kind}:{address}' where
+// kind is either abicodec (gRPC address) or nodeos (chain HTTP API url).
+func ParseAbiSource(source string) (kind string, address string, err error) {
+	kv := strings.SplitN(source, ":", 2)
+	if len(kv) != 2 || kv[1] == "" {
+		return "", "", fmt.Errorf("invalid abi source: '%s', expected format: {abicodec|nodeos}:{address}", source)
+	}
+	switch kind, address = kv[0], kv[1]; kind {
+	case AbicodecAbiSource, NodeosAbiSource:
+		return
+	default:
+		return "", "", fmt.Errorf("unsupported abi source: '%s' in: '%s'", kind, source)
+	}
+}
+
+// NodeosAbiRepository fetches the ABIs from the chain HTTP API of a nodeos
+// node. Nodeos only serves the ABI active at its head block, so a fetched ABI
+// is considered active from the block it was first requested at. The ABI hash
+// is used to keep the same version, and therefore the same schemas, as long as
+// the ABI does not change on chain.
+type NodeosAbiRepository struct {
+	url        string
+	client     *http.Client
+	retries    int
+	retryDelay time.Duration
+	overrides  map[string][]*ABI
+	known      map[string]nodeosAbi
+}
+
+type nodeosAbi struct {
+	hash string
+	abi  *ABI
+}
+
+func NewNodeosAbiRepository(url string, overrides map[string][]*ABI, timeout time.Duration, retries int) *NodeosAbiRepository {
+	return &NodeosAbiRepository{
+		url:        strings.TrimSuffix(url, "/"),
+		client:     &http.Client{Timeout: timeout},
+		retries:    retries,
+		retryDelay: time.Second,
+		overrides:  overrides,
+		known:      make(map[string]nodeosAbi),
+	}
+}
+
+func (a *NodeosAbiRepository) IsNOOP() bool {
+	return false
+}
+
+func (a *NodeosAbiRepository) overriddenVersions(contract string) []*ABI {
+	return a.overrides[contract]
+}
+
+type nodeosGetRawAbiRequest struct {
+	AccountName string `json:"account_name"`
+	AbiHash     string `json:"abi_hash,omitempty"`
+}
+
+type nodeosGetRawAbiResponse struct {
+	AccountName string `json:"account_name"`
+	AbiHash     string `json:"abi_hash"`
+	Abi         string `json:"abi"`
+}
+
+type nodeosGetAbiRequest struct {
+	AccountName string `json:"account_name"`
+}
+
+type nodeosGetAbiResponse struct {
+	AccountName string   `json:"account_name"`
+	Abi         *eos.ABI `json:"abi"`
+}
+
+// nodeosHTTPError is returned when nodeos replies with an error status
+type nodeosHTTPError struct {
+	StatusCode int
+	Body       string
+}
+
+func (e nodeosHTTPError) Error() string {
+	return fmt.Sprintf("nodeos replied with status: %d, body: %s", e.StatusCode, e.Body)
+}
+
+// nodeosErrorResponse is the body of a nodeos error reply, only the name of
+// the error is used
+type nodeosErrorResponse struct {
+	Error struct {
+		Name string `json:"name"`
+	} `json:"error"`
+}
+
+// nodeosPermanentErrors are the errors nodeos replies with a server error
+// status while retrying the call cannot change the outcome
+var nodeosPermanentErrors = map[string]bool{
+	"unknown_account":         true,
+	"account_query_exception": true,
+	"abi_not_found_exception": true,
+}
+
+// retryable tells if the call may succeed on retry: client errors and the
+// nodeos errors on the queried account are not retried.
+func (e nodeosHTTPError) retryable() bool {
+	if e.StatusCode < http.StatusInternalServerError {
+		return false
+	}
+	var body nodeosErrorResponse
+	if err := json.Unmarshal([]byte(e.Body), &body); err != nil {
+		return true
+	}
+	return !nodeosPermanentErrors[body.Error.Name]
+}
+
+var emptyAbiHash = strings.Repeat("0", 64)
+
+func (a *NodeosAbiRepository) GetAbi(contract string, blockNum uint32) (*ABI, error) {
+	if abi, ok := overrideAt(a.overrides, contract, blockNum); ok {
+		return abi, nil
+	}
+	known, isKnown := a.known[contract]
+	var raw nodeosGetRawAbiResponse
+	err := a.post("/v1/chain/get_raw_abi", nodeosGetRawAbiRequest{AccountName: contract, AbiHash: known.hash}, &raw)
+	if httpErr, ok := err.(nodeosHTTPError); ok && httpErr.StatusCode == http.StatusNotFound {
+		zlog.Info("get_raw_abi not available on nodeos fallback on get_abi", zap.String("url", a.url))
+		return a.getAbi(contract, blockNum)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("fail to get raw abi of contract %q from nodeos: %w", contract, err)
+	}
+	if raw.AbiHash == emptyAbiHash {
+		return nil, fmt.Errorf("no abi set on contract %q", contract)
+	}
+	if isKnown && raw.AbiHash == known.hash {
+		zlog.Debug("abi unchanged on nodeos", zap.String("contract", contract), zap.String("abi_hash", raw.AbiHash))
+		return known.abi, nil
+	}
+	abiData, err := decodeNodeosBase64(raw.Abi)
+	if err != nil {
+		return nil, fmt.Errorf("fail to decode raw abi of contract %q: %w", contract, err)
+	}
+	hash := sha256.Sum256(abiData)
+	if actual := hex.EncodeToString(hash[:]); actual != raw.AbiHash {
+		return nil, fmt.Errorf("raw abi hash mismatch for contract %q, expected: %s, actual: %s", contract, raw.AbiHash, actual)
+	}
+	var eosAbi *eos.ABI
+	if err = eos.UnmarshalBinary(abiData, &eosAbi); err != nil {
+		return nil, fmt.Errorf("fail to unmarshal raw abi of contract %q: %w", contract, err)
+	}
+	return a.loaded(contract, blockNum, raw.AbiHash, eosAbi), nil
+}
+
+// getAbi uses the get_abi endpoint that returns the JSON representation of
+// the ABI without its hash.
+func (a *NodeosAbiRepository) getAbi(contract string, blockNum uint32) (*ABI, error) {
+	var resp nodeosGetAbiResponse
+	if err := a.post("/v1/chain/get_abi", nodeosGetAbiRequest{AccountName: contract}, &resp); err != nil {
+		return nil, fmt.Errorf("fail to get abi of contract %q from nodeos: %w", contract, err)
+	}
+	if resp.Abi == nil {
+		return nil, fmt.Errorf("no abi set on contract %q", contract)
+	}
+	content, err := json.Marshal(resp.Abi)
+	if err != nil {
+		return nil, fmt.Errorf("fail to encode abi of contract %q: %w", contract, err)
+	}
+	hash := sha256.Sum256(content)
+	jsonHash := "json:" + hex.EncodeToString(hash[:])
+	if known, isKnown := a.known[contract]; isKnown && known.hash == jsonHash {
+		return known.abi, nil
+	}
+	return a.loaded(contract, blockNum, jsonHash, resp.Abi), nil
+}
+
+func (a *NodeosAbiRepository) loaded(contract string, blockNum uint32, hash string, eosAbi *eos.ABI) *ABI {
+	abi := &ABI{eosAbi, blockNum, contract, false}
+	a.known[contract] = nodeosAbi{hash: hash, abi: abi}
+	zlog.Info("new ABI loaded from nodeos", zap.String("contract", contract), zap.Uint32("block_num", blockNum), zap.String("abi_hash", hash))
+	return abi
+}
+
+// nodeos base64 encoding may omit the padding
+func decodeNodeosBase64(s string) ([]byte, error) {
+	return base64.RawStdEncoding.DecodeString(strings.TrimRight(s, "="))
+}
+
+// post calls the nodeos endpoint and retries on network and server errors,
+// except the nodeos errors on the queried account.
+func (a *NodeosAbiRepository) post(path string, request interface{}, response interface{}) error {
+	body, err := json.Marshal(request)
+	if err != nil {
+		return err
+	}
+	for attempt := 0; ; attempt++ {
+		err = a.doPost(path, body, response)
+		if err == nil {
+			return nil
+		}
+		if httpErr, ok := err.(nodeosHTTPError); ok && !httpErr.retryable() {
+			return err
+		}
+		if attempt >= a.retries {
+			return fmt.Errorf("giving up after %d attempt(s): %w", attempt+1, err)
+		}
+		zlog.Warn("nodeos call failed retrying", zap.String("url", a.url+path), zap.Int("attempt", attempt+1), zap.Error(err))
+		time.Sleep(a.retryDelay * time.Duration(attempt+1))
+	}
+}
+
+func (a *NodeosAbiRepository) doPost(path string, body []byte, response interface{}) error {
+	resp, err := a.client.Post(a.url+path, "application/json", bytes.NewReader(body))
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	content, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return err
+	}
+	if resp.StatusCode != http.StatusOK {
+		return nodeosHTTPError{StatusCode: resp.StatusCode, Body: string(content)}
+	}
+	return json.Unmarshal(content, response)
+}
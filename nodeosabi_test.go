@@ -0,0 +1,220 @@
+package dkafka
+
+import (
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/eoscanada/eos-go"
+)
+
+type nodeosStub struct {
+	rawAbi     []byte
+	abiHash    string
+	noRawAbi   bool
+	failures   int
+	errorName  string
+	calls      map[string]int
+	lastHashes []string
+}
+
+func (s *nodeosStub) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	s.calls[r.URL.Path]++
+	if s.failures > 0 {
+		s.failures--
+		w.WriteHeader(http.StatusServiceUnavailable)
+		return
+	}
+	if s.errorName != "" {
+		w.WriteHeader(http.StatusInternalServerError)
+		fmt.Fprintf(w, `{"code":500,"message":"Internal Service Error","error":{"code":3060002,"name":%q}}`, s.errorName)
+		return
+	}
+	switch r.URL.Path {
+	case "/v1/chain/get_raw_abi":
+		if s.noRawAbi {
+			w.WriteHeader(http.StatusNotFound)
+			return
+		}
+		var req nodeosGetRawAbiRequest
+		json.NewDecoder(r.Body).Decode(&req)
+		s.lastHashes = append(s.lastHashes, req.AbiHash)
+		resp := nodeosGetRawAbiResponse{AccountName: req.AccountName, AbiHash: s.abiHash}
+		if req.AbiHash != s.abiHash {
+			resp.Abi = base64.StdEncoding.EncodeToString(s.rawAbi)
+		}
+		json.NewEncoder(w).Encode(resp)
+	case "/v1/chain/get_abi":
+		var req nodeosGetAbiRequest
+		json.NewDecoder(r.Body).Decode(&req)
+		var abi *eos.ABI
+		if err := eos.UnmarshalBinary(s.rawAbi, &abi); err != nil {
+			w.WriteHeader(http.StatusInternalServerError)
+			return
+		}
+		json.NewEncoder(w).Encode(nodeosGetAbiResponse{AccountName: req.AccountName, Abi: abi})
+	default:
+		w.WriteHeader(http.StatusNotFound)
+	}
+}
+
+func newNodeosStub(t *testing.T) *nodeosStub {
+	rawAbi, err := hex.DecodeString(strings.TrimSpace(string(readFileFromTestdata(t, "testdata/abi.hex"))))
+	if err != nil {
+		t.Fatalf("hex.DecodeString() error: %v", err)
+	}
+	hash := sha256.Sum256(rawAbi)
+	return &nodeosStub{rawAbi: rawAbi, abiHash: hex.EncodeToString(hash[:]), calls: make(map[string]int)}
+}
+
+func newTestNodeosAbiRepository(url string, overrides map[string][]*ABI) *NodeosAbiRepository {
+	r := NewNodeosAbiRepository(url, overrides, time.Second, 2)
+	r.retryDelay = time.Millisecond
+	return r
+}
+
+func TestNodeosAbiRepository_GetAbi(t *testing.T) {
+	tests := []struct {
+		name          string
+		setup         func(s *nodeosStub)
+		overrides     map[string][]*ABI
+		wantErr       bool
+		wantVersion   string
+		wantRawCalls  int
+		wantJSONCalls int
+	}{
+		{
+			name:         "raw abi",
+			wantVersion:  "eosio::abi/1.1",
+			wantRawCalls: 1,
+		},
+		{
+			name:         "retry on server error",
+			setup:        func(s *nodeosStub) { s.failures = 2 },
+			wantVersion:  "eosio::abi/1.1",
+			wantRawCalls: 3,
+		},
+		{
+			name:         "give up after retries",
+			setup:        func(s *nodeosStub) { s.failures = 3 },
+			wantErr:      true,
+			wantRawCalls: 3,
+		},
+		{
+			name:         "retry on nodeos internal error",
+			setup:        func(s *nodeosStub) { s.errorName = "timeout_exception" },
+			wantErr:      true,
+			wantRawCalls: 3,
+		},
+		{
+			name:         "no retry on unknown account",
+			setup:        func(s *nodeosStub) { s.errorName = "account_query_exception" },
+			wantErr:      true,
+			wantRawCalls: 1,
+		},
+		{
+			name:         "hash mismatch",
+			setup:        func(s *nodeosStub) { s.abiHash = strings.Repeat("1", 64) },
+			wantErr:      true,
+			wantRawCalls: 1,
+		},
+		{
+			name:         "no abi set",
+			setup:        func(s *nodeosStub) { s.abiHash = emptyAbiHash; s.rawAbi = nil },
+			wantErr:      true,
+			wantRawCalls: 1,
+		},
+		{
+			name:          "fallback on get_abi",
+			setup:         func(s *nodeosStub) { s.noRawAbi = true },
+			wantVersion:   "eosio::abi/1.1",
+			wantRawCalls:  1,
+			wantJSONCalls: 1,
+		},
+		{
+			name: "override",
+			overrides: map[string][]*ABI{
+				"eosio.nft.ft": {{ABI: &eos.ABI{Version: "override"}, Account: "eosio.nft.ft"}},
+			},
+			wantVersion: "override",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			stub := newNodeosStub(t)
+			if tt.setup != nil {
+				tt.setup(stub)
+			}
+			server := httptest.NewServer(stub)
+			defer server.Close()
+			r := newTestNodeosAbiRepository(server.URL, tt.overrides)
+			abi, err := r.GetAbi("eosio.nft.ft", 42)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("NodeosAbiRepository.GetAbi() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if !tt.wantErr && abi.Version != tt.wantVersion {
+				t.Errorf("NodeosAbiRepository.GetAbi() version = %v, want %v", abi.Version, tt.wantVersion)
+			}
+			if got := stub.calls["/v1/chain/get_raw_abi"]; got != tt.wantRawCalls {
+				t.Errorf("get_raw_abi calls = %v, want %v", got, tt.wantRawCalls)
+			}
+			if got := stub.calls["/v1/chain/get_abi"]; got != tt.wantJSONCalls {
+				t.Errorf("get_abi calls = %v, want %v", got, tt.wantJSONCalls)
+			}
+		})
+	}
+}
+
+func TestNodeosAbiRepository_GetAbi_unchanged(t *testing.T) {
+	stub := newNodeosStub(t)
+	server := httptest.NewServer(stub)
+	defer server.Close()
+	r := newTestNodeosAbiRepository(server.URL, nil)
+	first, err := r.GetAbi("eosio.nft.ft", 42)
+	if err != nil {
+		t.Fatalf("NodeosAbiRepository.GetAbi() error: %v", err)
+	}
+	second, err := r.GetAbi("eosio.nft.ft", 100)
+	if err != nil {
+		t.Fatalf("NodeosAbiRepository.GetAbi() error: %v", err)
+	}
+	if first != second || second.AbiBlockNum != 42 {
+		t.Errorf("unchanged abi should keep its first activation block, got: %d", second.AbiBlockNum)
+	}
+	if stub.lastHashes[1] != stub.abiHash {
+		t.Errorf("known abi hash should be sent to nodeos, got: %q", stub.lastHashes[1])
+	}
+}
+
+func TestParseAbiSource(t *testing.T) {
+	tests := []struct {
+		source      string
+		wantKind    string
+		wantAddress string
+		wantErr     bool
+	}{
+		{"nodeos:http://localhost:8888", NodeosAbiSource, "http://localhost:8888", false},
+		{"abicodec:localhost:9001", AbicodecAbiSource, "localhost:9001", false},
+		{"nodeos:", "", "", true},
+		{"unknown:http://localhost:8888", "", "", true},
+		{"http://localhost:8888", "", "", true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.source, func(t *testing.T) {
+			kind, address, err := ParseAbiSource(tt.source)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("ParseAbiSource() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if kind != tt.wantKind || address != tt.wantAddress {
+				t.Errorf("ParseAbiSource() = %v, %v, want %v, %v", kind, address, tt.wantKind, tt.wantAddress)
+			}
+		})
+	}
+}
@@ -0,0 +1,415 @@
+package dkafka
+
+import (
+	"encoding/binary"
+	"fmt"
+	"math/big"
+	"net/url"
+	"strings"
+	"time"
+
+	"github.com/confluentinc/confluent-kafka-go/kafka"
+	protov1 "github.com/golang/protobuf/proto"
+	"github.com/linkedin/goavro/v2"
+	"github.com/riferrei/srclient"
+	"go.uber.org/zap"
+	"google.golang.org/protobuf/proto"
+	"google.golang.org/protobuf/reflect/protoreflect"
+	"google.golang.org/protobuf/types/dynamicpb"
+)
+
+// NewKafkaProtobufCodec returns the codec of the notification message, the
+// first message of the registered protobuf schema. The values are normalized
+// by the avro codec of the same message schema so that the eos types get the
+// same conversions as with the avro codec.
+func NewKafkaProtobufCodec(schemaRegistryURL string, schema *srclient.Schema, descriptor protoreflect.MessageDescriptor, avroSchema Schema, codec *goavro.Codec) (Codec, error) {
+	avro, err := parseAvroSchema(avroSchema)
+	if err != nil {
+		return nil, err
+	}
+	u, _ := url.Parse(schemaRegistryURL)
+	u, _ = u.Parse(schemaByIDs)
+	t := fmt.Sprint(u, "%d")
+	return KafkaProtobufCodec{
+		schemaURLTemplate: t,
+		id:                uint32(schema.ID()),
+		descriptor:        descriptor,
+		avro:              avro,
+		codec:             codec,
+	}, nil
+}
+
+type KafkaProtobufCodec struct {
+	schemaURLTemplate string
+	id                uint32
+	descriptor        protoreflect.MessageDescriptor `deep:"-"`
+	avro              *avroSchema                    `deep:"-"`
+	codec             *goavro.Codec                  `deep:"-"`
+}
+
+func (c KafkaProtobufCodec) Marshal(buf []byte, value interface{}) ([]byte, error) {
+	zlog.Debug("marshal value to protobuf", zap.Uint32("schema_id", c.id))
+	avroBytes, err := c.codec.BinaryFromNative(nil, value)
+	if err != nil {
+		return buf, err
+	}
+	native, _, err := c.codec.NativeFromBinary(avroBytes)
+	if err != nil {
+		return buf, err
+	}
+	message := dynamicpb.NewMessage(c.descriptor)
+	root := c.avro.root.(map[string]interface{})
+	if err := c.fillRecord(message, root, avroNamespace(namedFullName(root, "")), native); err != nil {
+		return buf, err
+	}
+	bytes, err := proto.Marshal(message)
+	if err != nil {
+		return buf, err
+	}
+	// magic byte 0, schema id in int32 bigendian and the message indexes
+	// where [0] (first message of the schema) is encoded as a single 0
+	header := make([]byte, 6)
+	binary.BigEndian.PutUint32(header[1:5], c.id)
+	buf = append(buf, header...)
+	return append(buf, bytes...), nil
+}
+
+func (c KafkaProtobufCodec) Unmarshal(buf []byte) (interface{}, error) {
+	if len(buf) < 6 {
+		return nil, fmt.Errorf("invalid byte buffer it must at least have a length of 6 but: %d", len(buf))
+	}
+	if buf[0] != byte(0) {
+		return nil, fmt.Errorf("invalid magic byte at the beginning of the buffer must be 0 but: %d", buf[0])
+	}
+	if schemaId := binary.BigEndian.Uint32(buf[1:5]); c.id != schemaId {
+		return nil, fmt.Errorf("invalid schema id at the beginning of the buffer must be %d but: %d", c.id, schemaId)
+	}
+	if buf[5] != byte(0) {
+		return nil, fmt.Errorf("invalid message indexes, only the first message of the schema is supported")
+	}
+	message := dynamicpb.NewMessage(c.descriptor)
+	if err := proto.Unmarshal(buf[6:], message); err != nil {
+		return nil, err
+	}
+	return message, nil
+}
+
+var protobufKafkaHeader []kafka.Header = []kafka.Header{
+	{
+		Key:   "content-type",
+		Value: []byte("application/x-protobuf"),
+	},
+	{
+		Key:   "ce_datacontenttype",
+		Value: []byte("application/x-protobuf"),
+	},
+}
+
+func (c KafkaProtobufCodec) GetHeaders() []kafka.Header {
+	u := fmt.Sprintf(c.schemaURLTemplate, c.id)
+	return append(protobufKafkaHeader[:len(protobufKafkaHeader):len(protobufKafkaHeader)], kafka.Header{
+		Key:   "ce_dataschema",
+		Value: []byte(u),
+	})
+}
+
+// The avro native value is converted by walking the avro schema with the
+// rules of GenerateProtoSchema.
+
+func (c KafkaProtobufCodec) fillRecord(message protoreflect.Message, record map[string]interface{}, namespace string, value interface{}) error {
+	fields, ok := value.(map[string]interface{})
+	if !ok {
+		return fmt.Errorf("unsupported record value type: %T for record: %v", value, record["name"])
+	}
+	avroFields, _ := record["fields"].([]interface{})
+	for i, field := range avroFields {
+		f := field.(map[string]interface{})
+		name := f["name"].(string)
+		fd := message.Descriptor().Fields().ByNumber(protoreflect.FieldNumber(i + 1))
+		if err := c.setField(message, fd, f["type"], namespace, fields[name]); err != nil {
+			return fmt.Errorf("field: '%s' of record: '%v', error: %w", name, record["name"], err)
+		}
+	}
+	return nil
+}
+
+func (c KafkaProtobufCodec) setField(message protoreflect.Message, fd protoreflect.FieldDescriptor, schema interface{}, namespace string, value interface{}) error {
+	if value == nil {
+		return nil
+	}
+	def, t, _ := c.avro.resolve(schema, namespace)
+	if branches := nonNullBranches(def, t); len(branches) == 1 {
+		// optional value
+		return c.setField(message, fd, branches[0], namespace, unionValue(value))
+	}
+	if fd.IsList() {
+		items, ok := value.([]interface{})
+		if !ok {
+			return fmt.Errorf("unsupported array value type: %T", value)
+		}
+		list := message.Mutable(fd).List()
+		itemSchema := def.(map[string]interface{})["items"]
+		for _, item := range items {
+			v, err := c.value(fd, list.NewElement, itemSchema, namespace, item)
+			if err != nil {
+				return err
+			}
+			list.Append(v)
+		}
+		return nil
+	}
+	v, err := c.value(fd, func() protoreflect.Value { return message.NewField(fd) }, schema, namespace, value)
+	if err != nil {
+		return err
+	}
+	message.Set(fd, v)
+	return nil
+}
+
+// value returns the single value of the field, newValue returns a new value
+// of the field message
+func (c KafkaProtobufCodec) value(fd protoreflect.FieldDescriptor, newValue func() protoreflect.Value, schema interface{}, namespace string, value interface{}) (protoreflect.Value, error) {
+	if fd.Kind() != protoreflect.MessageKind {
+		return protoScalar(fd, schema, value)
+	}
+	message := newValue().Message()
+	if _, logical, ok := logicalType(schema); ok && strings.HasPrefix(logical, "timestamp-") {
+		ts, ok := value.(time.Time)
+		if !ok {
+			return protoreflect.Value{}, fmt.Errorf("unsupported timestamp value type: %T", value)
+		}
+		message.Set(message.Descriptor().Fields().ByName("seconds"), protoreflect.ValueOfInt64(ts.Unix()))
+		message.Set(message.Descriptor().Fields().ByName("nanos"), protoreflect.ValueOfInt32(int32(ts.Nanosecond())))
+		return protoreflect.ValueOfMessage(message), nil
+	}
+	def, t, childNamespace := c.avro.resolve(schema, namespace)
+	switch branches := nonNullBranches(def, t); {
+	case t == "record" || t == "error":
+		if err := c.fillRecord(message, def.(map[string]interface{}), childNamespace, value); err != nil {
+			return protoreflect.Value{}, err
+		}
+	case len(branches) > 1:
+		if err := c.fillUnion(message, branches, namespace, value); err != nil {
+			return protoreflect.Value{}, err
+		}
+	case len(branches) == 1 && protoMessage(c.avro, branches[0], namespace):
+		// optional message item
+		if value == nil {
+			break
+		}
+		return c.value(fd, func() protoreflect.Value { return protoreflect.ValueOfMessage(message) }, branches[0], namespace, unionValue(value))
+	default:
+		// wrapper message of an array or of an optional item
+		if err := c.setField(message, message.Descriptor().Fields().ByNumber(1), schema, namespace, value); err != nil {
+			return protoreflect.Value{}, err
+		}
+	}
+	return protoreflect.ValueOfMessage(message), nil
+}
+
+func (c KafkaProtobufCodec) fillUnion(message protoreflect.Message, branches []interface{}, namespace string, value interface{}) error {
+	if value == nil {
+		return nil
+	}
+	union, ok := value.(map[string]interface{})
+	if !ok || len(union) != 1 {
+		return fmt.Errorf("unsupported union value type: %T", value)
+	}
+	for key, v := range union {
+		i, ok := unionBranch(c.avro, branches, namespace, key)
+		if !ok {
+			return fmt.Errorf("unknown union branch: %s", key)
+		}
+		return c.setField(message, message.Descriptor().Fields().ByNumber(protoreflect.FieldNumber(i+1)), branches[i], namespace, v)
+	}
+	return nil
+}
+
+func nonNullBranches(def interface{}, t string) []interface{} {
+	if t != "union" {
+		return nil
+	}
+	var branches []interface{}
+	for _, branch := range def.([]interface{}) {
+		if branch != "null" {
+			branches = append(branches, branch)
+		}
+	}
+	return branches
+}
+
+// unionValue returns the value of a goavro union value
+func unionValue(value interface{}) interface{} {
+	if union, ok := value.(map[string]interface{}); ok && len(union) == 1 {
+		for _, v := range union {
+			return v
+		}
+	}
+	return value
+}
+
+// protoMessage tells if the avro type is directly a message, not wrapped
+func protoMessage(avro *avroSchema, schema interface{}, namespace string) bool {
+	if _, logical, ok := logicalType(schema); ok {
+		return strings.HasPrefix(logical, "timestamp-")
+	}
+	def, t, _ := avro.resolve(schema, namespace)
+	return t == "record" || t == "error" || len(nonNullBranches(def, t)) > 1
+}
+
+// unionBranch returns the index of the branch of a goavro union value key
+func unionBranch(avro *avroSchema, branches []interface{}, namespace string, key string) (int, bool) {
+	for i, branch := range branches {
+		if name := avroUnionName(avro, branch, namespace); key == name || strings.HasPrefix(key, name+".") {
+			return i, true
+		}
+	}
+	return 0, false
+}
+
+// avroUnionName returns the goavro name of an union branch
+func avroUnionName(avro *avroSchema, branch interface{}, namespace string) string {
+	if t, logical, ok := logicalType(branch); ok {
+		if logical != "" {
+			return t + "." + logical
+		}
+		return t
+	}
+	def, t, _ := avro.resolve(branch, namespace)
+	switch t {
+	case "record", "error", "enum", "fixed":
+		return namedFullName(def.(map[string]interface{}), namespace)
+	}
+	return t
+}
+
+func protoScalar(fd protoreflect.FieldDescriptor, schema interface{}, value interface{}) (protoreflect.Value, error) {
+	switch v := value.(type) {
+	case bool:
+		return protoreflect.ValueOfBool(v), nil
+	case int32:
+		return protoreflect.ValueOfInt32(v), nil
+	case int64:
+		return protoreflect.ValueOfInt64(v), nil
+	case float32:
+		return protoreflect.ValueOfFloat32(v), nil
+	case float64:
+		return protoreflect.ValueOfFloat64(v), nil
+	case string:
+		return protoreflect.ValueOfString(v), nil
+	case []byte:
+		return protoreflect.ValueOfBytes(v), nil
+	case *big.Rat:
+		return protoreflect.ValueOfString(decimalString(schema, v)), nil
+	default:
+		return protoreflect.Value{}, fmt.Errorf("unsupported value type: %T for field: %s", value, fd.FullName())
+	}
+}
+
+// decimalString returns the decimal number of an avro decimal value with the
+// scale of its schema
+func decimalString(schema interface{}, value *big.Rat) string {
+	scale := 0
+	if typed, ok := schema.(map[string]interface{}); ok {
+		if s, ok := typed["scale"].(float64); ok {
+			scale = int(s)
+		}
+	}
+	return value.FloatString(scale)
+}
+
+// NewKafkaRawProtobufCodec returns the codec of a protobuf message published
+// as is, like the firehose transaction traces of the raw cdc.
+func NewKafkaRawProtobufCodec(schemaRegistryURL string, schema *srclient.Schema, descriptor protoreflect.MessageDescriptor) Codec {
+	u, _ := url.Parse(schemaRegistryURL)
+	u, _ = u.Parse(schemaByIDs)
+	t := fmt.Sprint(u, "%d")
+	return KafkaRawProtobufCodec{
+		schemaURLTemplate: t,
+		id:                uint32(schema.ID()),
+		indexes:           protoMessageIndexes(descriptor),
+		descriptor:        descriptor,
+	}
+}
+
+type KafkaRawProtobufCodec struct {
+	schemaURLTemplate string
+	id                uint32
+	indexes           []byte
+	descriptor        protoreflect.MessageDescriptor `deep:"-"`
+}
+
+func (c KafkaRawProtobufCodec) Marshal(buf []byte, value interface{}) ([]byte, error) {
+	zlog.Debug("marshal raw value to protobuf", zap.Uint32("schema_id", c.id))
+	var message proto.Message
+	switch v := value.(type) {
+	case proto.Message:
+		message = v
+	case protov1.Message:
+		message = protov1.MessageV2(v)
+	default:
+		return buf, fmt.Errorf("unsupported protobuf message type: %T", value)
+	}
+	if message.ProtoReflect().Descriptor().FullName() != c.descriptor.FullName() {
+		return buf, fmt.Errorf("invalid protobuf message: %s, expected: %s", message.ProtoReflect().Descriptor().FullName(), c.descriptor.FullName())
+	}
+	bytes, err := proto.Marshal(message)
+	if err != nil {
+		return buf, err
+	}
+	// magic byte 0, schema id in int32 bigendian and the message indexes
+	header := make([]byte, 5)
+	binary.BigEndian.PutUint32(header[1:5], c.id)
+	buf = append(buf, header...)
+	buf = append(buf, c.indexes...)
+	return append(buf, bytes...), nil
+}
+
+func (c KafkaRawProtobufCodec) Unmarshal(buf []byte) (interface{}, error) {
+	if len(buf) < 5+len(c.indexes) {
+		return nil, fmt.Errorf("invalid byte buffer it must at least have a length of %d but: %d", 5+len(c.indexes), len(buf))
+	}
+	if buf[0] != byte(0) {
+		return nil, fmt.Errorf("invalid magic byte at the beginning of the buffer must be 0 but: %d", buf[0])
+	}
+	if schemaId := binary.BigEndian.Uint32(buf[1:5]); c.id != schemaId {
+		return nil, fmt.Errorf("invalid schema id at the beginning of the buffer must be %d but: %d", c.id, schemaId)
+	}
+	if indexes := buf[5 : 5+len(c.indexes)]; string(indexes) != string(c.indexes) {
+		return nil, fmt.Errorf("invalid message indexes: %v, expected: %v", indexes, c.indexes)
+	}
+	message := dynamicpb.NewMessage(c.descriptor)
+	if err := proto.Unmarshal(buf[5+len(c.indexes):], message); err != nil {
+		return nil, err
+	}
+	return message, nil
+}
+
+func (c KafkaRawProtobufCodec) GetHeaders() []kafka.Header {
+	u := fmt.Sprintf(c.schemaURLTemplate, c.id)
+	return append(protobufKafkaHeader[:len(protobufKafkaHeader):len(protobufKafkaHeader)], kafka.Header{
+		Key:   "ce_dataschema",
+		Value: []byte(u),
+	})
+}
+
+// protoMessageIndexes returns the schema registry encoding of the path of the
+// message in its file: the zigzag varint count of indexes followed by the
+// zigzag varint indexes, [0] being encoded as a single 0.
+func protoMessageIndexes(descriptor protoreflect.MessageDescriptor) []byte {
+	var path []int
+	for d := protoreflect.Descriptor(descriptor); d != nil; d = d.Parent() {
+		if _, ok := d.(protoreflect.MessageDescriptor); !ok {
+			break
+		}
+		path = append([]int{d.Index()}, path...)
+	}
+	if len(path) == 1 && path[0] == 0 {
+		return []byte{0}
+	}
+	indexes := binary.AppendVarint(nil, int64(len(path)))
+	for _, index := range path {
+		indexes = binary.AppendVarint(indexes, int64(index))
+	}
+	return indexes
+}
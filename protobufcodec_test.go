@@ -0,0 +1,134 @@
+package dkafka
+
+import (
+	"encoding/binary"
+	"testing"
+	"time"
+
+	"github.com/riferrei/srclient"
+	"google.golang.org/protobuf/reflect/protoreflect"
+	"google.golang.org/protobuf/types/dynamicpb"
+)
+
+func TestKafkaProtobufCodec_TableNotification(t *testing.T) {
+	abi, err := LoadABIFile("eosio.nft.ft", "testdata/eosio.nft.ft.abi")
+	if err != nil {
+		t.Fatalf("LoadABIFile() error: %v", err)
+	}
+	gc := newActionContext4Test(t)
+	registry := srclient.CreateMockSchemaRegistryClient("mock://TestKafkaProtobufCodec_TableNotification")
+	abiCodec := NewStreamedAbiCodec(
+		&AbiRepositoryStub{abi: abi},
+		MessageSchemaGenerator{Namespace: "test", Account: "eosio.nft.ft"}.getTableSchema,
+		registry,
+		"eosio.nft.ft",
+		"mock://TestKafkaProtobufCodec_TableNotification",
+		srclient.Forward,
+		WithProtobufCodec(),
+	)
+	finder, _ := buildTableKeyExtractorFinder([]string{"*:k"})
+	g := TableGenerator{
+		getExtractKey:   finder,
+		abiCodec:        abiCodec,
+		targetedAccount: "eosio.nft.ft",
+	}
+	messages, err := g.Apply(gc)
+	if err != nil {
+		t.Fatalf("Apply() error: %v", err)
+	}
+	if len(messages) != 1 {
+		t.Fatalf("Apply() expected 1 message got: %d", len(messages))
+	}
+	msg := messages[0]
+	if contentType := findHeader("content-type", msg.Headers); contentType != "application/x-protobuf" {
+		t.Errorf("content-type = %s, want application/x-protobuf", contentType)
+	}
+	codec, err := abiCodec.GetCodec(CodecId{"eosio.nft.ft", "resale.a"}, 42)
+	if err != nil {
+		t.Fatalf("GetCodec() error: %v", err)
+	}
+	id := codec.(KafkaProtobufCodec).id
+	if msg.Value[0] != 0 || binary.BigEndian.Uint32(msg.Value[1:5]) != id || msg.Value[5] != 0 {
+		t.Errorf("invalid wire format header: %v, want [0 %d 0]", msg.Value[:6], id)
+	}
+	schema, err := registry.GetSchema(int(id))
+	if err != nil {
+		t.Fatalf("GetSchema() error: %v", err)
+	}
+	if schemaType := schema.SchemaType(); schemaType == nil || *schemaType != srclient.Protobuf {
+		t.Errorf("registered schema type = %v, want %s", schemaType, srclient.Protobuf)
+	}
+
+	value, err := codec.Unmarshal(msg.Value)
+	if err != nil {
+		t.Fatalf("Unmarshal() error: %v", err)
+	}
+	message := value.(*dynamicpb.Message)
+	dbOp := protoField(t, message, "db_op").Message()
+	if got := protoField(t, dbOp, "table_name").String(); got != "resale.a" {
+		t.Errorf("db_op.table_name = %s, want resale.a", got)
+	}
+	newJSON := protoField(t, dbOp, "new_json").Message()
+	if got := protoField(t, newJSON, "owner").String(); got != "owner" {
+		t.Errorf("db_op.new_json.owner = %s, want owner", got)
+	}
+	price := protoField(t, newJSON, "price").Message()
+	if got := protoField(t, price, "amount").String(); got != "1.50000000" {
+		t.Errorf("db_op.new_json.price.amount = %s, want 1.50000000", got)
+	}
+	if dbOp.Has(dbOp.Descriptor().Fields().ByName("old_json")) {
+		t.Errorf("db_op.old_json must not be set on insert")
+	}
+}
+
+func TestKafkaProtobufCodec_Checkpoint(t *testing.T) {
+	abiCodec := NewStreamedAbiCodec(
+		nil,
+		nil,
+		srclient.CreateMockSchemaRegistryClient("mock://TestKafkaProtobufCodec_Checkpoint"),
+		"",
+		"mock://TestKafkaProtobufCodec_Checkpoint",
+		srclient.Forward,
+		WithProtobufCodec(),
+	)
+	codec, err := abiCodec.GetCodec(CheckpointMessageSchema.AsCodecId(), 0)
+	if err != nil {
+		t.Fatalf("GetCodec() error: %v", err)
+	}
+	now := time.Date(2022, 1, 1, 0, 0, 1, 0, time.UTC)
+	bytes, err := codec.Marshal(nil, map[string]interface{}{
+		"step":                  1,
+		"block":                 map[string]interface{}{"id": "block-42", "num": uint64(42)},
+		"headBlock":             map[string]interface{}{"id": "block-43", "num": uint64(43)},
+		"lastIrreversibleBlock": map[string]interface{}{"id": "block-41", "num": uint64(41)},
+		"time":                  now,
+	})
+	if err != nil {
+		t.Fatalf("Marshal() error: %v", err)
+	}
+	value, err := codec.Unmarshal(bytes)
+	if err != nil {
+		t.Fatalf("Unmarshal() error: %v", err)
+	}
+	message := value.(*dynamicpb.Message)
+	if got := protoField(t, protoField(t, message, "headBlock").Message(), "num").Int(); got != 43 {
+		t.Errorf("headBlock.num = %d, want 43", got)
+	}
+	ts := protoField(t, message, "time").Message()
+	if got := protoField(t, ts, "seconds").Int(); got != now.Unix() {
+		t.Errorf("time.seconds = %d, want %d", got, now.Unix())
+	}
+
+	if _, err := codec.Unmarshal(append([]byte{0, 0, 0, 0, 0}, bytes[5:]...)); err == nil {
+		t.Errorf("Unmarshal() expected an error on an unknown schema id")
+	}
+}
+
+func protoField(t *testing.T, message protoreflect.Message, name string) protoreflect.Value {
+	t.Helper()
+	fd := message.Descriptor().Fields().ByName(protoreflect.Name(name))
+	if fd == nil {
+		t.Fatalf("missing field: %s in message: %s", name, message.Descriptor().FullName())
+	}
+	return message.Get(fd)
+}
@@ -0,0 +1,382 @@
+package dkafka
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+
+	"github.com/iancoleman/strcase"
+	"google.golang.org/protobuf/proto"
+	"google.golang.org/protobuf/reflect/protodesc"
+	"google.golang.org/protobuf/reflect/protoregistry"
+	"google.golang.org/protobuf/types/descriptorpb"
+	_ "google.golang.org/protobuf/types/known/timestamppb"
+)
+
+const (
+	protoTimestamp     = ".google.protobuf.Timestamp"
+	protoTimestampFile = "google/protobuf/timestamp.proto"
+)
+
+var protoScalarTypes = map[string]descriptorpb.FieldDescriptorProto_Type{
+	"boolean": descriptorpb.FieldDescriptorProto_TYPE_BOOL,
+	"int":     descriptorpb.FieldDescriptorProto_TYPE_INT32,
+	"long":    descriptorpb.FieldDescriptorProto_TYPE_INT64,
+	"float":   descriptorpb.FieldDescriptorProto_TYPE_FLOAT,
+	"double":  descriptorpb.FieldDescriptorProto_TYPE_DOUBLE,
+	"bytes":   descriptorpb.FieldDescriptorProto_TYPE_BYTES,
+	"string":  descriptorpb.FieldDescriptorProto_TYPE_STRING,
+}
+
+// GenerateProtoSchema converts the avro message schema into a proto3 file
+// whose first message is the notification. The named records are messages of
+// the package named after the notification namespace. The avro types without
+// protobuf equivalent are mapped as follow:
+// - optional (null union) scalars are proto3 optional fields
+// - unions are wrapper messages with a oneof of their branches
+// - arrays of arrays or of optional items are repeated wrapper messages
+// - timestamps are google.protobuf.Timestamp
+// - decimals are the string of the decimal number
+// - fixed are bytes
+func GenerateProtoSchema(messageSchema MessageSchema) (*descriptorpb.FileDescriptorProto, error) {
+	avro, err := parseAvroSchema(messageSchema.RecordSchema)
+	if err != nil {
+		return nil, err
+	}
+	root := avro.root.(map[string]interface{})
+	fullName := namedFullName(root, "")
+	g := &protoSchemaGenerator{
+		avro:     avro,
+		pkg:      avroNamespace(fullName),
+		messages: make(map[string]string),
+		used:     make(map[string]bool),
+		file: &descriptorpb.FileDescriptorProto{
+			Name:   proto.String(strcase.ToSnake(messageSchema.Name) + ".proto"),
+			Syntax: proto.String("proto3"),
+		},
+	}
+	if g.pkg != "" {
+		g.file.Package = proto.String(g.pkg)
+	}
+	if _, err := g.message(root, ""); err != nil {
+		return nil, err
+	}
+	// validate the generated file
+	if _, err := protodesc.NewFile(g.file, protoregistry.GlobalFiles); err != nil {
+		return nil, fmt.Errorf("invalid protobuf schema: %s, error: %w", g.file.GetName(), err)
+	}
+	return g.file, nil
+}
+
+type protoSchemaGenerator struct {
+	avro *avroSchema
+	pkg  string
+	// messages are the message type names by avro full name
+	messages map[string]string
+	used     map[string]bool
+	file     *descriptorpb.FileDescriptorProto
+}
+
+// newMessage adds an empty message whose name is unique in the file
+func (g *protoSchemaGenerator) newMessage(name string, qualifiers ...string) (*descriptorpb.DescriptorProto, string) {
+	name = strcase.ToCamel(name)
+	for i := len(qualifiers) - 1; i >= 0 && g.used[name]; i-- {
+		name = strcase.ToCamel(qualifiers[i]) + name
+	}
+	for i := 2; g.used[name]; i++ {
+		name = fmt.Sprintf("%s%d", strings.TrimRight(name, "0123456789"), i)
+	}
+	g.used[name] = true
+	message := &descriptorpb.DescriptorProto{Name: proto.String(name)}
+	g.file.MessageType = append(g.file.MessageType, message)
+	if g.pkg == "" {
+		return message, "." + name
+	}
+	return message, "." + g.pkg + "." + name
+}
+
+// message returns the type name of the record message
+func (g *protoSchemaGenerator) message(record map[string]interface{}, namespace string) (string, error) {
+	fullName := namedFullName(record, namespace)
+	if typeName, found := g.messages[fullName]; found {
+		return typeName, nil
+	}
+	message, typeName := g.newMessage(unqualifiedName(record["name"]), strings.Split(avroNamespace(fullName), ".")...)
+	g.messages[fullName] = typeName
+	fields, _ := record["fields"].([]interface{})
+	for i, field := range fields {
+		f := field.(map[string]interface{})
+		name := f["name"].(string)
+		fd, err := g.field(message.GetName()+strcase.ToCamel(name), f["type"], avroNamespace(fullName))
+		if err != nil {
+			return "", fmt.Errorf("field: '%s' of record: '%s', error: %w", name, fullName, err)
+		}
+		fd.Name = proto.String(name)
+		fd.Number = proto.Int32(int32(i + 1))
+		if fd.GetProto3Optional() {
+			// proto3 optional fields are in a synthetic oneof
+			fd.OneofIndex = proto.Int32(int32(len(message.OneofDecl)))
+			message.OneofDecl = append(message.OneofDecl, &descriptorpb.OneofDescriptorProto{Name: proto.String("_" + name)})
+		}
+		message.Field = append(message.Field, fd)
+	}
+	return typeName, nil
+}
+
+// field returns the unnamed field of the avro type, name is the name of the
+// wrapper messages
+func (g *protoSchemaGenerator) field(name string, schema interface{}, namespace string) (*descriptorpb.FieldDescriptorProto, error) {
+	label := descriptorpb.FieldDescriptorProto_LABEL_OPTIONAL.Enum()
+	if t, logical, ok := logicalType(schema); ok {
+		return g.scalar(t, logical)
+	}
+	def, t, _ := g.avro.resolve(schema, namespace)
+	switch t {
+	case "union":
+		var branches []interface{}
+		for _, branch := range def.([]interface{}) {
+			if branch != "null" {
+				branches = append(branches, branch)
+			}
+		}
+		nullable := len(branches) < len(def.([]interface{}))
+		if len(branches) == 1 {
+			fd, err := g.field(name, branches[0], namespace)
+			if err != nil {
+				return nil, err
+			}
+			if nullable && fd.GetLabel() != descriptorpb.FieldDescriptorProto_LABEL_REPEATED && fd.GetType() != descriptorpb.FieldDescriptorProto_TYPE_MESSAGE {
+				fd.Proto3Optional = proto.Bool(true)
+			}
+			return fd, nil
+		}
+		typeName, err := g.union(name, branches, namespace)
+		if err != nil {
+			return nil, err
+		}
+		return &descriptorpb.FieldDescriptorProto{Label: label, Type: descriptorpb.FieldDescriptorProto_TYPE_MESSAGE.Enum(), TypeName: proto.String(typeName)}, nil
+	case "array":
+		fd, err := g.field(name+"Item", def.(map[string]interface{})["items"], namespace)
+		if err != nil {
+			return nil, err
+		}
+		if !isPlainField(fd) {
+			typeName, err := g.wrapper(name+"Item", fd)
+			if err != nil {
+				return nil, err
+			}
+			fd = &descriptorpb.FieldDescriptorProto{Type: descriptorpb.FieldDescriptorProto_TYPE_MESSAGE.Enum(), TypeName: proto.String(typeName)}
+		}
+		fd.Label = descriptorpb.FieldDescriptorProto_LABEL_REPEATED.Enum()
+		return fd, nil
+	case "fixed":
+		return g.scalar("bytes", "")
+	case "record", "error":
+		typeName, err := g.message(def.(map[string]interface{}), namespace)
+		if err != nil {
+			return nil, err
+		}
+		return &descriptorpb.FieldDescriptorProto{Label: label, Type: descriptorpb.FieldDescriptorProto_TYPE_MESSAGE.Enum(), TypeName: proto.String(typeName)}, nil
+	}
+	if avroPrimitives[t] {
+		return g.scalar(t, "")
+	}
+	return nil, fmt.Errorf("unsupported avro type: '%s' in protobuf", t)
+}
+
+func (g *protoSchemaGenerator) scalar(t string, logical string) (*descriptorpb.FieldDescriptorProto, error) {
+	fd := &descriptorpb.FieldDescriptorProto{Label: descriptorpb.FieldDescriptorProto_LABEL_OPTIONAL.Enum()}
+	switch logical {
+	case "timestamp-millis", "timestamp-micros":
+		g.addDependency(protoTimestampFile)
+		fd.Type = descriptorpb.FieldDescriptorProto_TYPE_MESSAGE.Enum()
+		fd.TypeName = proto.String(protoTimestamp)
+		return fd, nil
+	case "decimal":
+		fd.Type = descriptorpb.FieldDescriptorProto_TYPE_STRING.Enum()
+		return fd, nil
+	}
+	scalar, found := protoScalarTypes[t]
+	if !found {
+		return nil, fmt.Errorf("unsupported avro type: '%s' in protobuf", t)
+	}
+	fd.Type = scalar.Enum()
+	return fd, nil
+}
+
+// union returns the type name of the message with a oneof of the branches
+func (g *protoSchemaGenerator) union(name string, branches []interface{}, namespace string) (string, error) {
+	message, typeName := g.newMessage(name)
+	message.OneofDecl = []*descriptorpb.OneofDescriptorProto{{Name: proto.String("value")}}
+	names := make(map[string]bool)
+	for i, branch := range branches {
+		fd, err := g.field(name+"Branch", branch, namespace)
+		if err != nil {
+			return "", err
+		}
+		if !isPlainField(fd) {
+			// oneof fields cannot be repeated
+			wrapped, err := g.wrapper(fmt.Sprintf("%sBranch%d", name, i+1), fd)
+			if err != nil {
+				return "", err
+			}
+			fd = &descriptorpb.FieldDescriptorProto{Type: descriptorpb.FieldDescriptorProto_TYPE_MESSAGE.Enum(), TypeName: proto.String(wrapped)}
+		}
+		fieldName := protoBranchName(branch)
+		if names[fieldName] {
+			fieldName = fmt.Sprintf("%s_%d", fieldName, i+1)
+		}
+		names[fieldName] = true
+		fd.Name = proto.String(fieldName)
+		fd.Number = proto.Int32(int32(i + 1))
+		fd.Label = descriptorpb.FieldDescriptorProto_LABEL_OPTIONAL.Enum()
+		fd.OneofIndex = proto.Int32(0)
+		message.Field = append(message.Field, fd)
+	}
+	return typeName, nil
+}
+
+// wrapper returns the type name of a message with the field as single value
+func (g *protoSchemaGenerator) wrapper(name string, fd *descriptorpb.FieldDescriptorProto) (string, error) {
+	message, typeName := g.newMessage(name)
+	fd.Name = proto.String("value")
+	if fd.GetLabel() == descriptorpb.FieldDescriptorProto_LABEL_REPEATED {
+		fd.Name = proto.String("values")
+	}
+	fd.Number = proto.Int32(1)
+	if fd.GetProto3Optional() {
+		fd.OneofIndex = proto.Int32(0)
+		message.OneofDecl = []*descriptorpb.OneofDescriptorProto{{Name: proto.String("_value")}}
+	}
+	message.Field = []*descriptorpb.FieldDescriptorProto{fd}
+	return typeName, nil
+}
+
+func (g *protoSchemaGenerator) addDependency(file string) {
+	for _, dependency := range g.file.Dependency {
+		if dependency == file {
+			return
+		}
+	}
+	g.file.Dependency = append(g.file.Dependency, file)
+}
+
+func isPlainField(fd *descriptorpb.FieldDescriptorProto) bool {
+	return fd.GetLabel() != descriptorpb.FieldDescriptorProto_LABEL_REPEATED && !fd.GetProto3Optional()
+}
+
+// protoBranchName returns the oneof field name of an union branch
+func protoBranchName(branch interface{}) string {
+	switch typed := branch.(type) {
+	case string:
+		if avroPrimitives[typed] {
+			return typed + "_value"
+		}
+		return strcase.ToSnake(unqualifiedName(typed))
+	case map[string]interface{}:
+		if logical, ok := typed["logicalType"].(string); ok {
+			return strcase.ToSnake(logical) + "_value"
+		}
+		if name, ok := typed["name"].(string); ok {
+			return strcase.ToSnake(unqualifiedName(name))
+		}
+		if t, ok := typed["type"].(string); ok {
+			return t + "_value"
+		}
+	}
+	return "value"
+}
+
+// FormatProtoSchema renders the file in the protobuf language
+func FormatProtoSchema(file *descriptorpb.FileDescriptorProto) string {
+	var b strings.Builder
+	fmt.Fprintf(&b, "syntax = \"%s\";\n", file.GetSyntax())
+	if file.Package != nil {
+		fmt.Fprintf(&b, "\npackage %s;\n", file.GetPackage())
+	}
+	if len(file.Dependency) > 0 {
+		b.WriteString("\n")
+		dependencies := append([]string(nil), file.Dependency...)
+		sort.Strings(dependencies)
+		for _, dependency := range dependencies {
+			fmt.Fprintf(&b, "import \"%s\";\n", dependency)
+		}
+	}
+	prefix := "."
+	if file.Package != nil {
+		prefix = "." + file.GetPackage() + "."
+	}
+	for _, enum := range file.EnumType {
+		b.WriteString("\n")
+		formatProtoEnum(&b, enum, "")
+	}
+	for _, message := range file.MessageType {
+		b.WriteString("\n")
+		formatProtoMessage(&b, message, prefix, "")
+	}
+	return b.String()
+}
+
+func formatProtoMessage(b *strings.Builder, message *descriptorpb.DescriptorProto, prefix string, indent string) {
+	fmt.Fprintf(b, "%smessage %s {\n", indent, message.GetName())
+	for _, enum := range message.EnumType {
+		formatProtoEnum(b, enum, indent+"  ")
+	}
+	for _, nested := range message.NestedType {
+		formatProtoMessage(b, nested, prefix, indent+"  ")
+	}
+	for _, reserved := range message.ReservedRange {
+		// the end of the descriptor range is exclusive
+		if reserved.GetEnd()-reserved.GetStart() == 1 {
+			fmt.Fprintf(b, "%s  reserved %d;\n", indent, reserved.GetStart())
+		} else {
+			fmt.Fprintf(b, "%s  reserved %d to %d;\n", indent, reserved.GetStart(), reserved.GetEnd()-1)
+		}
+	}
+	for i, oneof := range message.OneofDecl {
+		if strings.HasPrefix(oneof.GetName(), "_") {
+			// synthetic oneof of the proto3 optional fields
+			continue
+		}
+		fmt.Fprintf(b, "%s  oneof %s {\n", indent, oneof.GetName())
+		for _, fd := range message.Field {
+			if fd.OneofIndex != nil && int(fd.GetOneofIndex()) == i {
+				fmt.Fprintf(b, "%s    %s\n", indent, formatProtoField(fd, prefix))
+			}
+		}
+		fmt.Fprintf(b, "%s  }\n", indent)
+	}
+	for _, fd := range message.Field {
+		if fd.OneofIndex != nil && !fd.GetProto3Optional() {
+			continue
+		}
+		fmt.Fprintf(b, "%s  %s\n", indent, formatProtoField(fd, prefix))
+	}
+	fmt.Fprintf(b, "%s}\n", indent)
+}
+
+func formatProtoEnum(b *strings.Builder, enum *descriptorpb.EnumDescriptorProto, indent string) {
+	fmt.Fprintf(b, "%senum %s {\n", indent, enum.GetName())
+	for _, value := range enum.Value {
+		fmt.Fprintf(b, "%s  %s = %d;\n", indent, value.GetName(), value.GetNumber())
+	}
+	fmt.Fprintf(b, "%s}\n", indent)
+}
+
+func formatProtoField(fd *descriptorpb.FieldDescriptorProto, prefix string) string {
+	var t string
+	switch fd.GetType() {
+	case descriptorpb.FieldDescriptorProto_TYPE_MESSAGE, descriptorpb.FieldDescriptorProto_TYPE_ENUM:
+		t = strings.TrimPrefix(fd.GetTypeName(), prefix)
+		t = strings.TrimPrefix(t, ".")
+	default:
+		t = strings.ToLower(strings.TrimPrefix(fd.GetType().String(), "TYPE_"))
+	}
+	switch {
+	case fd.GetLabel() == descriptorpb.FieldDescriptorProto_LABEL_REPEATED:
+		t = "repeated " + t
+	case fd.GetProto3Optional():
+		t = "optional " + t
+	}
+	return fmt.Sprintf("%s %s = %d;", t, fd.GetName(), fd.GetNumber())
+}
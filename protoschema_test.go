@@ -0,0 +1,96 @@
+package dkafka
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/eoscanada/eos-go"
+)
+
+func TestGenerateProtoSchema(t *testing.T) {
+	abi, err := LoadABIFile("eosio.nft.ft", "testdata/eosio.nft.ft.abi")
+	if err != nil {
+		t.Fatalf("LoadABIFile() error: %v", err)
+	}
+	generator := MessageSchemaGenerator{Namespace: "test", Account: "eosio.nft.ft"}
+	schemas := []MessageSchema{CheckpointMessageSchema, AbiUpdatedMessageSchema, UndecodedTableNotificationMessageSchema, TransactionMessageSchema}
+	for _, table := range abi.Tables {
+		schema, err := generator.getTableSchema(string(table.Name), abi)
+		if err != nil {
+			t.Fatalf("getTableSchema(%s) error: %v", table.Name, err)
+		}
+		schemas = append(schemas, schema)
+	}
+	for _, action := range abi.Actions {
+		schema, err := generator.getActionSchema(string(action.Name), abi)
+		if err != nil {
+			t.Fatalf("getActionSchema(%s) error: %v", action.Name, err)
+		}
+		schemas = append(schemas, schema)
+	}
+	for _, schema := range schemas {
+		t.Run(schema.Name, func(t *testing.T) {
+			file, err := GenerateProtoSchema(schema)
+			if err != nil {
+				t.Fatalf("GenerateProtoSchema() error: %v", err)
+			}
+			if name := file.MessageType[0].GetName(); name != schema.Name {
+				t.Errorf("first message = %s, want %s", name, schema.Name)
+			}
+		})
+	}
+}
+
+func TestGenerateProtoSchema_types(t *testing.T) {
+	abi := &ABI{
+		ABI: &eos.ABI{
+			Structs: []eos.StructDef{
+				{
+					Name:   "key_def",
+					Fields: []eos.FieldDef{{Name: "name", Type: "string"}},
+				},
+				{
+					Name: "row",
+					Fields: []eos.FieldDef{
+						{Name: "value", Type: "value_type"},
+						{Name: "optional_value", Type: "value_type?"},
+						{Name: "optional_id", Type: "uint32?"},
+						{Name: "matrix", Type: "uint32[][]"},
+						{Name: "created_at", Type: "time_point"},
+						{Name: "quantity", Type: "asset"},
+					},
+				},
+			},
+			Variants: []eos.VariantDef{
+				{Name: "value_type", Types: []string{"uint32", "string", "key_def", "uint8[]"}},
+			},
+			Tables: []eos.TableDef{{Name: "rows", Type: "row"}},
+		},
+		Account: "test",
+	}
+	record, err := tableToRecord(abi, "rows", SchemaMappingProfile{})
+	if err != nil {
+		t.Fatalf("tableToRecord() error: %v", err)
+	}
+	record.Namespace = "test.v0"
+	file, err := GenerateProtoSchema(MessageSchema{RecordSchema: record})
+	if err != nil {
+		t.Fatalf("GenerateProtoSchema() error: %v", err)
+	}
+	proto := FormatProtoSchema(file)
+	for _, want := range []string{
+		"package test.v0;",
+		`import "google/protobuf/timestamp.proto";`,
+		"RowValue value = 1;",
+		"RowOptionalValue optional_value = 2;",
+		"optional int64 optional_id = 3;",
+		"repeated RowMatrixItem matrix = 4;",
+		"google.protobuf.Timestamp created_at = 5;",
+		"message RowValue {\n  oneof value {\n    int64 long_value = 1;\n    string string_value = 2;\n    KeyDef key_def = 3;\n    RowValueBranch4 array_value = 4;\n  }\n}",
+		"message RowMatrixItem {\n  repeated int64 values = 1;\n}",
+	} {
+		if !strings.Contains(proto, want) {
+			t.Errorf("missing '%s' in:\n%s", want, proto)
+		}
+	}
+}
@@ -0,0 +1,133 @@
+package dkafka
+
+import (
+	"fmt"
+
+	"github.com/confluentinc/confluent-kafka-go/kafka"
+	pbcodec "github.com/dfuse-io/dfuse-eosio/pb/dfuse/eosio/codec/v1"
+	protov1 "github.com/golang/protobuf/proto"
+	"github.com/riferrei/srclient"
+	"go.uber.org/zap"
+	"google.golang.org/protobuf/reflect/protoreflect"
+)
+
+const (
+	// TransactionRawGranularity publishes one message per transaction trace
+	TransactionRawGranularity = "transaction"
+	// BlockRawGranularity publishes one message per block with its
+	// transaction traces
+	BlockRawGranularity = "block"
+)
+
+func ValidateRawGranularity(granularity string) error {
+	switch granularity {
+	case "", TransactionRawGranularity, BlockRawGranularity:
+		return nil
+	default:
+		return fmt.Errorf("unsupported raw granularity: '%s', expected one of: %s, %s", granularity, TransactionRawGranularity, BlockRawGranularity)
+	}
+}
+
+// The firehose messages published as is by the raw cdc
+var (
+	rawTransactionTraceMessage = protov1.MessageV2(&pbcodec.TransactionTrace{}).ProtoReflect().Descriptor()
+	rawBlockMessage            = protov1.MessageV2(&pbcodec.Block{}).ProtoReflect().Descriptor()
+)
+
+func rawCodecId(message protoreflect.MessageDescriptor) CodecId {
+	return CodecId{Name: string(message.FullName())}
+}
+
+// newStreamedAbiCodecWithRawMessages returns the constructor of the codec
+// registering the raw messages in addition to the static schemas
+func newStreamedAbiCodecWithRawMessages(messages ...protoreflect.MessageDescriptor) StreamAbiCodecConstructor {
+	return func(
+		bootstrapper AbiRepository,
+		getSchema MessageSchemaSupplier,
+		schemaRegistryClient srclient.ISchemaRegistryClient,
+		account string,
+		schemaRegistryURL string,
+		compatibility srclient.CompatibilityLevel,
+		options ...StreamedAbiCodecOption,
+	) ABICodec {
+		options = append(options, WithRawProtobufMessages(messages...))
+		return NewStreamedAbiCodec(bootstrapper, getSchema, schemaRegistryClient, account, schemaRegistryURL, compatibility, options...)
+	}
+}
+
+// RawAdapter publishes the firehose transaction traces, or the blocks, in
+// protobuf without any ABI decoding.
+type RawAdapter struct {
+	topic     string
+	saveBlock SaveBlock
+	headers   []kafka.Header
+	abiCodec  ABICodec
+	perBlock  bool
+}
+
+func (m *RawAdapter) Adapt(blkStep BlockStep) ([]*kafka.Message, error) {
+	blk := blkStep.blk
+	step := sanitizeStep(blkStep.step.String())
+
+	m.saveBlock(blk)
+	trxs := blk.TransactionTraces()
+	zlog.Debug("adapt raw block", zap.Uint32("num", blk.Number), zap.String("step", step), zap.Int("nb_trx", len(trxs)))
+	if len(trxs) == 0 {
+		return nil, nil
+	}
+	if m.perBlock {
+		ceId := hashString(fmt.Sprintf("%s%s", blkStep.cursor, step))
+		msg, err := m.message(blkStep, step, rawBlockMessage, blk, ceId, blk.Id)
+		if err != nil {
+			return nil, err
+		}
+		return []*kafka.Message{msg}, nil
+	}
+	msgs := make([]*kafka.Message, 0, len(trxs))
+	for _, trx := range orderSliceOnBlockStep(trxs, blkStep.step) {
+		transactionTracesReceived.Inc()
+		ceId := hashString(fmt.Sprintf("%s%s%s", blkStep.cursor, trx.Id, step))
+		msg, err := m.message(blkStep, step, rawTransactionTraceMessage, trx, ceId, trx.Id)
+		if err != nil {
+			return nil, err
+		}
+		msgs = append(msgs, msg)
+	}
+	return msgs, nil
+}
+
+func (m *RawAdapter) message(blkStep BlockStep, step string, message protoreflect.MessageDescriptor, value protov1.Message, ceId []byte, key string) (*kafka.Message, error) {
+	codec, err := m.abiCodec.GetCodec(rawCodecId(message), blkStep.blockNum())
+	if err != nil {
+		return nil, fmt.Errorf("RawAdapter.Adapt() fail to get codec for %s: %w", message.FullName(), err)
+	}
+	bytes, err := codec.Marshal(nil, value)
+	if err != nil {
+		return nil, fmt.Errorf("RawAdapter.Adapt() fail to marshal %s: %w", message.FullName(), err)
+	}
+	headers := append(m.headers[:len(m.headers):len(m.headers)],
+		kafka.Header{
+			Key:   "ce_id",
+			Value: ceId,
+		},
+		kafka.Header{
+			Key:   "ce_type",
+			Value: []byte(message.Name()),
+		},
+		kafka.Header{
+			Key:   "ce_blkstep",
+			Value: []byte(step),
+		},
+		blkStep.timeHeader(),
+	)
+	headers = append(headers, codec.GetHeaders()...)
+	return &kafka.Message{
+		Key:     []byte(key),
+		Headers: headers,
+		Value:   bytes,
+		TopicPartition: kafka.TopicPartition{
+			Topic:     &m.topic,
+			Partition: kafka.PartitionAny,
+		},
+	}, nil
+}
@@ -0,0 +1,192 @@
+package dkafka
+
+import (
+	"encoding/binary"
+	"strings"
+	"testing"
+
+	pbcodec "github.com/dfuse-io/dfuse-eosio/pb/dfuse/eosio/codec/v1"
+	protov1 "github.com/golang/protobuf/proto"
+	"github.com/riferrei/srclient"
+	pbbstream "github.com/streamingfast/pbgo/dfuse/bstream/v1"
+	"google.golang.org/protobuf/proto"
+	"google.golang.org/protobuf/reflect/protoreflect"
+	"google.golang.org/protobuf/types/dynamicpb"
+)
+
+func TestRawAdapter_Transaction(t *testing.T) {
+	registry := srclient.CreateMockSchemaRegistryClient("mock://TestRawAdapter_Transaction")
+	abiCodec := newRawAbiCodec(registry, "mock://TestRawAdapter_Transaction", rawTransactionTraceMessage)
+	adapter := &RawAdapter{
+		topic:     "raw",
+		saveBlock: saveBlockNoop,
+		abiCodec:  abiCodec,
+	}
+	blk := newBlock4Test(t)
+	msgs, err := adapter.Adapt(BlockStep{blk: blk, step: pbbstream.ForkStep_STEP_UNDO, cursor: "cursor-42"})
+	if err != nil {
+		t.Fatalf("Adapt() error: %v", err)
+	}
+	if len(msgs) != 2 {
+		t.Fatalf("Adapt() expected 2 messages got: %d", len(msgs))
+	}
+	// the transactions of an UNDO block are in the reverse order
+	if string(msgs[0].Key) != "trx-2" || string(msgs[1].Key) != "trx-1" {
+		t.Errorf("Adapt() keys: %s, %s, want: trx-2, trx-1", msgs[0].Key, msgs[1].Key)
+	}
+	for name, want := range map[string]string{
+		"ce_type":            "TransactionTrace",
+		"ce_blkstep":         "UNDO",
+		"ce_time":            "2022-01-01T00:00:00Z",
+		"ce_datacontenttype": "application/x-protobuf",
+	} {
+		if got := findHeader(name, msgs[0].Headers); got != want {
+			t.Errorf("header: %s = %s, want: %s", name, got, want)
+		}
+	}
+	if findHeader("ce_id", msgs[0].Headers) == findHeader("ce_id", msgs[1].Headers) {
+		t.Errorf("ce_id must be unique per transaction")
+	}
+
+	codec, err := abiCodec.GetCodec(rawCodecId(rawTransactionTraceMessage), 42)
+	if err != nil {
+		t.Fatalf("GetCodec() error: %v", err)
+	}
+	id := codec.(KafkaRawProtobufCodec).id
+	bytes := msgs[1].Value
+	if bytes[0] != 0 || binary.BigEndian.Uint32(bytes[1:5]) != id {
+		t.Errorf("invalid wire format header: %v, want [0 %d]", bytes[:5], id)
+	}
+	value, err := codec.Unmarshal(bytes)
+	if err != nil {
+		t.Fatalf("Unmarshal() error: %v", err)
+	}
+	if want := protov1.MessageV2(blk.UnfilteredTransactionTraces[0]); !proto.Equal(value.(*dynamicpb.Message), want) {
+		t.Errorf("Unmarshal() = %v, want: %v", value, want)
+	}
+
+	schema, err := registry.GetSchema(int(id))
+	if err != nil {
+		t.Fatalf("GetSchema() error: %v", err)
+	}
+	if schemaType := schema.SchemaType(); schemaType == nil || *schemaType != srclient.Protobuf {
+		t.Errorf("registered schema type = %v, want %s", schemaType, srclient.Protobuf)
+	}
+	for _, want := range []string{
+		"package dfuse.eosio.codec.v1;",
+		`import "google/protobuf/timestamp.proto";`,
+		"enum TransactionStatus {",
+		"message TransactionTrace {",
+		"repeated DBOp db_ops = ",
+		"  enum Operation {",
+		"  reserved 13 to 18;",
+	} {
+		if !strings.Contains(schema.Schema(), want) {
+			t.Errorf("missing '%s' in registered schema", want)
+		}
+	}
+}
+
+func TestRawAdapter_Block(t *testing.T) {
+	registry := srclient.CreateMockSchemaRegistryClient("mock://TestRawAdapter_Block")
+	abiCodec := newRawAbiCodec(registry, "mock://TestRawAdapter_Block", rawBlockMessage)
+	adapter := &RawAdapter{
+		topic:     "raw",
+		saveBlock: saveBlockNoop,
+		abiCodec:  abiCodec,
+		perBlock:  true,
+	}
+	blk := newBlock4Test(t)
+	msgs, err := adapter.Adapt(BlockStep{blk: blk, step: pbbstream.ForkStep_STEP_NEW, cursor: "cursor-42"})
+	if err != nil {
+		t.Fatalf("Adapt() error: %v", err)
+	}
+	if len(msgs) != 1 {
+		t.Fatalf("Adapt() expected 1 message got: %d", len(msgs))
+	}
+	if string(msgs[0].Key) != "block-42" || findHeader("ce_type", msgs[0].Headers) != "Block" {
+		t.Errorf("Adapt() key: %s, ce_type: %s, want: block-42, Block", msgs[0].Key, findHeader("ce_type", msgs[0].Headers))
+	}
+	codec, err := abiCodec.GetCodec(rawCodecId(rawBlockMessage), 42)
+	if err != nil {
+		t.Fatalf("GetCodec() error: %v", err)
+	}
+	value, err := codec.Unmarshal(msgs[0].Value)
+	if err != nil {
+		t.Fatalf("Unmarshal() error: %v", err)
+	}
+	if want := protov1.MessageV2(blk); !proto.Equal(value.(*dynamicpb.Message), want) {
+		t.Errorf("Unmarshal() = %v, want: %v", value, want)
+	}
+
+	msgs, err = adapter.Adapt(BlockStep{blk: &pbcodec.Block{Id: "block-43", Number: 43}, step: pbbstream.ForkStep_STEP_NEW, cursor: "cursor-43"})
+	if err != nil {
+		t.Fatalf("Adapt() error: %v", err)
+	}
+	if len(msgs) != 0 {
+		t.Errorf("Adapt() expected no message for a block without transactions got: %d", len(msgs))
+	}
+}
+
+func TestRawAdapter_readOnly(t *testing.T) {
+	registry := srclient.CreateMockSchemaRegistryClient("mock://TestRawAdapter_readOnly")
+	newCodec := func(options ...StreamedAbiCodecOption) ABICodec {
+		return newStreamedAbiCodecWithRawMessages(rawBlockMessage)(
+			&AbiRepositoryStub{},
+			MessageSchemaGenerator{}.getNoopSchema,
+			registry,
+			"",
+			"mock://TestRawAdapter_readOnly",
+			srclient.Forward,
+			append(options, WithProtobufCodec())...,
+		)
+	}
+	// the static schemas are registered upfront, the raw message on first use
+	writer := newCodec()
+	readOnly := newCodec(WithReadOnlySchemas())
+	if _, err := readOnly.GetCodec(rawCodecId(rawBlockMessage), 42); err == nil || !strings.Contains(err.Error(), "no schema registered") {
+		t.Fatalf("GetCodec() on read-only registry without schema error = %v, want no schema registered", err)
+	}
+	registered, err := writer.GetCodec(rawCodecId(rawBlockMessage), 42)
+	if err != nil {
+		t.Fatalf("GetCodec() error: %v", err)
+	}
+	found, err := readOnly.GetCodec(rawCodecId(rawBlockMessage), 42)
+	if err != nil {
+		t.Fatalf("GetCodec() on read-only registry error: %v", err)
+	}
+	if got, want := findHeader("ce_dataschema", found.GetHeaders()), findHeader("ce_dataschema", registered.GetHeaders()); got != want {
+		t.Errorf("read-only ce_dataschema = %s, want the registered one %s", got, want)
+	}
+}
+
+func TestProtoMessageIndexes(t *testing.T) {
+	file := rawTransactionTraceMessage.ParentFile()
+	first := file.Messages().Get(0)
+	if got := protoMessageIndexes(first); string(got) != string([]byte{0}) {
+		t.Errorf("protoMessageIndexes(%s) = %v, want [0]", first.FullName(), got)
+	}
+	// zigzag varints: 1 index then the index of the message
+	index := rawTransactionTraceMessage.Index()
+	want := binary.AppendVarint(binary.AppendVarint(nil, 1), int64(index))
+	if got := protoMessageIndexes(rawTransactionTraceMessage); string(got) != string(want) {
+		t.Errorf("protoMessageIndexes(%s) = %v, want %v", rawTransactionTraceMessage.FullName(), got, want)
+	}
+	nested := file.Messages().ByName("Exception").Messages().Get(1)
+	want = binary.AppendVarint(binary.AppendVarint(binary.AppendVarint(nil, 2), int64(nested.Parent().Index())), 1)
+	if got := protoMessageIndexes(nested); string(got) != string(want) {
+		t.Errorf("protoMessageIndexes(%s) = %v, want %v", nested.FullName(), got, want)
+	}
+}
+
+func newRawAbiCodec(registry srclient.ISchemaRegistryClient, url string, message ...protoreflect.MessageDescriptor) ABICodec {
+	return newStreamedAbiCodecWithRawMessages(message...)(
+		&AbiRepositoryStub{},
+		MessageSchemaGenerator{}.getNoopSchema,
+		registry,
+		"",
+		url,
+		srclient.Forward,
+		WithProtobufCodec(),
+	)
+}
@@ -38,6 +38,12 @@ type NamedSchemaGenOptions struct {
 	Version   string
 	AbiSpec   *ABI
 	Domain    string
+	// TypedActionData generates the action parameters with the same typed
+	// representation as the tables, to be used when the parameters are
+	// decoded from the action raw data
+	TypedActionData bool
+	// Mapping selects the lossless representations of some built-in types
+	Mapping SchemaMappingProfile
 }
 
 func (o NamedSchemaGenOptions) GetVersion() string {
@@ -77,7 +83,11 @@ func GenerateActionSchema(options NamedSchemaGenOptions) (MessageSchema, error)
 		zap.String("actionParams", actionParamsRecordName),
 	)
 
-	actionParamsSchema, err := ActionToRecord(options.AbiSpec, eos.ActionName(options.Name))
+	initBuiltInTypes := initBuiltInTypesForActions
+	if options.TypedActionData {
+		initBuiltInTypes = initBuiltInTypesForTables
+	}
+	actionParamsSchema, err := actionToRecord(options.AbiSpec, eos.ActionName(options.Name), initBuiltInTypes, options.Mapping)
 	if err != nil {
 		return MessageSchema{}, err
 	}
@@ -120,7 +130,7 @@ func GenerateTableSchema(options NamedSchemaGenOptions) (MessageSchema, error) {
 		zap.String("TableOp", dbOpRecordName),
 	)
 
-	dbOpSchema, err := TableToRecord(options.AbiSpec, eos.TableName(options.Name))
+	dbOpSchema, err := tableToRecord(options.AbiSpec, eos.TableName(options.Name), options.Mapping)
 	if err != nil {
 		return MessageSchema{}, err
 	}
@@ -132,8 +142,12 @@ func GenerateTableSchema(options NamedSchemaGenOptions) (MessageSchema, error) {
 }
 
 func ActionToRecord(abi *ABI, name eos.ActionName) (RecordSchema, error) {
+	return actionToRecord(abi, name, initBuiltInTypesForActions, SchemaMappingProfile{})
+}
+
+func actionToRecord(abi *ABI, name eos.ActionName, initBuiltInTypes func(SchemaMappingProfile), mapping SchemaMappingProfile) (RecordSchema, error) {
 	visited := make(map[string]string)
-	initBuiltInTypesForActions()
+	initBuiltInTypes(mapping)
 	actionDef := abi.ActionForName(name)
 	if actionDef == nil {
 		return RecordSchema{}, fmt.Errorf("action '%s' not found", name)
@@ -143,8 +157,12 @@ func ActionToRecord(abi *ABI, name eos.ActionName) (RecordSchema, error) {
 }
 
 func TableToRecord(abi *ABI, name eos.TableName) (RecordSchema, error) {
+	return tableToRecord(abi, name, SchemaMappingProfile{})
+}
+
+func tableToRecord(abi *ABI, name eos.TableName, mapping SchemaMappingProfile) (RecordSchema, error) {
 	visited := make(map[string]string)
-	initBuiltInTypesForTables()
+	initBuiltInTypesForTables(mapping)
 	tableDef := abi.TableForName(name)
 	if tableDef == nil {
 		return RecordSchema{}, fmt.Errorf("table '%s' not found", name)
@@ -209,31 +227,6 @@ func abiFieldToRecordField(abi *ABI, fieldDef eos.FieldDef, visited map[string]s
 	}
 }
 
-func variantToUnion(abi *ABI, name string, visited map[string]string) (Schema, error) {
-	v := abi.VariantForName(name)
-	if v == nil {
-		return nil, nil
-	}
-	if uberVariant, found := hardcodedVariantType[name]; found {
-		return uberVariant, nil
-	}
-	if len(v.Types) == 1 {
-		//edge case where there is only one type in the union
-		//then return the type ;)
-		return resolveType(abi, v.Types[0], visited)
-	} else {
-		var union = make([]Schema, len(v.Types))
-		for i, aType := range v.Types {
-			if resolved, err := resolveType(abi, aType, visited); err == nil {
-				union[i] = resolved
-			} else {
-				return nil, err
-			}
-		}
-		return union, nil
-	}
-}
-
 /*
    built_in_types.emplace("bool",                      pack_unpack<uint8_t>());
    built_in_types.emplace("int8",                      pack_unpack<int8_t>());
@@ -405,10 +398,23 @@ var schemaTypeConverters = map[string]goavro.ConvertBuild{
 	"eos.Int128":          int128Converter,
 	"eos.Uint128":         uint128Converter,
 	"eos.Symbol":          symbolConverter,
+	"eos.Uint64":          uint64Converter,
+	"eos.Uint64Fixed":     uint64FixedConverter,
+	"eos.Uint64String":    uint64StringConverter,
+	"eosio.SymbolAsset":   symbolAssetConverter,
+	"eos.VariantJSON":     variantJSONConverter,
+	"eos.TaggedVariant":   taggedVariantConverter,
+}
+
+// NewAvroCodec returns the codec of the avro schema with the converters of the
+// eos types used by dkafka to produce the messages.
+func NewAvroCodec(schema string) (*goavro.Codec, error) {
+	return goavro.NewCodecWithConverters(schema, schemaTypeConverters)
 }
 
 var avroPrimitiveTypeByBuiltInTypes map[string]TypedSchema
 var avroDecimalLogicalTypeByBuiltInTypes map[string]DecimalLogicalType
+var avroFixedTypeByBuiltInTypes map[string]FixedSchema
 
 type BuiltInRecordTypeGenerator func(map[string]string) RecordSchema
 
@@ -445,11 +451,13 @@ func assetSchemaGenerator(visited map[string]string) RecordSchema {
 }
 
 func extendedAssetSchemaGenerator(visited map[string]string) RecordSchema {
-	var assetType Schema = assetSchema
+	// the asset representation depends on the mapping profile
+	asset := avroRecordTypeByBuiltInTypes["asset"](visited)
+	var assetType Schema = asset
 	if record, found := visited["asset"]; found {
 		assetType = record
 	}
-	visited["asset"] = reference(assetSchema)
+	visited["asset"] = reference(asset)
 	var extendedAssetSchema RecordSchema = RecordSchema{
 		Type:      "record",
 		Name:      "ExtendedAsset",
@@ -553,7 +561,7 @@ func convertAllPrimitiveTypeToInterface() []interface{} {
 	return converted
 }
 
-func initBuiltInTypesForTables() {
+func initBuiltInTypesForTables(mapping SchemaMappingProfile) {
 	avroPrimitiveTypeByBuiltInTypes = map[string]TypedSchema{
 		"bool":   {Type: "boolean", EosType: "bool"},
 		"int8":   {Type: "int", EosType: "int8"},
@@ -564,7 +572,7 @@ func initBuiltInTypesForTables() {
 		"uint32": {Type: "long", EosType: "uint32"},
 		"int64":  {Type: "long", EosType: "int64"},
 		// FIXME: remove the logicalType
-		"uint64":               {Type: "long", EosType: "uint64", LogicalType: "eos.uint64"}, // FIXME maybe use Decimal here see goavro or FIXED
+		"uint64":               {Type: "long", EosType: "uint64", LogicalType: "eos.uint64"}, // lossless mappings are available with SchemaMappingProfile.Uint64
 		"varint32":             {Type: "int", EosType: "varint32"},
 		"varuint32":            {Type: "long", EosType: "varuint32"},
 		"float32":              {Type: "float", EosType: "float32"},
@@ -591,17 +599,19 @@ func initBuiltInTypesForTables() {
 		"public_key":     publicKeySchemaGenerator,
 		"signature":      signatureSchemaGenerator,
 	}
+	avroFixedTypeByBuiltInTypes = map[string]FixedSchema{}
 	hardcodedVariantType = map[string]interface{}{
 		HardcodedUberVariant: append(convertAllPrimitiveTypeToInterface(), NewArray(allPrimitiveTypes)),
 	}
+	mapping.apply()
 }
 
 // initBuiltInTypesForActions must rewrite the default types provided by initBuiltInTypesForTables
 // because the action details is sent directly in json from the firehouse and the firehouse use the
 // string representation of most of the advance type like asset and time based.
-// FIXME Can be fixed by using the raw_data of the action trace ;)
-func initBuiltInTypesForActions() {
-	initBuiltInTypesForTables()
+// Use initBuiltInTypesForTables (TypedActionData) when the action parameters are decoded from the raw_data of the action trace.
+func initBuiltInTypesForActions(mapping SchemaMappingProfile) {
+	initBuiltInTypesForTables(mapping)
 	avroPrimitiveTypeByBuiltInTypes["asset"] = TypedSchema{Type: "string", EosType: "asset"}
 	avroPrimitiveTypeByBuiltInTypes["public_key"] = TypedSchema{Type: "string", EosType: "public_key"}
 	avroPrimitiveTypeByBuiltInTypes["signature"] = TypedSchema{Type: "string", EosType: "signature"}
@@ -662,6 +672,11 @@ func resolveType(abi *ABI, name string, visited map[string]string) (Schema, erro
 		return referenceName, nil
 	}
 
+	if fixed, found := avroFixedTypeByBuiltInTypes[name]; found {
+		// named type must be defined only once
+		visited[name] = fmt.Sprintf("%s.%s", fixed.Namespace, fixed.Name)
+		return fixed, nil
+	}
 	if recordGenerator, found := avroRecordTypeByBuiltInTypes[name]; found {
 		record := recordGenerator(visited)
 		visited[name] = reference(record)
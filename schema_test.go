@@ -10,7 +10,7 @@ import (
 )
 
 func Test_resolveFieldTypeSchema(t *testing.T) {
-	initBuiltInTypesForTables()
+	initBuiltInTypesForTables(SchemaMappingProfile{})
 	type args struct {
 		fieldType string
 		abi       *ABI
@@ -586,7 +586,7 @@ func Test_resolveFieldTypeSchema(t *testing.T) {
 }
 
 func Test_variantResolveFieldTypeSchema(t *testing.T) {
-	initBuiltInTypesForTables()
+	initBuiltInTypesForTables(SchemaMappingProfile{})
 	type args struct {
 		fieldType string
 		abi       *ABI
@@ -0,0 +1,104 @@
+package dkafka
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+
+	"github.com/riferrei/srclient"
+	"go.uber.org/zap"
+)
+
+const (
+	// NewSchema is the verdict of a schema whose subject is not registered yet
+	NewSchema = "new"
+	// CompatibleSchema is the verdict of a schema compatible with the latest
+	// version of its subject
+	CompatibleSchema = "compatible"
+	// IncompatibleSchema is the verdict of a schema rejected by the
+	// compatibility level of its subject
+	IncompatibleSchema = "incompatible"
+)
+
+// schema registry error codes of an unknown subject or version
+const (
+	subjectNotFoundErrorCode = 40401
+	versionNotFoundErrorCode = 40402
+)
+
+// SchemaDeployer registers or checks the generated schemas ahead of their
+// usage. It relies on the StreamedAbiCodec so the subject naming, the meta and
+// the shared records are the same as at runtime.
+type SchemaDeployer struct {
+	codec *StreamedAbiCodec
+}
+
+// NewSchemaDeployer returns a deployer on the schema registry of the config.
+func NewSchemaDeployer(c *Config) (*SchemaDeployer, error) {
+	if c.SchemaRegistryReadOnly {
+		return nil, fmt.Errorf("cannot deploy schemas on a read-only schema registry")
+	}
+	compatibility, err := c.getCompatibility()
+	if err != nil {
+		return nil, fmt.Errorf("getting compatibility level: %w", err)
+	}
+	options, err := c.streamedAbiCodecOptions(compatibility)
+	if err != nil {
+		return nil, err
+	}
+	schemaRegistryClient, err := newSchemaRegistryClient(c.SchemaRegistryURL, c.SchemaRegistryAuth)
+	if err != nil {
+		return nil, fmt.Errorf("cannot create schema registry client: %w", err)
+	}
+	return newSchemaDeployer(schemaRegistryClient, c.SchemaRegistryURL, compatibility, options...), nil
+}
+
+func newSchemaDeployer(
+	schemaRegistryClient srclient.ISchemaRegistryClient,
+	schemaRegistryURL string,
+	compatibility srclient.CompatibilityLevel,
+	options ...StreamedAbiCodecOption,
+) *SchemaDeployer {
+	// the static schemas are deployed like the generated ones
+	codec := newStreamedAbiCodec(nil, nil, schemaRegistryClient, "", schemaRegistryURL, nil, compatibility, options...)
+	return &SchemaDeployer{codec: codec.(*StreamedAbiCodec)}
+}
+
+// Register registers the schema under its subject with the configured
+// compatibility and returns the subject.
+func (d *SchemaDeployer) Register(messageSchema MessageSchema) (string, *srclient.Schema, error) {
+	messageSchema = d.codec.withCompatibility(messageSchema)
+	subject := d.codec.subjectName(messageSchema)
+	jsonSchema, err := json.Marshal(messageSchema)
+	if err != nil {
+		return subject, nil, err
+	}
+	schema, err := d.codec.registeredSchema(subject, messageSchema, jsonSchema)
+	return subject, schema, err
+}
+
+// Check checks, without registering it, the schema against the latest
+// version of its subject and returns the subject and the verdict. The shared
+// records are inlined in the checked schema, their own subjects are not
+// checked.
+func (d *SchemaDeployer) Check(messageSchema MessageSchema) (string, string, error) {
+	messageSchema = d.codec.withCompatibility(messageSchema)
+	subject := d.codec.subjectName(messageSchema)
+	jsonSchema, err := json.Marshal(messageSchema)
+	if err != nil {
+		return subject, "", err
+	}
+	zlog.Debug("check schema compatibility", zap.String("subject", subject), zap.ByteString("schema", jsonSchema))
+	compatible, err := d.codec.schemaRegistryClient.IsSchemaCompatible(subject, string(jsonSchema), "latest", srclient.Avro)
+	if err != nil {
+		var registryErr srclient.Error
+		if errors.As(err, &registryErr) && (registryErr.Code == subjectNotFoundErrorCode || registryErr.Code == versionNotFoundErrorCode) {
+			return subject, NewSchema, nil
+		}
+		return subject, "", fmt.Errorf("IsSchemaCompatible on subject: '%s', error: %w", subject, err)
+	}
+	if compatible {
+		return subject, CompatibleSchema, nil
+	}
+	return subject, IncompatibleSchema, nil
+}
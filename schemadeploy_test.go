@@ -0,0 +1,102 @@
+package dkafka
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/riferrei/srclient"
+)
+
+func TestSchemaDeployer_Register(t *testing.T) {
+	abi, err := LoadABIFile("eosio.nft.ft", "testdata/eosio.nft.ft.abi")
+	if err != nil {
+		t.Fatalf("LoadABIFile() error: %v", err)
+	}
+	generator := MessageSchemaGenerator{Namespace: "test", Account: "eosio.nft.ft"}
+	registry := srclient.CreateMockSchemaRegistryClient("mock://TestSchemaDeployer_Register")
+	deployer := newSchemaDeployer(registry, "mock://TestSchemaDeployer_Register", srclient.Forward)
+
+	schemas := []MessageSchema{CheckpointMessageSchema, AbiUpdatedMessageSchema, UndecodedTableNotificationMessageSchema}
+	tableSchema, err := generator.getTableSchema("token.a", abi)
+	if err != nil {
+		t.Fatalf("getTableSchema() error: %v", err)
+	}
+	schemas = append(schemas, tableSchema)
+	var tableSchemaID int
+	for _, schema := range schemas {
+		subject, registered, err := deployer.Register(schema)
+		if err != nil {
+			t.Fatalf("Register(%s) error: %v", schema.Name, err)
+		}
+		tableSchemaID = registered.ID()
+		if subject == "" {
+			t.Errorf("Register(%s) empty subject", schema.Name)
+		}
+	}
+
+	// the runtime finds the deployed schemas without registering them
+	abiCodec := NewStreamedAbiCodec(
+		&AbiRepositoryStub{abi: abi},
+		generator.getTableSchema,
+		registry,
+		"eosio.nft.ft",
+		"mock://TestSchemaDeployer_Register",
+		srclient.Forward,
+		WithReadOnlySchemas(),
+	)
+	codec, err := abiCodec.GetCodec(CodecId{"eosio.nft.ft", "token.a"}, 42)
+	if err != nil {
+		t.Fatalf("GetCodec() error: %v", err)
+	}
+	if id := int(codec.(KafkaAvroCodec).schema.id); id != tableSchemaID {
+		t.Errorf("runtime schema ID = %d, want %d", id, tableSchemaID)
+	}
+}
+
+func TestSchemaDeployer_Check(t *testing.T) {
+	var checked []string
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		checked = append(checked, r.URL.Path)
+		switch {
+		case strings.Contains(r.URL.Path, CheckpointMessageSchema.Name):
+			w.Write([]byte(`{"is_compatible": true}`))
+		case strings.Contains(r.URL.Path, AbiUpdatedMessageSchema.Name):
+			w.Write([]byte(`{"is_compatible": false}`))
+		default:
+			w.WriteHeader(http.StatusNotFound)
+			w.Write([]byte(`{"error_code": 40401, "message": "Subject not found."}`))
+		}
+	}))
+	defer server.Close()
+	deployer, err := NewSchemaDeployer(&Config{SchemaRegistryURL: server.URL})
+	if err != nil {
+		t.Fatalf("NewSchemaDeployer() error: %v", err)
+	}
+	tests := []struct {
+		schema      MessageSchema
+		wantVerdict string
+	}{
+		{schema: CheckpointMessageSchema, wantVerdict: CompatibleSchema},
+		{schema: AbiUpdatedMessageSchema, wantVerdict: IncompatibleSchema},
+		{schema: UndecodedTableNotificationMessageSchema, wantVerdict: NewSchema},
+	}
+	for _, tt := range tests {
+		t.Run(tt.schema.Name, func(t *testing.T) {
+			subject, verdict, err := deployer.Check(tt.schema)
+			if err != nil {
+				t.Fatalf("Check() error: %v", err)
+			}
+			if verdict != tt.wantVerdict {
+				t.Errorf("Check() verdict = %s, want %s", verdict, tt.wantVerdict)
+			}
+			if want := "/compatibility/subjects/" + subject + "/versions/latest"; checked[len(checked)-1] != want {
+				t.Errorf("Check() path = %s, want %s", checked[len(checked)-1], want)
+			}
+		})
+	}
+	if len(checked) != len(tests) {
+		t.Errorf("expected %d compatibility checks got: %v", len(tests), checked)
+	}
+}
@@ -0,0 +1,40 @@
+package dkafka
+
+import (
+	"encoding/json"
+	"fmt"
+)
+
+const (
+	AvroSchemaFormat     = "avro"
+	JSONSchemaFormat     = "jsonschema"
+	ProtobufSchemaFormat = "protobuf"
+	avroSchemaExtension  = ".avsc"
+	jsonSchemaExtension  = ".schema.json"
+	protoSchemaExtension = ".proto"
+)
+
+// MarshalSchema returns the message schema in the avro, jsonschema or
+// protobuf format with the file extension of the format.
+func MarshalSchema(format string, messageSchema MessageSchema) ([]byte, string, error) {
+	switch format {
+	case "", AvroSchemaFormat:
+		bytes, err := json.Marshal(messageSchema)
+		return bytes, avroSchemaExtension, err
+	case JSONSchemaFormat:
+		schema, err := GenerateJSONSchema(messageSchema)
+		if err != nil {
+			return nil, "", err
+		}
+		bytes, err := json.MarshalIndent(schema, "", "  ")
+		return bytes, jsonSchemaExtension, err
+	case ProtobufSchemaFormat:
+		file, err := GenerateProtoSchema(messageSchema)
+		if err != nil {
+			return nil, "", err
+		}
+		return []byte(FormatProtoSchema(file)), protoSchemaExtension, nil
+	default:
+		return nil, "", fmt.Errorf("unsupported schema format: '%s', expected one of: %s, %s, %s", format, AvroSchemaFormat, JSONSchemaFormat, ProtobufSchemaFormat)
+	}
+}
@@ -0,0 +1,218 @@
+package dkafka
+
+import (
+	"encoding/binary"
+	"encoding/json"
+	"fmt"
+	"math/big"
+	"strconv"
+	"strings"
+
+	"github.com/eoscanada/eos-go"
+)
+
+const (
+	Uint64AsLong    = "long"
+	Uint64AsDecimal = "decimal"
+	Uint64AsFixed   = "fixed"
+	Uint64AsString  = "string"
+
+	TimePointAsMillis = "millis"
+	TimePointAsMicros = "micros"
+
+	AssetAsDecimal = "decimal"
+	AssetAsSymbol  = "symbol"
+)
+
+// SchemaMappingProfile selects how the ABI built-in types that cannot be
+// represented without loss by the default mapping are converted to avro.
+// The zero value is the default mapping.
+type SchemaMappingProfile struct {
+	// Uint64 is one of long (default), decimal, fixed or string
+	Uint64 string
+	// TimePoint is one of millis (default) or micros
+	TimePoint string
+	// Asset is one of decimal (default, decimal(32,8) amount) or symbol
+	// (amount formatted with the precision of the asset symbol)
+	Asset string
+	// Variants selects the avro representation of the ABI variants
+	Variants VariantMapping
+}
+
+// ParseSchemaMappingProfile parses a list of '{type}={mapping}' entries where
+// type is one of: uint64, time_point or asset.
+func ParseSchemaMappingProfile(specs []string) (profile SchemaMappingProfile, err error) {
+	for _, spec := range specs {
+		kv := strings.SplitN(spec, "=", 2)
+		if len(kv) != 2 {
+			return profile, fmt.Errorf("invalid schema mapping: '%s', expected format: {type}={mapping}", spec)
+		}
+		switch eosType, mapping := kv[0], kv[1]; eosType {
+		case "uint64":
+			if err = checkMapping(spec, mapping, Uint64AsLong, Uint64AsDecimal, Uint64AsFixed, Uint64AsString); err != nil {
+				return
+			}
+			profile.Uint64 = mapping
+		case "time_point":
+			if err = checkMapping(spec, mapping, TimePointAsMillis, TimePointAsMicros); err != nil {
+				return
+			}
+			profile.TimePoint = mapping
+		case "asset":
+			if err = checkMapping(spec, mapping, AssetAsDecimal, AssetAsSymbol); err != nil {
+				return
+			}
+			profile.Asset = mapping
+		default:
+			return profile, fmt.Errorf("unsupported schema mapping type: '%s' in: '%s', expected one of: uint64, time_point, asset", eosType, spec)
+		}
+	}
+	return
+}
+
+func checkMapping(spec string, mapping string, supported ...string) error {
+	for _, s := range supported {
+		if mapping == s {
+			return nil
+		}
+	}
+	return fmt.Errorf("unsupported mapping: '%s' in: '%s', expected one of: %s", mapping, spec, strings.Join(supported, ", "))
+}
+
+// apply overrides the built-in types set by initBuiltInTypesForTables
+func (p SchemaMappingProfile) apply() {
+	switch p.Uint64 {
+	case Uint64AsDecimal:
+		avroDecimalLogicalTypeByBuiltInTypes["uint64"] = NewUint64DecimalType()
+		delete(avroPrimitiveTypeByBuiltInTypes, "uint64")
+	case Uint64AsFixed:
+		avroFixedTypeByBuiltInTypes["uint64"] = uint64FixedSchema
+		delete(avroPrimitiveTypeByBuiltInTypes, "uint64")
+	case Uint64AsString:
+		avroPrimitiveTypeByBuiltInTypes["uint64"] = TypedSchema{Type: "string", EosType: "uint64", Convert: "eos.Uint64String"}
+	}
+	if p.TimePoint == TimePointAsMicros {
+		avroPrimitiveTypeByBuiltInTypes["time_point"] = NewTimestampMicrosType("time_point")
+	}
+	if p.Asset == AssetAsSymbol {
+		avroRecordTypeByBuiltInTypes["asset"] = symbolAssetSchemaGenerator
+	}
+	variantMapping = p.Variants
+}
+
+// NewUint64DecimalType is the lossless decimal(20,0) representation of an
+// uint64 value
+func NewUint64DecimalType() DecimalLogicalType {
+	uint64Type := NewUint64Type()
+	uint64Type.Convert = "eos.Uint64"
+	return uint64Type
+}
+
+// uint64FixedSchema is the big-endian binary representation of an uint64
+var uint64FixedSchema = FixedSchema{
+	Type:      "fixed",
+	Name:      "Uint64",
+	Namespace: "eos",
+	Size:      8,
+	EosType:   "uint64",
+	Convert:   "eos.Uint64Fixed",
+}
+
+var symbolAssetSchema RecordSchema = RecordSchema{
+	Type:      "record",
+	Name:      "Asset",
+	Namespace: "eosio",
+	Convert:   "eosio.SymbolAsset",
+	Fields: []FieldSchema{
+		{
+			Name: "amount",
+			Type: "string",
+			Doc:  "Decimal amount formatted with the precision of the symbol",
+		},
+		{
+			Name: "symbol",
+			Type: "string",
+		},
+		{
+			Name: "precision",
+			Type: "int",
+		},
+	},
+}
+
+func symbolAssetSchemaGenerator(visited map[string]string) RecordSchema {
+	return symbolAssetSchema
+}
+
+func symbolAssetConverter(f func([]byte, interface{}) ([]byte, error)) func([]byte, interface{}) ([]byte, error) {
+	return func(bytes []byte, value interface{}) ([]byte, error) {
+		switch valueType := value.(type) {
+		case eos.Asset:
+			return f(bytes, symbolAssetMap(valueType))
+		case *eos.Asset:
+			return f(bytes, symbolAssetMap(*valueType))
+		default:
+			return bytes, fmt.Errorf("unsupported asset type: %T", value)
+		}
+	}
+}
+
+func symbolAssetMap(asset eos.Asset) map[string]interface{} {
+	amount := new(big.Rat).SetFrac(big.NewInt(int64(asset.Amount)), new(big.Int).Exp(big.NewInt(10), big.NewInt(int64(asset.Symbol.Precision)), nil))
+	return map[string]interface{}{
+		"amount":    amount.FloatString(int(asset.Symbol.Precision)),
+		"symbol":    asset.Symbol.Symbol,
+		"precision": asset.Symbol.Precision,
+	}
+}
+
+// toUint64 supports the native decoded value and the JSON representations of
+// the action parameters
+func toUint64(value interface{}) (uint64, error) {
+	switch valueType := value.(type) {
+	case uint64:
+		return valueType, nil
+	case eos.Uint64:
+		return uint64(valueType), nil
+	case int64:
+		return uint64(valueType), nil
+	case float64:
+		return uint64(valueType), nil
+	case json.Number:
+		return strconv.ParseUint(valueType.String(), 10, 64)
+	case string:
+		return strconv.ParseUint(valueType, 10, 64)
+	default:
+		return 0, fmt.Errorf("unsupported uint64 type: %T", value)
+	}
+}
+
+func uint64Converter(f func([]byte, interface{}) ([]byte, error)) func([]byte, interface{}) ([]byte, error) {
+	return func(bytes []byte, value interface{}) ([]byte, error) {
+		v, err := toUint64(value)
+		if err != nil {
+			return bytes, err
+		}
+		return f(bytes, new(big.Rat).SetInt(new(big.Int).SetUint64(v)))
+	}
+}
+
+func uint64FixedConverter(f func([]byte, interface{}) ([]byte, error)) func([]byte, interface{}) ([]byte, error) {
+	return func(bytes []byte, value interface{}) ([]byte, error) {
+		v, err := toUint64(value)
+		if err != nil {
+			return bytes, err
+		}
+		return f(bytes, binary.BigEndian.AppendUint64(nil, v))
+	}
+}
+
+func uint64StringConverter(f func([]byte, interface{}) ([]byte, error)) func([]byte, interface{}) ([]byte, error) {
+	return func(bytes []byte, value interface{}) ([]byte, error) {
+		v, err := toUint64(value)
+		if err != nil {
+			return bytes, err
+		}
+		return f(bytes, strconv.FormatUint(v, 10))
+	}
+}
@@ -0,0 +1,158 @@
+package dkafka
+
+import (
+	"encoding/json"
+	"math"
+	"math/big"
+	"testing"
+	"time"
+
+	"github.com/eoscanada/eos-go"
+	"github.com/go-test/deep"
+)
+
+func TestParseSchemaMappingProfile(t *testing.T) {
+	tests := []struct {
+		name    string
+		specs   []string
+		want    SchemaMappingProfile
+		wantErr bool
+	}{
+		{
+			name: "default",
+			want: SchemaMappingProfile{},
+		},
+		{
+			name:  "all",
+			specs: []string{"uint64=decimal", "time_point=micros", "asset=symbol"},
+			want:  SchemaMappingProfile{Uint64: Uint64AsDecimal, TimePoint: TimePointAsMicros, Asset: AssetAsSymbol},
+		},
+		{
+			name:    "invalid-format",
+			specs:   []string{"uint64"},
+			wantErr: true,
+		},
+		{
+			name:    "unknown-type",
+			specs:   []string{"int128=string"},
+			wantErr: true,
+		},
+		{
+			name:    "unknown-mapping",
+			specs:   []string{"uint64=double"},
+			wantErr: true,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := ParseSchemaMappingProfile(tt.specs)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("ParseSchemaMappingProfile() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if diff := deep.Equal(got, tt.want); diff != nil {
+				t.Errorf("ParseSchemaMappingProfile() diff: %v", diff)
+			}
+		})
+	}
+}
+
+func TestSchemaMappingProfile_encoding(t *testing.T) {
+	abi := &ABI{
+		ABI: &eos.ABI{
+			Structs: []eos.StructDef{
+				{
+					Name: "row",
+					Fields: []eos.FieldDef{
+						{Name: "id", Type: "uint64"},
+						{Name: "ids", Type: "uint64[]"},
+						{Name: "created_at", Type: "time_point"},
+						{Name: "quantity", Type: "asset"},
+						{Name: "fee", Type: "extended_asset"},
+					},
+				},
+			},
+			Tables: []eos.TableDef{{Name: "rows", Type: "row"}},
+		},
+		Account: "test",
+	}
+	createdAt := time.Date(2022, 1, 1, 0, 0, 0, 123456000, time.UTC)
+	quantity := eos.Asset{Amount: 123456789012, Symbol: eos.Symbol{Precision: 10, Symbol: "UOS"}}
+	row := map[string]interface{}{
+		"id":         uint64(math.MaxUint64),
+		"ids":        []interface{}{uint64(1), uint64(math.MaxUint64)},
+		"created_at": createdAt,
+		"quantity":   quantity,
+		"fee":        eos.ExtendedAsset{Asset: quantity, Contract: "eosio.token"},
+	}
+	maxUint64 := new(big.Rat).SetInt(new(big.Int).SetUint64(math.MaxUint64))
+	tests := []struct {
+		name          string
+		mapping       SchemaMappingProfile
+		wantID        func(interface{}) bool
+		wantCreatedAt time.Time
+		wantAmount    func(interface{}) bool
+	}{
+		{
+			name:          "decimal-micros-symbol",
+			mapping:       SchemaMappingProfile{Uint64: Uint64AsDecimal, TimePoint: TimePointAsMicros, Asset: AssetAsSymbol},
+			wantID:        func(v interface{}) bool { r, ok := v.(*big.Rat); return ok && r.Cmp(maxUint64) == 0 },
+			wantCreatedAt: createdAt,
+			wantAmount:    func(v interface{}) bool { return v == "12.3456789012" },
+		},
+		{
+			name:    "fixed",
+			mapping: SchemaMappingProfile{Uint64: Uint64AsFixed},
+			wantID: func(v interface{}) bool {
+				b, ok := v.([]byte)
+				return ok && string(b) == "\xff\xff\xff\xff\xff\xff\xff\xff"
+			},
+			wantCreatedAt: createdAt.Truncate(time.Millisecond),
+			wantAmount:    func(v interface{}) bool { _, ok := v.(*big.Rat); return ok },
+		},
+		{
+			name:          "string",
+			mapping:       SchemaMappingProfile{Uint64: Uint64AsString},
+			wantID:        func(v interface{}) bool { return v == "18446744073709551615" },
+			wantCreatedAt: createdAt.Truncate(time.Millisecond),
+			wantAmount:    func(v interface{}) bool { _, ok := v.(*big.Rat); return ok },
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			record, err := tableToRecord(abi, "rows", tt.mapping)
+			if err != nil {
+				t.Fatalf("tableToRecord() error: %v", err)
+			}
+			schema, err := json.Marshal(record)
+			if err != nil {
+				t.Fatalf("json.Marshal() error: %v", err)
+			}
+			codec := newAvroCodec(t, string(schema))
+			bytes, err := codec.BinaryFromNative(nil, row)
+			if err != nil {
+				t.Fatalf("BinaryFromNative() error: %v", err)
+			}
+			native, _, err := codec.NativeFromBinary(bytes)
+			if err != nil {
+				t.Fatalf("NativeFromBinary() error: %v", err)
+			}
+			decoded := native.(map[string]interface{})
+			if !tt.wantID(decoded["id"]) {
+				t.Errorf("id = %v (%T)", decoded["id"], decoded["id"])
+			}
+			if ids := decoded["ids"].([]interface{}); !tt.wantID(ids[1]) {
+				t.Errorf("ids[1] = %v (%T)", ids[1], ids[1])
+			}
+			if createdAt := decoded["created_at"].(time.Time); !createdAt.Equal(tt.wantCreatedAt) {
+				t.Errorf("created_at = %v, want %v", createdAt, tt.wantCreatedAt)
+			}
+			if amount := decoded["quantity"].(map[string]interface{})["amount"]; !tt.wantAmount(amount) {
+				t.Errorf("quantity.amount = %v (%T)", amount, amount)
+			}
+			fee := decoded["fee"].(map[string]interface{})["quantity"].(map[string]interface{})
+			if !tt.wantAmount(fee["amount"]) {
+				t.Errorf("fee.quantity.amount = %v (%T)", fee["amount"], fee["amount"])
+			}
+		})
+	}
+}
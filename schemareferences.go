@@ -0,0 +1,149 @@
+package dkafka
+
+import (
+	"encoding/json"
+	"fmt"
+
+	"github.com/riferrei/srclient"
+	"go.uber.org/zap"
+)
+
+// sharedNamespaces are the namespaces of the records shared by the generated
+// schemas. In schema references mode they are registered once under their own
+// subject, named after their full name whatever the subject name strategy,
+// and referenced by the other schemas.
+var sharedNamespaces = map[string]bool{
+	dkafkaNamespace: true,
+	"eosio":         true,
+	"ecc":           true,
+}
+
+// sharedRecord is a shared record extracted from a schema
+type sharedRecord struct {
+	record RecordSchema
+	// references are the full names of the shared records directly used by
+	// this record
+	references []string
+}
+
+// extractSharedRecords returns a copy of the record where the shared records
+// are replaced by their full name, the full names of the shared records
+// directly used by the record and the shared records themselves sorted by
+// dependency order.
+func extractSharedRecords(record RecordSchema) (RecordSchema, []string, []sharedRecord) {
+	var shared []sharedRecord
+	var references []string
+	seen := map[string]bool{reference(record): true}
+	record = withSharedReferences(record, &references, &shared, seen)
+	return record, references, shared
+}
+
+func withSharedReferences(record RecordSchema, references *[]string, shared *[]sharedRecord, seen map[string]bool) RecordSchema {
+	fields := make([]FieldSchema, len(record.Fields))
+	for i, field := range record.Fields {
+		field.Type = sharedReference(field.Type, references, shared, seen)
+		fields[i] = field
+	}
+	record.Fields = fields
+	return record
+}
+
+func sharedReference(schema Schema, references *[]string, shared *[]sharedRecord, seen map[string]bool) Schema {
+	switch s := schema.(type) {
+	case RecordSchema:
+		if !sharedNamespaces[s.Namespace] {
+			return withSharedReferences(s, references, shared, seen)
+		}
+		name := reference(s)
+		*references = append(*references, name)
+		if !seen[name] {
+			seen[name] = true
+			var recordReferences []string
+			s = withSharedReferences(s, &recordReferences, shared, seen)
+			*shared = append(*shared, sharedRecord{record: s, references: recordReferences})
+		}
+		return name
+	case Union:
+		union := make(Union, len(s))
+		for i, member := range s {
+			union[i] = sharedReference(member, references, shared, seen)
+		}
+		return union
+	case ArraySchema:
+		s.Items = sharedReference(s.Items, references, shared, seen)
+		return s
+	default:
+		return schema
+	}
+}
+
+// registeredReference is a shared record registered in the schema registry
+type registeredReference struct {
+	schema    string
+	reference srclient.Reference
+}
+
+// referenceSharedRecords registers the shared records of the message schema
+// under their own subject and returns the message schema that references
+// them.
+func (s *StreamedAbiCodec) referenceSharedRecords(messageSchema MessageSchema) ([]byte, []srclient.Reference, error) {
+	record, names, shared := extractSharedRecords(messageSchema.RecordSchema)
+	if s.readOnly {
+		// the shared records are pre-registered
+		shared = nil
+	}
+	for _, r := range shared {
+		if err := s.registerSharedRecord(r); err != nil {
+			return nil, nil, err
+		}
+	}
+	messageSchema.RecordSchema = record
+	jsonSchema, err := json.Marshal(messageSchema)
+	if err != nil {
+		return nil, nil, err
+	}
+	return jsonSchema, s.sharedReferences(names), nil
+}
+
+func (s *StreamedAbiCodec) registerSharedRecord(shared sharedRecord) error {
+	subject := reference(shared.record)
+	jsonSchema, err := json.Marshal(shared.record)
+	if err != nil {
+		return err
+	}
+	if registered, found := s.registeredReferences[subject]; found && registered.schema == string(jsonSchema) {
+		return nil
+	}
+	references := s.sharedReferences(shared.references)
+	zlog.Debug("register shared record", zap.String("subject", subject), zap.ByteString("schema", jsonSchema))
+	schema, err := s.registerSchema(subject, string(jsonSchema), srclient.Avro, references)
+	if err != nil {
+		return err
+	}
+	version := schema.Version()
+	if version == 0 {
+		// the schema registry does not return the version on creation
+		if schema, err = s.schemaRegistryClient.LookupSchema(subject, string(jsonSchema), srclient.Avro, references...); err != nil {
+			return fmt.Errorf("LookupSchema on subject: '%s', error: %w", subject, err)
+		}
+		version = schema.Version()
+	}
+	s.registeredReferences[subject] = registeredReference{
+		schema:    string(jsonSchema),
+		reference: srclient.Reference{Name: subject, Subject: subject, Version: version},
+	}
+	return nil
+}
+
+func (s *StreamedAbiCodec) sharedReferences(names []string) []srclient.Reference {
+	references := make([]srclient.Reference, 0, len(names))
+	added := make(map[string]bool, len(names))
+	for _, name := range names {
+		if added[name] {
+			continue
+		}
+		added[name] = true
+		references = append(references, s.registeredReferences[name].reference)
+	}
+	return references
+}
@@ -0,0 +1,91 @@
+package dkafka
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/go-test/deep"
+	"github.com/riferrei/srclient"
+)
+
+func TestExtractSharedRecords(t *testing.T) {
+	context := newNotificationContextSchema()
+	correlation := newCorrelationRecord()
+	shared := newRecordFQN(dkafkaNamespace, "Shared", []FieldSchema{
+		{Name: "context", Type: context},
+	})
+	local := newRecordS("local", []FieldSchema{
+		{Name: "context", Type: "io.dkafka.NotificationContext"},
+		NewOptionalField("correlation", correlation),
+	})
+	record := newRecordFQN("test", "Root", []FieldSchema{
+		{Name: "shared", Type: shared},
+		{Name: "locals", Type: NewArray(local)},
+	})
+
+	gotRecord, gotReferences, gotShared := extractSharedRecords(record)
+
+	wantRecord := newRecordFQN("test", "Root", []FieldSchema{
+		{Name: "shared", Type: "io.dkafka.Shared"},
+		{Name: "locals", Type: NewArray(newRecordS("local", []FieldSchema{
+			{Name: "context", Type: "io.dkafka.NotificationContext"},
+			NewOptionalField("correlation", "io.dkafka.Correlation"),
+		}))},
+	})
+	if diff := deep.Equal(gotRecord, wantRecord); diff != nil {
+		t.Errorf("extractSharedRecords() record diff: %v", diff)
+	}
+	if diff := deep.Equal(gotReferences, []string{"io.dkafka.Shared", "io.dkafka.Correlation"}); diff != nil {
+		t.Errorf("extractSharedRecords() references diff: %v", diff)
+	}
+	wantShared := []sharedRecord{
+		{record: context},
+		{record: newRecordFQN(dkafkaNamespace, "Shared", []FieldSchema{{Name: "context", Type: "io.dkafka.NotificationContext"}}), references: []string{"io.dkafka.NotificationContext"}},
+		{record: correlation},
+	}
+	if diff := deep.Equal(gotShared, wantShared); diff != nil {
+		t.Errorf("extractSharedRecords() shared diff: %v", diff)
+	}
+}
+
+func TestStreamedAbiCodec_schemaReferences(t *testing.T) {
+	abi, err := LoadABIFile("eosio.nft.ft", "testdata/eosio.nft.ft.abi")
+	if err != nil {
+		t.Fatalf("LoadABIFile() error: %v", err)
+	}
+	registry := srclient.CreateMockSchemaRegistryClient("mock://TestStreamedAbiCodec_schemaReferences")
+	abiCodec := NewStreamedAbiCodec(
+		&AbiRepositoryStub{abi: abi},
+		MessageSchemaGenerator{Namespace: "test", Account: "eosio.nft.ft"}.getTableSchema,
+		registry,
+		"eosio.nft.ft",
+		"mock://TestStreamedAbiCodec_schemaReferences",
+		srclient.Forward,
+		WithSchemaReferences(),
+		WithUndecodedTableNotifications(),
+	)
+	for _, table := range []string{"factory.a", "factory.b"} {
+		if _, err := abiCodec.GetCodec(CodecId{"eosio.nft.ft", table}, 42); err != nil {
+			t.Fatalf("GetCodec(%s) error: %v", table, err)
+		}
+	}
+	for _, subject := range []string{"io.dkafka.NotificationContext", "io.dkafka.ActionInfoBasic", "io.dkafka.DBOpBasic"} {
+		versions, err := registry.GetSchemaVersions(subject)
+		if err != nil {
+			t.Fatalf("GetSchemaVersions(%s) error: %v", subject, err)
+		}
+		if len(versions) != 1 {
+			t.Errorf("subject %s expected 1 version got: %v", subject, versions)
+		}
+	}
+	schema, err := registry.GetLatestSchema("test.eosio.nft.ft.tables.v0.FactoryATableNotification")
+	if err != nil {
+		t.Fatalf("GetLatestSchema() error: %v", err)
+	}
+	if strings.Contains(schema.Schema(), `"name":"NotificationContext"`) {
+		t.Errorf("NotificationContext is inlined in: %s", schema.Schema())
+	}
+	if !strings.Contains(schema.Schema(), `"io.dkafka.NotificationContext"`) {
+		t.Errorf("NotificationContext is not referenced in: %s", schema.Schema())
+	}
+}
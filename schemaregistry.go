@@ -2,13 +2,19 @@ package dkafka
 
 import (
 	"bytes"
+	"crypto/tls"
+	"crypto/x509"
 	"encoding/json"
 	"fmt"
 	"io"
 	"io/ioutil"
 	"net/http"
 	"net/url"
+	"os"
 	"regexp"
+	"time"
+
+	"github.com/riferrei/srclient"
 )
 
 const (
@@ -121,3 +127,92 @@ func createError(resp *http.Response) error {
 
 	return err
 }
+
+// SchemaRegistryAuth is the authentication and TLS configuration of the
+// schema registry connections. The secrets are never serialized.
+type SchemaRegistryAuth struct {
+	Username    string
+	Password    string `json:"-"`
+	BearerToken string `json:"-"`
+	// CAFile is the PEM file of the certificate authorities trusted in
+	// addition to the system ones
+	CAFile string
+	// CertFile and KeyFile are the PEM client certificate and key used for
+	// mutual TLS
+	CertFile string
+	KeyFile  string
+}
+
+func (a SchemaRegistryAuth) validate() error {
+	if a.BearerToken != "" && (a.Username != "" || a.Password != "") {
+		return fmt.Errorf("schema registry basic authentication and bearer token are mutually exclusive")
+	}
+	if (a.CertFile == "") != (a.KeyFile == "") {
+		return fmt.Errorf("schema registry client certificate and key files must be provided together")
+	}
+	return nil
+}
+
+func (a SchemaRegistryAuth) tlsConfig() (*tls.Config, error) {
+	if a.CAFile == "" && a.CertFile == "" {
+		return nil, nil
+	}
+	config := &tls.Config{MinVersion: tls.VersionTLS12}
+	if a.CAFile != "" {
+		pool, err := x509.SystemCertPool()
+		if err != nil {
+			pool = x509.NewCertPool()
+		}
+		pem, err := os.ReadFile(a.CAFile)
+		if err != nil {
+			return nil, fmt.Errorf("cannot read schema registry CA file: %s, error: %w", a.CAFile, err)
+		}
+		if !pool.AppendCertsFromPEM(pem) {
+			return nil, fmt.Errorf("no certificate found in schema registry CA file: %s", a.CAFile)
+		}
+		config.RootCAs = pool
+	}
+	if a.CertFile != "" {
+		certificate, err := tls.LoadX509KeyPair(a.CertFile, a.KeyFile)
+		if err != nil {
+			return nil, fmt.Errorf("cannot load schema registry client certificate: %s, key: %s, error: %w", a.CertFile, a.KeyFile, err)
+		}
+		config.Certificates = []tls.Certificate{certificate}
+	}
+	return config, nil
+}
+
+// bearerTokenTransport sets the bearer token on every request
+type bearerTokenTransport struct {
+	token string
+	next  http.RoundTripper
+}
+
+func (t bearerTokenTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	req = req.Clone(req.Context())
+	req.Header.Set("Authorization", "Bearer "+t.token)
+	return t.next.RoundTrip(req)
+}
+
+// newSchemaRegistryClient returns a schema registry client that authenticates
+// every call, compatibility changes included.
+func newSchemaRegistryClient(schemaRegistryURL string, auth SchemaRegistryAuth) (*srclient.SchemaRegistryClient, error) {
+	if err := auth.validate(); err != nil {
+		return nil, err
+	}
+	tlsConfig, err := auth.tlsConfig()
+	if err != nil {
+		return nil, err
+	}
+	transport := http.DefaultTransport.(*http.Transport).Clone()
+	transport.TLSClientConfig = tlsConfig
+	var roundTripper http.RoundTripper = transport
+	if auth.BearerToken != "" {
+		roundTripper = bearerTokenTransport{token: auth.BearerToken, next: transport}
+	}
+	client := srclient.CreateSchemaRegistryClientWithOptions(schemaRegistryURL, &http.Client{Timeout: 5 * time.Second, Transport: roundTripper}, 16)
+	if auth.Username != "" {
+		client.SetCredentials(auth.Username, auth.Password)
+	}
+	return client, nil
+}
@@ -0,0 +1,100 @@
+package dkafka
+
+import (
+	"encoding/json"
+	"encoding/pem"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/riferrei/srclient"
+)
+
+func TestNewSchemaRegistryClient(t *testing.T) {
+	tests := []struct {
+		name          string
+		auth          SchemaRegistryAuth
+		wantAuthorize string
+		wantErr       bool
+	}{
+		{
+			name:          "basic",
+			auth:          SchemaRegistryAuth{Username: "user", Password: "secret"},
+			wantAuthorize: "Basic dXNlcjpzZWNyZXQ=",
+		},
+		{
+			name:          "bearer",
+			auth:          SchemaRegistryAuth{BearerToken: "token"},
+			wantAuthorize: "Bearer token",
+		},
+		{
+			name:    "basic-and-bearer",
+			auth:    SchemaRegistryAuth{Username: "user", BearerToken: "token"},
+			wantErr: true,
+		},
+		{
+			name:    "cert-without-key",
+			auth:    SchemaRegistryAuth{CertFile: "client.pem"},
+			wantErr: true,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var authorizations []string
+			server := httptest.NewTLSServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				authorizations = append(authorizations, r.Header.Get("Authorization"))
+				w.Write([]byte(`{"compatibilityLevel": "FORWARD"}`))
+			}))
+			defer server.Close()
+			caFile := filepath.Join(t.TempDir(), "ca.pem")
+			ca := pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: server.Certificate().Raw})
+			if err := os.WriteFile(caFile, ca, 0644); err != nil {
+				t.Fatal(err)
+			}
+			tt.auth.CAFile = caFile
+
+			client, err := newSchemaRegistryClient(server.URL, tt.auth)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("newSchemaRegistryClient() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if err != nil {
+				return
+			}
+			if _, err := client.GetCompatibilityLevel("subject", true); err != nil {
+				t.Fatalf("GetCompatibilityLevel() error: %v", err)
+			}
+			if _, err := client.ChangeSubjectCompatibilityLevel("subject", srclient.Forward); err != nil {
+				t.Fatalf("ChangeSubjectCompatibilityLevel() error: %v", err)
+			}
+			if len(authorizations) != 2 {
+				t.Fatalf("expected 2 requests got: %d", len(authorizations))
+			}
+			for _, authorization := range authorizations {
+				if authorization != tt.wantAuthorize {
+					t.Errorf("Authorization header = %s, want %s", authorization, tt.wantAuthorize)
+				}
+			}
+		})
+	}
+}
+
+func TestConfig_secretsNotSerialized(t *testing.T) {
+	config := Config{
+		DfuseToken: "dfuse-secret",
+		SchemaRegistryAuth: SchemaRegistryAuth{
+			Username:    "user",
+			Password:    "registry-secret",
+			BearerToken: "token-secret",
+		},
+	}
+	j, err := json.Marshal(config)
+	if err != nil {
+		t.Fatalf("json.Marshal() error: %v", err)
+	}
+	if strings.Contains(string(j), "secret") {
+		t.Errorf("secrets found in: %s", string(j))
+	}
+}
@@ -28,10 +28,15 @@ type Sender interface {
 	SaveCP(ctx context.Context, location location) error
 }
 
-type DryRunSender struct{}
+type DryRunSender struct {
+	cloudEventsMode string
+}
 
 func (s *DryRunSender) Send(ctx context.Context, messages []*kafka.Message, location location) error {
 	for i, msg := range messages {
+		if err := encodeCloudEvent(s.cloudEventsMode, msg); err != nil {
+			return err
+		}
 		outJson, err := messageToJSON(msg)
 		if err != nil {
 			return err
@@ -46,16 +51,20 @@ func (s *DryRunSender) SaveCP(ctx context.Context, location location) error {
 }
 
 type FastKafkaSender struct {
-	producer *kafka.Producer
-	headers  []kafka.Header
-	topic    string
-	abiCodec ABICodec
+	producer        *kafka.Producer
+	headers         []kafka.Header
+	topic           string
+	abiCodec        ABICodec
+	cloudEventsMode string
 }
 
 func (s *FastKafkaSender) Send(ctx context.Context, messages []*kafka.Message, location location) error {
 	zlog.Debug("send messages", zap.Uint32("block_id", location.blockNum()), zap.String("block_id", location.blockId()), zap.Int("nb", len(messages)))
 	for _, msg := range messages {
 		msg.Headers = appendLocation(msg.Headers, location)
+		if err := encodeCloudEvent(s.cloudEventsMode, msg); err != nil {
+			return err
+		}
 		if err := send(s.producer, msg); err != nil {
 			return err
 		}
@@ -115,10 +124,21 @@ func (s *FastKafkaSender) SaveCP(ctx context.Context, location location) error {
 			Partition: kafka.PartitionAny,
 		},
 	}
+	if err := encodeCloudEvent(s.cloudEventsMode, &msg); err != nil {
+		return fmt.Errorf("SaveCP() fail to encode %s: %w", dkafkaCheckpoint, err)
+	}
 
 	return send(s.producer, &msg)
 }
 
+// encodeCloudEvent converts the binary mode message into the cloudevents mode
+func encodeCloudEvent(mode string, msg *kafka.Message) error {
+	if mode != StructuredCloudEventsMode {
+		return nil
+	}
+	return toStructuredCloudEvent(msg)
+}
+
 func appendLocation(headers []kafka.Header, location location) []kafka.Header {
 	return append(headers, newCursorHeader(location.opaqueCursor()),
 		newPreviousCursorHeader(location.previousOpaqueCursor()))
@@ -153,12 +173,13 @@ func send(producer *kafka.Producer, msg *kafka.Message) error {
 	return nil
 }
 
-func NewFastSender(ctx context.Context, producer *kafka.Producer, topic string, headers []kafka.Header, abiCodec ABICodec) Sender {
+func NewFastSender(ctx context.Context, producer *kafka.Producer, topic string, headers []kafka.Header, abiCodec ABICodec, cloudEventsMode string) Sender {
 	ks := FastKafkaSender{
-		producer: producer,
-		headers:  headers,
-		topic:    topic,
-		abiCodec: abiCodec,
+		producer:        producer,
+		headers:         headers,
+		topic:           topic,
+		abiCodec:        abiCodec,
+		cloudEventsMode: cloudEventsMode,
 	}
 	return &ks
 }
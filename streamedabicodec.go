@@ -4,6 +4,7 @@ import (
 	"context"
 	"encoding/json"
 	"fmt"
+	"sort"
 	"strings"
 
 	pbabicodec "github.com/dfuse-io/dfuse-eosio/pb/dfuse/eosio/abicodec/v1"
@@ -13,6 +14,9 @@ import (
 	"github.com/riferrei/srclient"
 	pbbstream "github.com/streamingfast/pbgo/dfuse/bstream/v1"
 	"go.uber.org/zap"
+	"google.golang.org/protobuf/reflect/protodesc"
+	"google.golang.org/protobuf/reflect/protoreflect"
+	"google.golang.org/protobuf/reflect/protoregistry"
 )
 
 const (
@@ -26,16 +30,14 @@ type AbiRepository interface {
 }
 
 type DfuseAbiRepository struct {
-	overrides   map[string]*ABI
+	overrides   map[string][]*ABI
 	abiCodecCli pbabicodec.DecoderClient
 	context     context.Context
 }
 
 func (a *DfuseAbiRepository) GetAbi(contract string, blockNum uint32) (*ABI, error) {
-	if a.overrides != nil {
-		if abi, ok := a.overrides[contract]; ok {
-			return abi, nil
-		}
+	if abi, ok := overrideAt(a.overrides, contract, blockNum); ok {
+		return abi, nil
 	}
 	if a.abiCodecCli == nil {
 		return nil, fmt.Errorf("unable to get abi for contract %q, no client, no overrides you need at least one of them", contract)
@@ -62,21 +64,44 @@ func (a *DfuseAbiRepository) IsNOOP() bool {
 	return a.overrides == nil && a.abiCodecCli == nil
 }
 
+func (a *DfuseAbiRepository) overriddenVersions(contract string) []*ABI {
+	return a.overrides[contract]
+}
+
 func (a CodecId) String() string {
 	return fmt.Sprintf("%v::%v", a.Account, a.Name)
 }
 
+// AbiCodecId identifies a codec generated from a given ABI version
+type AbiCodecId struct {
+	CodecId
+	AbiBlockNum uint32
+}
+
 type StreamedAbiCodec struct {
-	bootstrapper         AbiRepository
-	latestABIs           map[string]*ABI
-	abiHistories         map[string][]*ABI
+	bootstrapper AbiRepository
+	// abiVersions keeps, per account, every known ABI version sorted by
+	// activation block number (AbiBlockNum)
+	abiVersions          map[string][]*ABI
 	getSchema            MessageSchemaSupplier
 	schemaRegistryClient srclient.ISchemaRegistryClient
 	account              string
-	codecCache           map[CodecId]Codec
+	staticCodecs         map[CodecId]Codec
+	codecCache           map[AbiCodecId]Codec
 	schemaRegistryURL    string
 	staticSchemas        []MessageSchema
 	compatibility        srclient.CompatibilityLevel
+	// schemaReferences registers the shared records under their own subject
+	// and references them instead of inlining them
+	schemaReferences     bool
+	registeredReferences map[string]registeredReference
+	subjectName          SubjectNameStrategy
+	// readOnly looks up the pre-registered schemas instead of registering them
+	readOnly bool
+	// codec is the encoding of the messages, avro when empty
+	codec CodecType
+	// rawMessages are the protobuf messages published as is
+	rawMessages []protoreflect.MessageDescriptor
 }
 
 type StreamAbiCodecConstructor = func(AbiRepository,
@@ -85,8 +110,78 @@ type StreamAbiCodecConstructor = func(AbiRepository,
 	string,
 	string,
 	srclient.CompatibilityLevel,
+	...StreamedAbiCodecOption,
 ) ABICodec
 
+type StreamedAbiCodecOption func(*StreamedAbiCodec)
+
+// WithReadOnlySchemas never registers schemas nor changes the subjects
+// compatibility. The generated schemas must match, by fingerprint, a version
+// already registered under their subject.
+func WithReadOnlySchemas() StreamedAbiCodecOption {
+	return func(s *StreamedAbiCodec) {
+		s.readOnly = true
+	}
+}
+
+// WithRawProtobufMessages registers the protobuf definition of the messages
+// published as is, their codecs are identified by the message full name and
+// created on first use.
+func WithRawProtobufMessages(messages ...protoreflect.MessageDescriptor) StreamedAbiCodecOption {
+	return func(s *StreamedAbiCodec) {
+		s.rawMessages = append(s.rawMessages, messages...)
+	}
+}
+
+// WithUndecodedTableNotifications registers the UndecodedTableNotification
+// schema produced by the tables CDC with the raw undecodable DB op policy.
+func WithUndecodedTableNotifications() StreamedAbiCodecOption {
+	return func(s *StreamedAbiCodec) {
+		s.staticSchemas = append(s.staticSchemas, UndecodedTableNotificationMessageSchema)
+	}
+}
+
+// WithSubjectNameStrategy names the subjects of the registered schemas,
+// shared records excepted, see WithSchemaReferences.
+func WithSubjectNameStrategy(subjectName SubjectNameStrategy) StreamedAbiCodecOption {
+	return func(s *StreamedAbiCodec) {
+		s.subjectName = subjectName
+	}
+}
+
+// WithAvroJsonCodec registers the avro schemas like the avro codec but
+// encodes the messages in the avro JSON encoding.
+func WithAvroJsonCodec() StreamedAbiCodecOption {
+	return func(s *StreamedAbiCodec) {
+		s.codec = AvroJsonCodec
+	}
+}
+
+// WithProtobufCodec registers the protobuf definition of the generated schemas
+// and encodes the messages in protobuf instead of avro.
+func WithProtobufCodec() StreamedAbiCodecOption {
+	return func(s *StreamedAbiCodec) {
+		s.codec = ProtobufCodec
+	}
+}
+
+// WithJsonSchemaCodec registers the JSON Schema of the generated schemas and
+// encodes the messages in JSON validated by their JSON Schema.
+func WithJsonSchemaCodec() StreamedAbiCodecOption {
+	return func(s *StreamedAbiCodec) {
+		s.codec = JsonSchemaCodec
+	}
+}
+
+// WithSchemaReferences registers the records of the shared namespaces
+// (io.dkafka, eosio and ecc) once under their own subject and makes the
+// generated schemas reference them.
+func WithSchemaReferences() StreamedAbiCodecOption {
+	return func(s *StreamedAbiCodec) {
+		s.schemaReferences = true
+	}
+}
+
 func NewStreamedAbiCodec(
 	bootstrapper AbiRepository,
 	getSchema MessageSchemaSupplier,
@@ -94,6 +189,7 @@ func NewStreamedAbiCodec(
 	account string,
 	schemaRegistryURL string,
 	compatibility srclient.CompatibilityLevel,
+	options ...StreamedAbiCodecOption,
 ) ABICodec {
 	return newStreamedAbiCodec(
 		bootstrapper,
@@ -101,8 +197,9 @@ func NewStreamedAbiCodec(
 		schemaRegistryClient,
 		account,
 		schemaRegistryURL,
-		[]MessageSchema{CheckpointMessageSchema},
+		[]MessageSchema{CheckpointMessageSchema, AbiUpdatedMessageSchema},
 		compatibility,
+		options...,
 	)
 }
 
@@ -113,6 +210,7 @@ func NewStreamedAbiCodecWithTransaction(
 	account string,
 	schemaRegistryURL string,
 	compatibility srclient.CompatibilityLevel,
+	options ...StreamedAbiCodecOption,
 ) ABICodec {
 	return newStreamedAbiCodec(
 		bootstrapper,
@@ -120,8 +218,9 @@ func NewStreamedAbiCodecWithTransaction(
 		schemaRegistryClient,
 		account,
 		schemaRegistryURL,
-		[]MessageSchema{CheckpointMessageSchema, TransactionMessageSchema},
+		[]MessageSchema{CheckpointMessageSchema, AbiUpdatedMessageSchema, TransactionMessageSchema},
 		compatibility,
+		options...,
 	)
 }
 
@@ -133,6 +232,7 @@ func newStreamedAbiCodec(
 	schemaRegistryURL string,
 	staticSchemas []MessageSchema,
 	compatibility srclient.CompatibilityLevel,
+	options ...StreamedAbiCodecOption,
 ) ABICodec {
 	codec := &StreamedAbiCodec{
 		bootstrapper:         bootstrapper,
@@ -141,12 +241,16 @@ func newStreamedAbiCodec(
 		account:              account,
 		schemaRegistryURL:    schemaRegistryURL,
 		staticSchemas:        staticSchemas,
-		latestABIs:           make(map[string]*ABI),
-		abiHistories:         make(map[string][]*ABI),
-		codecCache:           make(map[CodecId]Codec),
+		abiVersions:          make(map[string][]*ABI),
+		codecCache:           make(map[AbiCodecId]Codec),
 		compatibility:        compatibility,
+		registeredReferences: make(map[string]registeredReference),
+		subjectName:          schemaSubject,
+	}
+	for _, option := range options {
+		option(codec)
 	}
-	codec.resetCodecs()
+	codec.staticCodecs = codec.initStaticSchemas(make(map[CodecId]Codec))
 	return codec
 }
 
@@ -155,14 +259,25 @@ func (s *StreamedAbiCodec) IsNOOP() bool {
 }
 
 func (s *StreamedAbiCodec) GetCodec(codecId CodecId, blockNum uint32) (Codec, error) {
-
-	if codec, found := s.codecCache[codecId]; found {
+	if codec, found := s.staticCodecs[codecId]; found {
+		return codec, nil
+	}
+	if message, found := s.rawMessage(codecId); found {
+		codec, err := s.newRawProtobufCodec(message)
+		if err != nil {
+			return nil, fmt.Errorf("cannot create raw protobuf codec: %s, error: %w", codecId, err)
+		}
+		s.staticCodecs[codecId] = codec
 		return codec, nil
 	}
-	abi, err := s.getLatestAbi(codecId.Account, blockNum)
+	abi, err := s.getAbi(codecId.Account, blockNum)
 	if err != nil {
 		return nil, fmt.Errorf("cannot get ABI for codec: %s, error: %w", codecId, err)
 	}
+	abiCodecId := AbiCodecId{codecId, abi.AbiBlockNum}
+	if codec, found := s.codecCache[abiCodecId]; found {
+		return codec, nil
+	}
 	zlog.Debug("create schema from abi", zap.Uint32("block_num", blockNum), zap.Uint32("abi_block_num", abi.AbiBlockNum), zap.Stringer("entry", codecId))
 	messageSchema, err := s.getSchema(codecId.Name, abi)
 	if err != nil {
@@ -172,22 +287,107 @@ func (s *StreamedAbiCodec) GetCodec(codecId CodecId, blockNum uint32) (Codec, er
 	if err != nil {
 		return nil, fmt.Errorf("StreamedAbiCodec.newCodec fail to create codec for schema %s, error: %w", messageSchema.Name, err)
 	}
-	zlog.Debug("register codec into cache", zap.Stringer("name", codecId))
-	s.codecCache[codecId] = codec
+	zlog.Debug("register codec into cache", zap.Stringer("name", codecId), zap.Uint32("abi_block_num", abi.AbiBlockNum))
+	s.codecCache[abiCodecId] = codec
 	return codec, nil
 }
 
-func (s *StreamedAbiCodec) getLatestAbi(account string, blockNum uint32) (latestAbi *ABI, err error) {
-	var found = false
-	if latestAbi, found = s.latestABIs[account]; !found {
-		if bootstrapAbi, er := s.bootstrapper.GetAbi(account, blockNum); er != nil {
-			err = fmt.Errorf("fail to bootstrap ABI at block: %d, error: %w", blockNum, err)
-		} else {
-			latestAbi = bootstrapAbi
-			s.latestABIs[account] = latestAbi
+func (s *StreamedAbiCodec) GetABI(account string, blockNum uint32) (*ABI, error) {
+	return s.getAbi(account, blockNum)
+}
+
+// getAbi returns the ABI version of the account valid at the given block. If
+// no known version was activated at or before this block the ABI is fetched
+// from the bootstrapper and added to the known versions.
+func (s *StreamedAbiCodec) getAbi(account string, blockNum uint32) (*ABI, error) {
+	if _, known := s.abiVersions[account]; !known {
+		// local ABIs are all known upfront so they can be crossed without bootstrapping
+		if overrider, ok := s.bootstrapper.(abiOverrider); ok {
+			for _, abi := range overrider.overriddenVersions(account) {
+				s.addAbiVersion(abi)
+			}
+		}
+	}
+	if abi := abiVersionAt(s.abiVersions[account], blockNum); abi != nil {
+		return abi, nil
+	}
+	abi, err := s.bootstrapper.GetAbi(account, blockNum)
+	if err != nil {
+		return nil, fmt.Errorf("fail to bootstrap ABI at block: %d, error: %w", blockNum, err)
+	}
+	zlog.Info("bootstrap abi version", zap.String("account", account), zap.Uint32("block_num", blockNum), zap.Uint32("abi_block_num", abi.AbiBlockNum))
+	return s.addAbiVersion(abi), nil
+}
+
+// addAbiVersion inserts a copy of the ABI in the account versions, replacing
+// the version activated at the same block if any, and returns it. The copy is
+// owned by the codec so that MarkIrreversible does not flag the ABI shared
+// with the overrides or the bootstrapper cache.
+func (s *StreamedAbiCodec) addAbiVersion(abi *ABI) *ABI {
+	version := *abi
+	versions := s.abiVersions[abi.Account]
+	i := sort.Search(len(versions), func(i int) bool { return versions[i].AbiBlockNum >= abi.AbiBlockNum })
+	if i < len(versions) && versions[i].AbiBlockNum == abi.AbiBlockNum {
+		versions[i] = &version
+		return &version
+	}
+	versions = append(versions, nil)
+	copy(versions[i+1:], versions[i:])
+	versions[i] = &version
+	s.abiVersions[abi.Account] = versions
+	abiHistoryDepth.WithLabelValues(abi.Account).Set(float64(len(versions)))
+	return &version
+}
+
+// removeAbiVersion removes the ABI version of the account activated at
+// abiBlockNum with all the codecs generated from it.
+func (s *StreamedAbiCodec) removeAbiVersion(account string, abiBlockNum uint32) bool {
+	versions := s.abiVersions[account]
+	i := sort.Search(len(versions), func(i int) bool { return versions[i].AbiBlockNum >= abiBlockNum })
+	if i == len(versions) || versions[i].AbiBlockNum != abiBlockNum {
+		return false
+	}
+	versions = append(versions[:i], versions[i+1:]...)
+	if len(versions) == 0 {
+		delete(s.abiVersions, account)
+	} else {
+		s.abiVersions[account] = versions
+	}
+	abiHistoryDepth.WithLabelValues(account).Set(float64(len(versions)))
+	s.removeCodecs(account, abiBlockNum)
+	return true
+}
+
+func (s *StreamedAbiCodec) removeCodecs(account string, abiBlockNum uint32) {
+	for id := range s.codecCache {
+		if id.Account == account && id.AbiBlockNum == abiBlockNum {
+			delete(s.codecCache, id)
 		}
 	}
-	return
+}
+
+// MarkIrreversible flags the ABI versions activated at or before the last
+// irreversible block and drops the history that can no longer be undone. As
+// every block to come, undone ones included, is above the LIB only the
+// latest irreversible version of each account is kept.
+func (s *StreamedAbiCodec) MarkIrreversible(libNum uint32) {
+	for account, versions := range s.abiVersions {
+		i := sort.Search(len(versions), func(i int) bool { return versions[i].AbiBlockNum > libNum }) - 1
+		if i < 0 {
+			continue
+		}
+		versions[i].Irreversible = true
+		if i == 0 {
+			continue
+		}
+		for _, pruned := range versions[:i] {
+			zlog.Debug("prune irreversible ABI version", zap.String("account", account), zap.Uint32("abi_block_num", pruned.AbiBlockNum), zap.Uint32("lib_num", libNum))
+			s.removeCodecs(account, pruned.AbiBlockNum)
+		}
+		versions = append([]*ABI{}, versions[i:]...)
+		s.abiVersions[account] = versions
+		abiHistoryDepth.WithLabelValues(account).Set(float64(len(versions)))
+	}
 }
 
 func (s *StreamedAbiCodec) DecodeDBOp(in *pbcodec.DBOp, blockNum uint32) (decoded *decodedDBOp, err error) {
@@ -197,11 +397,10 @@ func (s *StreamedAbiCodec) DecodeDBOp(in *pbcodec.DBOp, blockNum uint32) (decode
 }
 
 func (s *StreamedAbiCodec) decodeDBOp(op *decodedDBOp, blockNum uint32) error {
-	latestAbi, err := s.getLatestAbi(op.Code, blockNum)
+	abi, err := s.getAbi(op.Code, blockNum)
 	if err != nil {
 		return fmt.Errorf("fail to get ABI for decoding dbop in block: %d, error: %w", blockNum, err)
 	}
-	abi := latestAbi
 	tableDef := abi.TableForName(eos.TableName(op.TableName))
 	if tableDef == nil {
 		return fmt.Errorf("table %s not present in ABI for contract %s at block: %d", op.TableName, op.Code, blockNum)
@@ -230,14 +429,16 @@ func (s *StreamedAbiCodec) UpdateABI(blockNum uint32, step pbbstream.ForkStep, t
 	if err != nil {
 		return fmt.Errorf("fail to decode abi error: %w", err)
 	}
-	s.resetCodecs()
-
-	s.doUpdateABI(ABI{
+	newAbi := ABI{
 		ABI:          abi,
 		AbiBlockNum:  blockNum,
 		Account:      actionTrace.GetData("account").String(),
-		Irreversible: (step == pbbstream.ForkStep_STEP_UNDO),
-	}, blockNum, step)
+		Irreversible: (step == pbbstream.ForkStep_STEP_IRREVERSIBLE), // otherwise flagged by MarkIrreversible
+	}
+	s.doUpdateABI(newAbi, blockNum, step)
+	if recorder, ok := s.bootstrapper.(abiRecorder); ok && step != pbbstream.ForkStep_STEP_UNKNOWN {
+		recorder.recordABI(&newAbi, step)
+	}
 	return err
 }
 
@@ -247,50 +448,15 @@ func (s *StreamedAbiCodec) doUpdateABI(abi ABI, blockNum uint32, step pbbstream.
 		return
 	}
 	if step == pbbstream.ForkStep_STEP_UNDO {
-		if latestAbi, found := s.latestABIs[abi.Account]; found {
-			if blockNum > latestAbi.AbiBlockNum { // must have been replaced by a irreversible compaction
-				zlog.Info("undo skipped as undo block > latest abi block", zap.Uint32("undo_block_num", blockNum), zap.Uint32("latest_block_num", latestAbi.AbiBlockNum))
-				return
-			}
-			zlog.Info("undo actual/latest ABI", zap.Uint32("undo_block_num", blockNum), zap.Uint32("latest_block_num", latestAbi.AbiBlockNum))
-			delete(s.latestABIs, abi.Account)
-			if abiHistory, found := s.abiHistories[abi.Account]; found {
-				if len(abiHistory) > 0 {
-					// pop from history
-					previousAbi := abiHistory[len(abiHistory)-1]
-					zlog.Info("pop previous ABI from history on undo", zap.Uint32("undo_block_num", blockNum), zap.Uint32("previous_block_num", previousAbi.AbiBlockNum))
-					s.latestABIs[abi.Account] = previousAbi
-					s.abiHistories[abi.Account] = abiHistory[:len(abiHistory)-1]
-					if len(s.abiHistories[abi.Account]) == 0 { // fix a testing compare issue
-						delete(s.abiHistories, abi.Account)
-					}
-				}
-			} else {
-				zlog.Info("nothing pop from history (empty) on undo", zap.Uint32("undo_block_num", blockNum))
-			}
+		if s.removeAbiVersion(abi.Account, blockNum) {
+			zlog.Info("undo ABI version", zap.String("account", abi.Account), zap.Uint32("undo_block_num", blockNum))
 		} else {
-			zlog.Warn("undo skipped no latest abi", zap.Uint32("undo_block_num", blockNum))
-			return
-		}
-	} else {
-		newAbiItem := &abi
-		abiHistory := s.abiHistories[abi.Account]
-		latestABI := s.latestABIs[abi.Account]
-		if latestABI != nil {
-			if len(abiHistory) > 0 && abiHistory[len(abiHistory)-1].AbiBlockNum == latestABI.AbiBlockNum {
-				abiHistory[len(abiHistory)-1] = latestABI
-			} else {
-				abiHistory = append(abiHistory, latestABI)
-			}
-			s.abiHistories[abi.Account] = abiHistory
+			zlog.Info("undo skipped no ABI version at undo block", zap.String("account", abi.Account), zap.Uint32("undo_block_num", blockNum))
 		}
-		s.latestABIs[abi.Account] = newAbiItem
+		return
 	}
-}
-
-func (s *StreamedAbiCodec) resetCodecs() {
-	zlog.Info("reset schema cache on reload static schema")
-	s.codecCache = s.initStaticSchemas(make(map[CodecId]Codec))
+	zlog.Info("add ABI version", zap.String("account", abi.Account), zap.Uint32("abi_block_num", abi.AbiBlockNum))
+	s.addAbiVersion(&abi)
 }
 
 func (s *StreamedAbiCodec) initStaticSchemas(cache map[CodecId]Codec) map[CodecId]Codec {
@@ -301,6 +467,33 @@ func (s *StreamedAbiCodec) initStaticSchemas(cache map[CodecId]Codec) map[CodecI
 	return cache
 }
 
+// rawMessage returns the raw protobuf message identified by the codec id
+func (s *StreamedAbiCodec) rawMessage(codecId CodecId) (protoreflect.MessageDescriptor, bool) {
+	for _, message := range s.rawMessages {
+		if rawCodecId(message) == codecId {
+			return message, true
+		}
+	}
+	return nil, false
+}
+
+// newRawProtobufCodec registers the protobuf definition of the file of the
+// message under the subject of the message full name or looks it up in
+// read-only mode
+func (s *StreamedAbiCodec) newRawProtobufCodec(message protoreflect.MessageDescriptor) (Codec, error) {
+	subject := s.subjectName(MessageSchema{RecordSchema: RecordSchema{
+		Namespace: string(message.ParentFile().Package()),
+		Name:      string(message.Name()),
+	}})
+	protoSchema := FormatProtoSchema(protodesc.ToFileDescriptorProto(message.ParentFile()))
+	zlog.Info("create raw protobuf codec", zap.String("subject", subject), zap.String("message", string(message.FullName())))
+	schema, err := s.registeredDefinition(subject, protoSchema, srclient.Protobuf)
+	if err != nil {
+		return nil, err
+	}
+	return NewKafkaRawProtobufCodec(s.schemaRegistryURL, schema, message), nil
+}
+
 func (s *StreamedAbiCodec) registerStaticSchema(cache map[CodecId]Codec, schema MessageSchema) {
 	codec, err := s.newCodec(schema)
 	if err != nil {
@@ -333,13 +526,128 @@ func (s *StreamedAbiCodec) getCompatibility() srclient.CompatibilityLevel {
 }
 
 func (s *StreamedAbiCodec) newCodec(messageSchema MessageSchema) (Codec, error) {
-	messageSchema.Meta.Compatibility = s.getCompatibility().String()
-	subject := fmt.Sprintf("%s.%s", messageSchema.Namespace, messageSchema.Name)
+	messageSchema = s.withCompatibility(messageSchema)
+	subject := s.subjectName(messageSchema)
 	jsonSchema, err := json.Marshal(messageSchema)
 	if err != nil {
 		return nil, err
 	}
-	zlog.Debug("register schema", zap.String("subject", subject), zap.ByteString("schema", jsonSchema))
+	switch s.codec {
+	case ProtobufCodec:
+		return s.newProtobufCodec(subject, messageSchema, jsonSchema)
+	case JsonSchemaCodec:
+		return s.newJsonSchemaCodec(subject, messageSchema, jsonSchema)
+	}
+	schema, err := s.registeredSchema(subject, messageSchema, jsonSchema)
+	if err != nil {
+		return nil, err
+	}
+
+	zlog.Debug("create kafka avro codec", zap.String("subject", subject), zap.Int("ID", schema.ID()))
+	codecSchema := schema.Schema()
+	if s.schemaReferences || s.readOnly {
+		// the registered schema cannot be resolved without its references
+		// or may lack the converters when registered by another tool
+		codecSchema = string(jsonSchema)
+	}
+	ac, err := goavro.NewCodecWithConverters(codecSchema, schemaTypeConverters)
+	if err != nil {
+		return nil, fmt.Errorf("goavro.NewCodecWithConverters error: %w, with schema %s", err, string(jsonSchema))
+	}
+	if s.codec == AvroJsonCodec {
+		return NewKafkaAvroJsonCodec(s.schemaRegistryURL, schema, ac), nil
+	}
+	codec := NewKafkaAvroCodec(s.schemaRegistryURL, schema, ac)
+	return codec, nil
+}
+
+// withCompatibility sets the subject compatibility in the schema meta
+func (s *StreamedAbiCodec) withCompatibility(messageSchema MessageSchema) MessageSchema {
+	messageSchema.Meta.Compatibility = s.getCompatibility().String()
+	return messageSchema
+}
+
+// registeredSchema registers the schema under its subject or looks it up in
+// read-only mode
+func (s *StreamedAbiCodec) registeredSchema(subject string, messageSchema MessageSchema, jsonSchema []byte) (*srclient.Schema, error) {
+	registeredSchema, references := jsonSchema, []srclient.Reference(nil)
+	if s.schemaReferences {
+		var err error
+		registeredSchema, references, err = s.referenceSharedRecords(messageSchema)
+		if err != nil {
+			return nil, fmt.Errorf("cannot register the shared records of subject: '%s', error: %w", subject, err)
+		}
+	}
+	if s.readOnly {
+		zlog.Debug("lookup schema", zap.String("subject", subject), zap.ByteString("schema", registeredSchema))
+		return s.lookupSchema(subject, string(registeredSchema))
+	}
+	zlog.Debug("register schema", zap.String("subject", subject), zap.ByteString("schema", registeredSchema))
+	return s.registerSchema(subject, string(registeredSchema), srclient.Avro, references)
+}
+
+// newProtobufCodec registers the protobuf definition of the schema, the avro
+// schema is only used to normalize the values.
+func (s *StreamedAbiCodec) newProtobufCodec(subject string, messageSchema MessageSchema, jsonSchema []byte) (Codec, error) {
+	file, err := GenerateProtoSchema(messageSchema)
+	if err != nil {
+		return nil, err
+	}
+	protoSchema := FormatProtoSchema(file)
+	zlog.Debug("register protobuf schema", zap.String("subject", subject), zap.String("schema", protoSchema))
+	schema, err := s.registeredDefinition(subject, protoSchema, srclient.Protobuf)
+	if err != nil {
+		return nil, err
+	}
+	descriptor, err := protodesc.NewFile(file, protoregistry.GlobalFiles)
+	if err != nil {
+		return nil, fmt.Errorf("protodesc.NewFile error: %w, with schema %s", err, protoSchema)
+	}
+	ac, err := goavro.NewCodecWithConverters(string(jsonSchema), schemaTypeConverters)
+	if err != nil {
+		return nil, fmt.Errorf("goavro.NewCodecWithConverters error: %w, with schema %s", err, string(jsonSchema))
+	}
+	zlog.Debug("create kafka protobuf codec", zap.String("subject", subject), zap.Int("ID", schema.ID()))
+	return NewKafkaProtobufCodec(s.schemaRegistryURL, schema, descriptor.Messages().Get(0), messageSchema.RecordSchema, ac)
+}
+
+// newJsonSchemaCodec registers the JSON Schema of the schema, the avro schema
+// is only used to normalize the values.
+func (s *StreamedAbiCodec) newJsonSchemaCodec(subject string, messageSchema MessageSchema, jsonSchema []byte) (Codec, error) {
+	definition, err := GenerateJSONSchema(messageSchema)
+	if err != nil {
+		return nil, err
+	}
+	definitionBytes, err := json.Marshal(definition)
+	if err != nil {
+		return nil, err
+	}
+	zlog.Debug("register json schema", zap.String("subject", subject), zap.ByteString("schema", definitionBytes))
+	schema, err := s.registeredDefinition(subject, string(definitionBytes), srclient.Json)
+	if err != nil {
+		return nil, err
+	}
+	ac, err := goavro.NewCodecWithConverters(string(jsonSchema), schemaTypeConverters)
+	if err != nil {
+		return nil, fmt.Errorf("goavro.NewCodecWithConverters error: %w, with schema %s", err, string(jsonSchema))
+	}
+	zlog.Debug("create kafka json schema codec", zap.String("subject", subject), zap.Int("ID", schema.ID()))
+	return NewKafkaJsonSchemaCodec(s.schemaRegistryURL, schema, messageSchema.RecordSchema, ac)
+}
+
+// registeredDefinition registers the protobuf or JSON Schema definition under
+// its subject or looks it up in read-only mode
+func (s *StreamedAbiCodec) registeredDefinition(subject string, definition string, schemaType srclient.SchemaType) (*srclient.Schema, error) {
+	if s.readOnly {
+		zlog.Debug("lookup definition", zap.String("subject", subject), zap.Stringer("type", schemaType))
+		return s.lookupDefinition(subject, definition)
+	}
+	return s.registerSchema(subject, definition, schemaType, nil)
+}
+
+// registerSchema creates the schema definition of the given type in the
+// subject and sets the subject compatibility
+func (s *StreamedAbiCodec) registerSchema(subject string, definition string, schemaType srclient.SchemaType, references []srclient.Reference) (*srclient.Schema, error) {
 	zlog.Debug("get compatibility level of subject's schema", zap.String("subject", subject))
 	actualCompatibilityLevel, err := s.schemaRegistryClient.GetCompatibilityLevel(subject, true)
 	unknownSubject := false
@@ -351,9 +659,9 @@ func (s *StreamedAbiCodec) newCodec(messageSchema MessageSchema) (Codec, error)
 			return nil, err
 		}
 	}
-	schema, err := s.schemaRegistryClient.CreateSchema(subject, string(jsonSchema), srclient.Avro)
+	schema, err := s.schemaRegistryClient.CreateSchema(subject, definition, schemaType, references...)
 	if err != nil {
-		return nil, fmt.Errorf("CreateSchema on subject: '%s', schema:\n%s error: %w", subject, string(jsonSchema), err)
+		return nil, fmt.Errorf("CreateSchema on subject: '%s', schema:\n%s error: %w", subject, definition, err)
 	}
 	if unknownSubject {
 		err = s.setSubjectCompatibilityToForward(subject)
@@ -361,14 +669,117 @@ func (s *StreamedAbiCodec) newCodec(messageSchema MessageSchema) (Codec, error)
 			return nil, err
 		}
 	}
+	return schema, nil
+}
 
-	zlog.Debug("create kafka avro codec", zap.String("subject", subject), zap.Int("ID", schema.ID()))
-	ac, err := goavro.NewCodecWithConverters(schema.Schema(), schemaTypeConverters)
+// lookupSchema returns the version registered under the subject whose
+// fingerprint, computed on the normalized form, matches the one of the given
+// schema. Unlike the parsing canonical form, the normalized form keeps the
+// logical types, a timestamp-millis does not match a timestamp-micros.
+func (s *StreamedAbiCodec) lookupSchema(subject string, jsonSchema string) (*srclient.Schema, error) {
+	_, fingerprint, err := goavro.NormalizedSchemaForm(jsonSchema)
 	if err != nil {
-		return nil, fmt.Errorf("goavro.NewCodecWithConverters error: %w, with schema %s", err, string(jsonSchema))
+		return nil, fmt.Errorf("cannot fingerprint schema of subject: '%s', error: %w", subject, err)
 	}
-	codec := NewKafkaAvroCodec(s.schemaRegistryURL, schema, ac)
-	return codec, nil
+	versions, err := s.schemaRegistryClient.GetSchemaVersions(subject)
+	if err != nil || len(versions) == 0 {
+		return nil, fmt.Errorf("read-only schema registry: no schema registered under subject: '%s', it must be registered before, error: %v", subject, err)
+	}
+	var latest *srclient.Schema
+	for i := len(versions) - 1; i >= 0; i-- {
+		schema, err := s.schemaRegistryClient.GetSchemaByVersion(subject, versions[i])
+		if err != nil {
+			return nil, fmt.Errorf("GetSchemaByVersion on subject: '%s', version: %d, error: %w", subject, versions[i], err)
+		}
+		if latest == nil {
+			latest = schema
+		}
+		_, registered, err := goavro.NormalizedSchemaForm(schema.Schema())
+		if err != nil {
+			zlog.Warn("cannot fingerprint registered schema", zap.String("subject", subject), zap.Int("version", versions[i]), zap.Error(err))
+			continue
+		}
+		if registered == fingerprint {
+			zlog.Debug("registered schema found", zap.String("subject", subject), zap.Int("version", versions[i]), zap.Int("ID", schema.ID()))
+			return schema, nil
+		}
+	}
+	return nil, fmt.Errorf("read-only schema registry: no version registered under subject: '%s' matches the generated schema, diff (registered != generated) with the latest version %d:\n%s",
+		subject, latest.Version(), strings.Join(schemaDiff(latest.Schema(), jsonSchema), "\n"))
+}
+
+// lookupDefinition returns the latest version registered under the subject
+// with the exact same definition, it is used for the protobuf and JSON Schema
+// definitions which cannot be fingerprinted like the avro schemas.
+func (s *StreamedAbiCodec) lookupDefinition(subject string, definition string) (*srclient.Schema, error) {
+	versions, err := s.schemaRegistryClient.GetSchemaVersions(subject)
+	if err != nil || len(versions) == 0 {
+		return nil, fmt.Errorf("read-only schema registry: no schema registered under subject: '%s', it must be registered before, error: %v", subject, err)
+	}
+	for i := len(versions) - 1; i >= 0; i-- {
+		schema, err := s.schemaRegistryClient.GetSchemaByVersion(subject, versions[i])
+		if err != nil {
+			return nil, fmt.Errorf("GetSchemaByVersion on subject: '%s', version: %d, error: %w", subject, versions[i], err)
+		}
+		if schema.Schema() == definition {
+			zlog.Debug("registered definition found", zap.String("subject", subject), zap.Int("version", versions[i]), zap.Int("ID", schema.ID()))
+			return schema, nil
+		}
+	}
+	return nil, fmt.Errorf("read-only schema registry: no version registered under subject: '%s' matches the generated definition", subject)
+}
+
+// normalizedRecord is the normalized form of a record schema
+type normalizedRecord struct {
+	Name   string            `json:"name"`
+	Fields []normalizedField `json:"fields"`
+}
+
+type normalizedField struct {
+	Name string          `json:"name"`
+	Type json.RawMessage `json:"type"`
+}
+
+// schemaDiff returns the differences between the record name and fields of
+// the normalized forms of two schemas.
+func schemaDiff(registered string, generated string) []string {
+	var records [2]normalizedRecord
+	for i, schema := range []string{registered, generated} {
+		normalized, _, err := goavro.NormalizedSchemaForm(schema)
+		if err != nil {
+			return []string{err.Error()}
+		}
+		if err := json.Unmarshal([]byte(normalized), &records[i]); err != nil {
+			return []string{err.Error()}
+		}
+	}
+	var diff []string
+	if records[0].Name != records[1].Name {
+		diff = append(diff, fmt.Sprintf("name: %s != %s", records[0].Name, records[1].Name))
+	}
+	generatedFields := make(map[string]normalizedField, len(records[1].Fields))
+	for _, field := range records[1].Fields {
+		generatedFields[field.Name] = field
+	}
+	registeredFields := make(map[string]normalizedField, len(records[0].Fields))
+	for i, field := range records[0].Fields {
+		registeredFields[field.Name] = field
+		generatedField, found := generatedFields[field.Name]
+		switch {
+		case !found:
+			diff = append(diff, fmt.Sprintf("field: %s: %s != <missing>", field.Name, field.Type))
+		case string(field.Type) != string(generatedField.Type):
+			diff = append(diff, fmt.Sprintf("field: %s: %s != %s", field.Name, field.Type, generatedField.Type))
+		case i >= len(records[1].Fields) || records[1].Fields[i].Name != field.Name:
+			diff = append(diff, fmt.Sprintf("field: %s: position %d moved", field.Name, i))
+		}
+	}
+	for _, field := range records[1].Fields {
+		if _, found := registeredFields[field.Name]; !found {
+			diff = append(diff, fmt.Sprintf("field: %s: <missing> != %s", field.Name, field.Type))
+		}
+	}
+	return diff
 }
 
 func decodeABIAtBlock(trxID string, actionTrace *pbcodec.ActionTrace) (*eos.ABI, error) {
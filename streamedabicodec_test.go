@@ -2,8 +2,10 @@ package dkafka
 
 import (
 	"context"
+	"encoding/json"
 	"fmt"
 	"reflect"
+	"strings"
 	"testing"
 
 	pbabicodec "github.com/dfuse-io/dfuse-eosio/pb/dfuse/eosio/abicodec/v1"
@@ -44,10 +46,10 @@ func TestDfuseAbiRepository_GetAbi(t *testing.T) {
 		{
 			name: "overrides",
 			sut: &DfuseAbiRepository{
-				overrides: map[string]*ABI{"test": {
+				overrides: map[string][]*ABI{"test": {{
 					ABI:         &eos.ABI{Version: "1.2.3"},
 					AbiBlockNum: 42,
-				}},
+				}}},
 				abiCodecCli: nil,
 				context:     nil,
 			},
@@ -166,7 +168,7 @@ func TestDfuseAbiRepository_IsNOOP(t *testing.T) {
 		{
 			name: "noop",
 			sut: &DfuseAbiRepository{
-				overrides: make(map[string]*ABI),
+				overrides: make(map[string][]*ABI),
 			},
 			want: false,
 		},
@@ -182,6 +184,15 @@ func TestDfuseAbiRepository_IsNOOP(t *testing.T) {
 }
 
 func TestStreamedABICodec_doUpdateABI(t *testing.T) {
+	abi := func(version string, abiBlockNum uint32) *ABI {
+		return &ABI{
+			ABI:          &eos.ABI{Version: version},
+			AbiBlockNum:  abiBlockNum,
+			Account:      "eosio",
+			Irreversible: true,
+		}
+	}
+	dummyCodec := NewJSONCodec()
 	type args struct {
 		abi      ABI
 		blockNum uint32
@@ -196,433 +207,196 @@ func TestStreamedABICodec_doUpdateABI(t *testing.T) {
 		{
 			name: "empty-irreversible",
 			sut: &StreamedAbiCodec{
-				latestABIs:   make(map[string]*ABI),
-				abiHistories: make(map[string][]*ABI),
-				codecCache:   make(map[CodecId]Codec),
+				abiVersions: make(map[string][]*ABI),
+				codecCache:  make(map[AbiCodecId]Codec),
 			},
 			args: args{
-				abi: ABI{
-					ABI:          &eos.ABI{Version: "123"},
-					AbiBlockNum:  42,
-					Account:      "eosio",
-					Irreversible: true,
-				},
+				abi:      *abi("123", 42),
 				blockNum: 42,
 				step:     pbbstream.ForkStep_STEP_IRREVERSIBLE,
 			},
 			want: &StreamedAbiCodec{
-				latestABIs: map[string]*ABI{"eosio": {
-					ABI:          &eos.ABI{Version: "123"},
-					AbiBlockNum:  42,
-					Account:      "eosio",
-					Irreversible: true,
-				},
-				},
-				abiHistories: make(map[string][]*ABI),
-				codecCache:   make(map[CodecId]Codec),
+				abiVersions: map[string][]*ABI{"eosio": {abi("123", 42)}},
+				codecCache:  make(map[AbiCodecId]Codec),
 			},
 		},
 		{
 			name: "empty-new",
 			sut: &StreamedAbiCodec{
-				latestABIs:   make(map[string]*ABI),
-				abiHistories: make(map[string][]*ABI),
-				codecCache:   make(map[CodecId]Codec),
+				abiVersions: make(map[string][]*ABI),
+				codecCache:  make(map[AbiCodecId]Codec),
 			},
 			args: args{
-				abi: ABI{
-					ABI:          &eos.ABI{Version: "123"},
-					AbiBlockNum:  42,
-					Account:      "eosio",
-					Irreversible: false,
-				},
+				abi:      *abi("123", 42),
 				blockNum: 42,
 				step:     pbbstream.ForkStep_STEP_NEW,
 			},
 			want: &StreamedAbiCodec{
-				latestABIs: map[string]*ABI{"eosio": {
-					ABI:          &eos.ABI{Version: "123"},
-					AbiBlockNum:  42,
-					Account:      "eosio",
-					Irreversible: false,
-				},
-				},
-				abiHistories: make(map[string][]*ABI),
-				codecCache:   make(map[CodecId]Codec),
+				abiVersions: map[string][]*ABI{"eosio": {abi("123", 42)}},
+				codecCache:  make(map[AbiCodecId]Codec),
 			},
 		},
 		{
-			name: "not-empty-irreversible",
+			name: "same-version-irreversible",
 			sut: &StreamedAbiCodec{
-				latestABIs: map[string]*ABI{"eosio": {
-					ABI:          &eos.ABI{Version: "456"},
-					AbiBlockNum:  42,
-					Account:      "eosio",
-					Irreversible: false,
-				},
+				abiVersions: map[string][]*ABI{"eosio": {abi("123", 1), abi("456", 42)}},
+				codecCache: map[AbiCodecId]Codec{
+					{CodecId{"eosio", "table"}, 42}: dummyCodec,
 				},
-				abiHistories: map[string][]*ABI{"eosio": {{ABI: &eos.ABI{Version: "123"},
-					AbiBlockNum:  1,
-					Irreversible: true,
-					Account:      "eosio",
-				}}},
-				codecCache: make(map[CodecId]Codec),
 			},
 			args: args{
-				abi: ABI{
-					ABI:          &eos.ABI{Version: "456"},
-					AbiBlockNum:  42,
-					Account:      "eosio",
-					Irreversible: true,
-				},
+				abi:      *abi("456", 42),
 				blockNum: 42,
 				step:     pbbstream.ForkStep_STEP_IRREVERSIBLE,
 			},
 			want: &StreamedAbiCodec{
-				latestABIs: map[string]*ABI{"eosio": {
-					ABI:          &eos.ABI{Version: "456"},
-					AbiBlockNum:  42,
-					Irreversible: true,
-					Account:      "eosio",
-				},
+				abiVersions: map[string][]*ABI{"eosio": {abi("123", 1), abi("456", 42)}},
+				codecCache: map[AbiCodecId]Codec{
+					{CodecId{"eosio", "table"}, 42}: dummyCodec,
 				},
-				abiHistories: map[string][]*ABI{"eosio": {{
-					ABI:          &eos.ABI{Version: "123"},
-					AbiBlockNum:  1,
-					Irreversible: true,
-					Account:      "eosio",
-				},
-					{
-						ABI:          &eos.ABI{Version: "456"},
-						AbiBlockNum:  42,
-						Irreversible: false,
-						Account:      "eosio",
-					},
-				},
-				},
-				codecCache: make(map[CodecId]Codec),
 			},
 		},
 		{
-			name: "irreversible-compaction",
+			name: "new-version-keep-codecs",
 			sut: &StreamedAbiCodec{
-				latestABIs: map[string]*ABI{"eosio": {
-					ABI:          &eos.ABI{Version: "456"},
-					AbiBlockNum:  42,
-					Irreversible: true,
-					Account:      "eosio",
-				}},
-				abiHistories: map[string][]*ABI{"eosio": {{
-					ABI:          &eos.ABI{Version: "123"},
-					AbiBlockNum:  1,
-					Irreversible: true,
-					Account:      "eosio",
-				},
-					{
-						ABI:          &eos.ABI{Version: "456"},
-						AbiBlockNum:  42,
-						Irreversible: false,
-						Account:      "eosio",
-					},
-				},
+				abiVersions: map[string][]*ABI{"eosio": {abi("123", 1), abi("456", 42)}},
+				codecCache: map[AbiCodecId]Codec{
+					{CodecId{"eosio", "table"}, 42}: dummyCodec,
 				},
 			},
 			args: args{
-				abi: ABI{
-					ABI:          &eos.ABI{Version: "789"},
-					AbiBlockNum:  64,
-					Account:      "eosio",
-					Irreversible: false,
-				},
+				abi:      *abi("789", 64),
 				blockNum: 64,
 				step:     pbbstream.ForkStep_STEP_NEW,
 			},
 			want: &StreamedAbiCodec{
-				latestABIs: map[string]*ABI{"eosio": {
-					ABI:          &eos.ABI{Version: "789"},
-					AbiBlockNum:  64,
-					Irreversible: false,
-					Account:      "eosio",
-				},
-				},
-				abiHistories: map[string][]*ABI{"eosio": {{
-					ABI:          &eos.ABI{Version: "123"},
-					AbiBlockNum:  1,
-					Irreversible: true,
-					Account:      "eosio",
-				},
-					{
-						ABI:          &eos.ABI{Version: "456"},
-						AbiBlockNum:  42,
-						Irreversible: true,
-						Account:      "eosio",
-					},
-				},
+				abiVersions: map[string][]*ABI{"eosio": {abi("123", 1), abi("456", 42), abi("789", 64)}},
+				codecCache: map[AbiCodecId]Codec{
+					{CodecId{"eosio", "table"}, 42}: dummyCodec,
 				},
 			},
 		},
 		{
-			name: "irreversible-only",
+			name: "version-inserted-in-order",
 			sut: &StreamedAbiCodec{
-				latestABIs: map[string]*ABI{"eosio": {
-					ABI:          &eos.ABI{Version: "456"},
-					AbiBlockNum:  42,
-					Irreversible: true,
-					Account:      "eosio",
-				},
-				},
-				abiHistories: map[string][]*ABI{"eosio": {{
-					ABI:          &eos.ABI{Version: "123"},
-					AbiBlockNum:  1,
-					Irreversible: true,
-					Account:      "eosio",
-				},
-				},
-				},
+				abiVersions: map[string][]*ABI{"eosio": {abi("123", 1), abi("789", 64)}},
+				codecCache:  make(map[AbiCodecId]Codec),
 			},
 			args: args{
-				// abi:      &eos.ABI{Version: "789"},
-				abi: ABI{
-					ABI:          &eos.ABI{Version: "789"},
-					AbiBlockNum:  64,
-					Account:      "eosio",
-					Irreversible: true,
-				},
-				blockNum: 64,
+				abi:      *abi("456", 42),
+				blockNum: 42,
 				step:     pbbstream.ForkStep_STEP_IRREVERSIBLE,
 			},
 			want: &StreamedAbiCodec{
-				latestABIs: map[string]*ABI{"eosio": {
-					ABI:          &eos.ABI{Version: "789"},
-					AbiBlockNum:  64,
-					Irreversible: true,
-					Account:      "eosio",
-				},
-				},
-				abiHistories: map[string][]*ABI{"eosio": {
-					{
-						ABI:          &eos.ABI{Version: "123"},
-						AbiBlockNum:  1,
-						Irreversible: true,
-						Account:      "eosio",
-					},
-					{
-						ABI:          &eos.ABI{Version: "456"},
-						AbiBlockNum:  42,
-						Irreversible: true,
-						Account:      "eosio",
-					},
-				},
-				},
+				abiVersions: map[string][]*ABI{"eosio": {abi("123", 1), abi("456", 42), abi("789", 64)}},
+				codecCache:  make(map[AbiCodecId]Codec),
 			},
 		},
 		{
 			name: "undo",
 			sut: &StreamedAbiCodec{
-				latestABIs: map[string]*ABI{"eosio": {
-					ABI:          &eos.ABI{Version: "456"},
-					AbiBlockNum:  42,
-					Irreversible: false,
-					Account:      "eosio",
-				},
-				},
-				abiHistories: map[string][]*ABI{"eosio": {
-					{
-						ABI:          &eos.ABI{Version: "123"},
-						AbiBlockNum:  1,
-						Irreversible: true,
-						Account:      "eosio",
-					},
-				},
+				abiVersions: map[string][]*ABI{"eosio": {abi("123", 1), abi("456", 42)}},
+				codecCache: map[AbiCodecId]Codec{
+					{CodecId{"eosio", "table"}, 1}:  dummyCodec,
+					{CodecId{"eosio", "table"}, 42}: dummyCodec,
 				},
 			},
 			args: args{
-				abi: ABI{
-					ABI:          &eos.ABI{Version: "456"},
-					AbiBlockNum:  42,
-					Account:      "eosio",
-					Irreversible: false,
-				},
-
+				abi:      *abi("456", 42),
 				blockNum: 42,
 				step:     pbbstream.ForkStep_STEP_UNDO,
 			},
 			want: &StreamedAbiCodec{
-				latestABIs: map[string]*ABI{"eosio": {
-					ABI:          &eos.ABI{Version: "123"},
-					AbiBlockNum:  1,
-					Irreversible: true,
-					Account:      "eosio",
-				},
+				abiVersions: map[string][]*ABI{"eosio": {abi("123", 1)}},
+				codecCache: map[AbiCodecId]Codec{
+					{CodecId{"eosio", "table"}, 1}: dummyCodec,
 				},
-				abiHistories: make(map[string][]*ABI),
 			},
 		},
 		{
 			name: "unknown",
 			sut: &StreamedAbiCodec{
-				latestABIs: map[string]*ABI{"eosio": {
-					ABI:          &eos.ABI{Version: "456"},
-					AbiBlockNum:  42,
-					Irreversible: false,
-					Account:      "eosio",
-				},
-				},
-				abiHistories: map[string][]*ABI{"eosio": {
-					{
-						ABI:          &eos.ABI{Version: "123"},
-						AbiBlockNum:  1,
-						Irreversible: true,
-						Account:      "eosio",
-					},
-				},
-				},
+				abiVersions: map[string][]*ABI{"eosio": {abi("123", 1), abi("456", 42)}},
+				codecCache:  make(map[AbiCodecId]Codec),
 			},
 			args: args{
-				abi: ABI{
-					ABI:          &eos.ABI{Version: "456"},
-					AbiBlockNum:  42,
-					Account:      "eosio",
-					Irreversible: false,
-				},
+				abi:      *abi("456", 42),
 				blockNum: 42,
 				step:     pbbstream.ForkStep_STEP_UNKNOWN,
 			},
 			want: &StreamedAbiCodec{
-				latestABIs: map[string]*ABI{"eosio": {
-					ABI:          &eos.ABI{Version: "456"},
-					AbiBlockNum:  42,
-					Irreversible: false,
-					Account:      "eosio",
-				},
-				},
-				abiHistories: map[string][]*ABI{"eosio": {
-					{
-						ABI:          &eos.ABI{Version: "123"},
-						AbiBlockNum:  1,
-						Irreversible: true,
-						Account:      "eosio",
-					},
-				},
-				},
+				abiVersions: map[string][]*ABI{"eosio": {abi("123", 1), abi("456", 42)}},
+				codecCache:  make(map[AbiCodecId]Codec),
 			},
 		},
 		{
 			name: "undo-empty",
 			sut: &StreamedAbiCodec{
-				latestABIs:   make(map[string]*ABI),
-				abiHistories: make(map[string][]*ABI),
+				abiVersions: make(map[string][]*ABI),
+				codecCache:  make(map[AbiCodecId]Codec),
 			},
 			args: args{
-				// abi:      &eos.ABI{Version: "456"},
-				abi: ABI{
-					ABI:          &eos.ABI{Version: "456"},
-					AbiBlockNum:  42,
-					Account:      "eosio",
-					Irreversible: false,
-				},
+				abi:      *abi("456", 42),
 				blockNum: 42,
 				step:     pbbstream.ForkStep_STEP_UNDO,
 			},
 			want: &StreamedAbiCodec{
-				latestABIs:   make(map[string]*ABI),
-				abiHistories: make(map[string][]*ABI),
+				abiVersions: make(map[string][]*ABI),
+				codecCache:  make(map[AbiCodecId]Codec),
+			},
+		},
+		{
+			name: "undo-last-version",
+			sut: &StreamedAbiCodec{
+				abiVersions: map[string][]*ABI{"eosio": {abi("456", 42)}},
+				codecCache:  make(map[AbiCodecId]Codec),
+			},
+			args: args{
+				abi:      *abi("456", 42),
+				blockNum: 42,
+				step:     pbbstream.ForkStep_STEP_UNDO,
+			},
+			want: &StreamedAbiCodec{
+				abiVersions: make(map[string][]*ABI),
+				codecCache:  make(map[AbiCodecId]Codec),
 			},
 		},
 		{
 			name: "undo-gt-latest",
 			sut: &StreamedAbiCodec{
-				latestABIs: map[string]*ABI{"eosio": {
-					ABI:          &eos.ABI{Version: "123"},
-					AbiBlockNum:  24,
-					Irreversible: true,
-					Account:      "eosio",
-				},
-				},
-				abiHistories: make(map[string][]*ABI),
+				abiVersions: map[string][]*ABI{"eosio": {abi("123", 24)}},
+				codecCache:  make(map[AbiCodecId]Codec),
 			},
 			args: args{
-				abi: ABI{
-					ABI:          &eos.ABI{Version: "456"},
-					AbiBlockNum:  42,
-					Account:      "eosio",
-					Irreversible: false,
-				},
+				abi:      *abi("456", 42),
 				blockNum: 42,
 				step:     pbbstream.ForkStep_STEP_UNDO,
 			},
 			want: &StreamedAbiCodec{
-				latestABIs: map[string]*ABI{"eosio": {
-					ABI:          &eos.ABI{Version: "123"},
-					AbiBlockNum:  24,
-					Irreversible: true,
-					Account:      "eosio",
-				},
-				},
-				abiHistories: make(map[string][]*ABI),
+				abiVersions: map[string][]*ABI{"eosio": {abi("123", 24)}},
+				codecCache:  make(map[AbiCodecId]Codec),
 			},
 		},
 		{
 			name: "undo-long-history",
 			sut: &StreamedAbiCodec{
-				latestABIs: map[string]*ABI{"eosio": {
-					ABI:          &eos.ABI{Version: "789"},
-					AbiBlockNum:  42,
-					Irreversible: false,
-					Account:      "eosio",
-				},
-				},
-				abiHistories: map[string][]*ABI{"eosio": {
-					{
-						ABI:          &eos.ABI{Version: "123"},
-						AbiBlockNum:  1,
-						Irreversible: true,
-						Account:      "eosio",
-					},
-					{
-						ABI:          &eos.ABI{Version: "456"},
-						AbiBlockNum:  24,
-						Irreversible: true,
-						Account:      "eosio",
-					},
-				},
-				},
+				abiVersions: map[string][]*ABI{"eosio": {abi("123", 1), abi("456", 24), abi("789", 42)}},
+				codecCache:  make(map[AbiCodecId]Codec),
 			},
 			args: args{
-				// abi:      &eos.ABI{Version: "789"},
-				abi: ABI{
-					ABI:          &eos.ABI{Version: "789"},
-					AbiBlockNum:  42,
-					Account:      "eosio",
-					Irreversible: false,
-				},
+				abi:      *abi("789", 42),
 				blockNum: 42,
 				step:     pbbstream.ForkStep_STEP_UNDO,
 			},
 			want: &StreamedAbiCodec{
-				latestABIs: map[string]*ABI{"eosio": {
-					ABI:          &eos.ABI{Version: "456"},
-					AbiBlockNum:  24,
-					Irreversible: true,
-					Account:      "eosio",
-				},
-				},
-				abiHistories: map[string][]*ABI{"eosio": {
-					{
-						ABI:          &eos.ABI{Version: "123"},
-						AbiBlockNum:  1,
-						Irreversible: true,
-						Account:      "eosio",
-					},
-				},
-				},
+				abiVersions: map[string][]*ABI{"eosio": {abi("123", 1), abi("456", 24)}},
+				codecCache:  make(map[AbiCodecId]Codec),
 			},
 		},
 	}
 	for _, tt := range tests {
 		t.Run(tt.name, func(t *testing.T) {
 			tt.sut.doUpdateABI(tt.args.abi, tt.args.blockNum, tt.args.step)
-			// assertEqual(t, tt.sut, tt.want)
 			deep.CompareUnexportedFields = true
 			if diff := deep.Equal(tt.sut, tt.want); diff != nil {
 				t.Error(diff)
@@ -631,6 +405,264 @@ func TestStreamedABICodec_doUpdateABI(t *testing.T) {
 	}
 }
 
+func TestStreamedAbiCodec_MarkIrreversible(t *testing.T) {
+	abi := func(version string, abiBlockNum uint32, irreversible bool) *ABI {
+		return &ABI{
+			ABI:          &eos.ABI{Version: version},
+			AbiBlockNum:  abiBlockNum,
+			Account:      "eosio",
+			Irreversible: irreversible,
+		}
+	}
+	dummyCodec := NewJSONCodec()
+	tests := []struct {
+		name         string
+		libNum       uint32
+		wantVersions map[string][]*ABI
+		wantCodecs   map[AbiCodecId]Codec
+	}{
+		{
+			name:   "lib-before-first-version",
+			libNum: 5,
+			wantVersions: map[string][]*ABI{
+				"eosio": {abi("123", 10, false), abi("456", 20, false), abi("789", 30, false)},
+			},
+			wantCodecs: map[AbiCodecId]Codec{
+				{CodecId{"eosio", "table"}, 10}: dummyCodec,
+				{CodecId{"eosio", "table"}, 20}: dummyCodec,
+			},
+		},
+		{
+			name:   "lib-on-first-version",
+			libNum: 10,
+			wantVersions: map[string][]*ABI{
+				"eosio": {abi("123", 10, true), abi("456", 20, false), abi("789", 30, false)},
+			},
+			wantCodecs: map[AbiCodecId]Codec{
+				{CodecId{"eosio", "table"}, 10}: dummyCodec,
+				{CodecId{"eosio", "table"}, 20}: dummyCodec,
+			},
+		},
+		{
+			name:   "lib-between-versions",
+			libNum: 25,
+			wantVersions: map[string][]*ABI{
+				"eosio": {abi("456", 20, true), abi("789", 30, false)},
+			},
+			wantCodecs: map[AbiCodecId]Codec{
+				{CodecId{"eosio", "table"}, 20}: dummyCodec,
+			},
+		},
+		{
+			name:   "lib-after-last-version",
+			libNum: 100,
+			wantVersions: map[string][]*ABI{
+				"eosio": {abi("789", 30, true)},
+			},
+			wantCodecs: map[AbiCodecId]Codec{},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			s := &StreamedAbiCodec{
+				abiVersions: map[string][]*ABI{"eosio": {abi("123", 10, false), abi("456", 20, false), abi("789", 30, false)}},
+				codecCache: map[AbiCodecId]Codec{
+					{CodecId{"eosio", "table"}, 10}: dummyCodec,
+					{CodecId{"eosio", "table"}, 20}: dummyCodec,
+				},
+			}
+			s.MarkIrreversible(tt.libNum)
+			if diff := deep.Equal(s.abiVersions, tt.wantVersions); diff != nil {
+				t.Errorf("StreamedAbiCodec.abiVersions diff: %v", diff)
+			}
+			if diff := deep.Equal(s.codecCache, tt.wantCodecs); diff != nil {
+				t.Errorf("StreamedAbiCodec.codecCache diff: %v", diff)
+			}
+		})
+	}
+}
+
+// TestStreamedAbiCodec_deepReorg replays a fork undoing several setabi
+// actions while the LIB moves forward.
+func TestStreamedAbiCodec_deepReorg(t *testing.T) {
+	abi := func(version string, abiBlockNum uint32) ABI {
+		return ABI{
+			ABI:         &eos.ABI{Version: version},
+			AbiBlockNum: abiBlockNum,
+			Account:     "eosio",
+		}
+	}
+	s := &StreamedAbiCodec{
+		bootstrapper: &AbiRepositoryStub{err: fmt.Errorf("must not be called")},
+		abiVersions:  make(map[string][]*ABI),
+		codecCache:   make(map[AbiCodecId]Codec),
+	}
+	versionAt := func(blockNum uint32) string {
+		got, err := s.getAbi("eosio", blockNum)
+		if err != nil {
+			t.Fatalf("StreamedAbiCodec.getAbi() at block: %d, error: %v", blockNum, err)
+		}
+		return got.Version
+	}
+	s.doUpdateABI(abi("a", 10), 10, pbbstream.ForkStep_STEP_NEW)
+	s.MarkIrreversible(8)
+	s.doUpdateABI(abi("b", 20), 20, pbbstream.ForkStep_STEP_NEW)
+	s.doUpdateABI(abi("c", 30), 30, pbbstream.ForkStep_STEP_NEW)
+	s.MarkIrreversible(12)
+	if depth := len(s.abiVersions["eosio"]); depth != 3 {
+		t.Fatalf("history depth = %d, want 3", depth)
+	}
+	// the fork goes back to block 15, undoing the setabi of blocks 30 and 20
+	s.doUpdateABI(abi("c", 30), 30, pbbstream.ForkStep_STEP_UNDO)
+	s.doUpdateABI(abi("b", 20), 20, pbbstream.ForkStep_STEP_UNDO)
+	if v := versionAt(35); v != "a" {
+		t.Errorf("version at block 35 after undo = %s, want a", v)
+	}
+	s.doUpdateABI(abi("d", 21), 21, pbbstream.ForkStep_STEP_NEW)
+	if v := versionAt(25); v != "d" {
+		t.Errorf("version at block 25 = %s, want d", v)
+	}
+	if v := versionAt(20); v != "a" {
+		t.Errorf("version at block 20 = %s, want a", v)
+	}
+	s.MarkIrreversible(22)
+	versions := s.abiVersions["eosio"]
+	if len(versions) != 1 || versions[0].Version != "d" || !versions[0].Irreversible {
+		t.Errorf("history after LIB 22 = %v, want only irreversible version d", versions)
+	}
+	if v := versionAt(23); v != "d" {
+		t.Errorf("version at block 23 = %s, want d", v)
+	}
+}
+
+// TestStreamedAbiCodec_MarkIrreversibleOverride checks that flagging an
+// override ABI version irreversible leaves the override itself untouched.
+func TestStreamedAbiCodec_MarkIrreversibleOverride(t *testing.T) {
+	override := &ABI{
+		ABI:         &eos.ABI{Version: "override"},
+		AbiBlockNum: 10,
+		Account:     "eosio",
+	}
+	s := &StreamedAbiCodec{
+		bootstrapper: &DfuseAbiRepository{overrides: map[string][]*ABI{"eosio": {override}}},
+		abiVersions:  make(map[string][]*ABI),
+		codecCache:   make(map[AbiCodecId]Codec),
+	}
+	if _, err := s.getAbi("eosio", 15); err != nil {
+		t.Fatalf("StreamedAbiCodec.getAbi() error: %v", err)
+	}
+	s.MarkIrreversible(12)
+	if override.Irreversible {
+		t.Errorf("override ABI flagged irreversible by MarkIrreversible")
+	}
+	got, err := s.getAbi("eosio", 15)
+	if err != nil {
+		t.Fatalf("StreamedAbiCodec.getAbi() after MarkIrreversible error: %v", err)
+	}
+	if got == override || got.Version != "override" || !got.Irreversible {
+		t.Errorf("StreamedAbiCodec.getAbi() = %+v, want an irreversible copy of the override", got)
+	}
+}
+
+func TestStreamedAbiCodec_getAbi(t *testing.T) {
+	abi := func(version string, abiBlockNum uint32) *ABI {
+		return &ABI{
+			ABI:         &eos.ABI{Version: version},
+			AbiBlockNum: abiBlockNum,
+			Account:     "eosio",
+		}
+	}
+	tests := []struct {
+		name         string
+		bootstrapper AbiRepository
+		blockNum     uint32
+		want         *ABI
+		wantVersions []*ABI
+		wantErr      bool
+	}{
+		{
+			name:         "before-first-version",
+			bootstrapper: &AbiRepositoryStub{abi: abi("000", 2)},
+			blockNum:     5,
+			want:         abi("000", 2),
+			wantVersions: []*ABI{abi("000", 2), abi("123", 10), abi("456", 20), abi("789", 30)},
+		},
+		{
+			name:         "first-version",
+			bootstrapper: &AbiRepositoryStub{err: fmt.Errorf("must not be called")},
+			blockNum:     10,
+			want:         abi("123", 10),
+			wantVersions: []*ABI{abi("123", 10), abi("456", 20), abi("789", 30)},
+		},
+		{
+			name:         "between-versions",
+			bootstrapper: &AbiRepositoryStub{err: fmt.Errorf("must not be called")},
+			blockNum:     25,
+			want:         abi("456", 20),
+			wantVersions: []*ABI{abi("123", 10), abi("456", 20), abi("789", 30)},
+		},
+		{
+			name:         "after-last-version",
+			bootstrapper: &AbiRepositoryStub{err: fmt.Errorf("must not be called")},
+			blockNum:     100,
+			want:         abi("789", 30),
+			wantVersions: []*ABI{abi("123", 10), abi("456", 20), abi("789", 30)},
+		},
+		{
+			name:         "bootstrap-error",
+			bootstrapper: &AbiRepositoryStub{err: fmt.Errorf("unavailable")},
+			blockNum:     5,
+			wantErr:      true,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			s := &StreamedAbiCodec{
+				bootstrapper: tt.bootstrapper,
+				abiVersions:  map[string][]*ABI{"eosio": {abi("123", 10), abi("456", 20), abi("789", 30)}},
+				codecCache:   make(map[AbiCodecId]Codec),
+			}
+			got, err := s.getAbi("eosio", tt.blockNum)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("StreamedAbiCodec.getAbi() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if tt.wantErr {
+				return
+			}
+			if diff := deep.Equal(got, tt.want); diff != nil {
+				t.Errorf("StreamedAbiCodec.getAbi() diff: %v", diff)
+			}
+			if diff := deep.Equal(s.abiVersions["eosio"], tt.wantVersions); diff != nil {
+				t.Errorf("StreamedAbiCodec.abiVersions diff: %v", diff)
+			}
+		})
+	}
+}
+
+func TestStreamedAbiCodec_getAbi_localFiles(t *testing.T) {
+	abiFiles, err := LoadABIFiles(map[string][]string{
+		"eosio.nft.ft": {"testdata/eosio.nft.ft-1.31.1.abi:10", "testdata/eosio.nft.ft-2.0.abi:100"},
+	})
+	if err != nil {
+		t.Fatalf("LoadABIFiles() error: %v", err)
+	}
+	s := &StreamedAbiCodec{
+		bootstrapper: &DfuseAbiRepository{overrides: abiFiles},
+		abiVersions:  make(map[string][]*ABI),
+		codecCache:   make(map[AbiCodecId]Codec),
+	}
+	for _, blockNum := range []uint32{150, 50, 100, 10} {
+		abi, err := s.getAbi("eosio.nft.ft", blockNum)
+		if err != nil {
+			t.Fatalf("StreamedAbiCodec.getAbi() error: %v", err)
+		}
+		want := abiVersionAt(abiFiles["eosio.nft.ft"], blockNum)
+		if abi.ABI != want.ABI || abi.AbiBlockNum != want.AbiBlockNum {
+			t.Errorf("StreamedAbiCodec.getAbi() at block: %d, AbiBlockNum = %v, want %v", blockNum, abi.AbiBlockNum, want.AbiBlockNum)
+		}
+	}
+}
+
 type AbiRepositoryStub struct {
 	noop bool
 	abi  *ABI
@@ -682,8 +714,8 @@ func TestStreamedAbiCodec_IsNOOP(t *testing.T) {
 }
 
 func TestStreamedAbiCodec_GetCodec(t *testing.T) {
-	var localABIFiles = map[string]string{
-		"eosio.nft.ft": "testdata/eosio.nft.ft.abi:1",
+	var localABIFiles = map[string][]string{
+		"eosio.nft.ft": {"testdata/eosio.nft.ft.abi:1"},
 	}
 	abiFiles, _ := LoadABIFiles(localABIFiles)
 
@@ -708,14 +740,17 @@ func TestStreamedAbiCodec_GetCodec(t *testing.T) {
 			name: "cached-codec",
 			sut: &StreamedAbiCodec{
 				bootstrapper: nil,
-				latestABIs:   make(map[string]*ABI),
-				abiHistories: make(map[string][]*ABI),
+				abiVersions: map[string][]*ABI{"eosio.nft.ft": {{
+					ABI:         &eos.ABI{Version: "123"},
+					AbiBlockNum: 1,
+					Account:     "eosio.nft.ft",
+				}}},
 				getSchema: func(string, *ABI) (MessageSchema, error) {
 					return MessageSchema{}, nil
 				},
 				schemaRegistryClient: nil,
 				account:              "test",
-				codecCache:           map[CodecId]Codec{{"eosio.nft.ft", "TestTable"}: dummyCodec},
+				codecCache:           map[AbiCodecId]Codec{{CodecId{"eosio.nft.ft", "TestTable"}, 1}: dummyCodec},
 				schemaRegistryURL:    "http://localhost:8083",
 			},
 			args: args{
@@ -732,14 +767,13 @@ func TestStreamedAbiCodec_GetCodec(t *testing.T) {
 					abi: nil,
 					err: fmt.Errorf("bootstrap-abi-error"),
 				},
-				latestABIs:   make(map[string]*ABI),
-				abiHistories: make(map[string][]*ABI),
+				abiVersions: make(map[string][]*ABI),
 				getSchema: func(string, *ABI) (MessageSchema, error) {
 					return MessageSchema{}, nil
 				},
 				schemaRegistryClient: nil,
 				account:              "test",
-				codecCache:           map[CodecId]Codec{},
+				codecCache:           map[AbiCodecId]Codec{},
 				schemaRegistryURL:    "http://localhost:8083",
 			},
 			args: args{
@@ -797,7 +831,7 @@ func TestStreamedAbiCodec_GetCodec(t *testing.T) {
 			want: &KafkaAvroCodec{
 				schemaURLTemplate: "mock://TestKafkaAvroABICodec_GetCodec/schemas/ids/%d",
 				schema: RegisteredSchema{
-					id:      2,
+					id:      3,
 					schema:  "{\"type\":\"record\",\"name\":\"FactoryATableNotification\",\"namespace\":\"test.eosio.nft.ft.tables.v0\",\"fields\":[{\"name\":\"context\",\"type\":{\"type\":\"record\",\"name\":\"NotificationContext\",\"namespace\":\"io.dkafka\",\"fields\":[{\"name\":\"block_num\",\"type\":\"long\"},{\"name\":\"block_id\",\"type\":\"string\"},{\"name\":\"status\",\"type\":\"string\"},{\"name\":\"executed\",\"type\":\"boolean\"},{\"name\":\"block_step\",\"type\":\"string\"},{\"name\":\"correlation\",\"type\":[\"null\",{\"type\":\"record\",\"name\":\"Correlation\",\"namespace\":\"io.dkafka\",\"fields\":[{\"name\":\"payer\",\"type\":\"string\"},{\"name\":\"id\",\"type\":\"string\"}]}],\"default\":null},{\"name\":\"trx_id\",\"type\":\"string\"},{\"name\":\"time\",\"type\":{\"eos.type\":\"block_timestamp_type\",\"logicalType\":\"timestamp-millis\",\"type\":\"long\"}},{\"name\":\"cursor\",\"type\":\"string\"}]}},{\"name\":\"action\",\"type\":{\"type\":\"record\",\"name\":\"ActionInfoBasic\",\"namespace\":\"io.dkafka\",\"fields\":[{\"name\":\"account\",\"type\":\"string\"},{\"name\":\"receiver\",\"type\":\"string\"},{\"name\":\"name\",\"type\":\"string\"},{\"name\":\"global_seq\",\"type\":\"long\"},{\"name\":\"authorizations\",\"type\":{\"type\":\"array\",\"items\":\"string\"}},{\"name\":\"action_ordinal\",\"type\":\"long\"},{\"name\":\"creator_action_ordinal\",\"type\":\"long\"},{\"name\":\"closest_unnotified_ancestor_action_ordinal\",\"type\":\"long\"},{\"name\":\"execution_index\",\"type\":\"long\"}]}},{\"name\":\"db_op\",\"type\":{\"type\":\"record\",\"name\":\"FactoryATableOpInfo\",\"fields\":[{\"name\":\"operation\",\"type\":[\"null\",\"int\"],\"default\":null},{\"name\":\"action_index\",\"type\":[\"null\",\"long\"],\"default\":null},{\"name\":\"index\",\"type\":\"int\"},{\"name\":\"code\",\"type\":[\"null\",\"string\"],\"default\":null},{\"name\":\"scope\",\"type\":[\"null\",\"string\"],\"default\":null},{\"name\":\"table_name\",\"type\":[\"null\",\"string\"],\"default\":null},{\"name\":\"primary_key\",\"type\":[\"null\",\"string\"],\"default\":null},{\"name\":\"old_payer\",\"type\":[\"null\",\"string\"],\"default\":null},{\"name\":\"new_payer\",\"type\":[\"null\",\"string\"],\"default\":null},{\"name\":\"old_data\",\"type\":[\"null\",\"bytes\"],\"default\":null},{\"name\":\"new_data\",\"type\":[\"null\",\"bytes\"],\"default\":null},{\"name\":\"old_json\",\"type\":[\"null\",{\"type\":\"record\",\"name\":\"FactoryATableOp\",\"fields\":[{\"name\":\"id\",\"type\":{\"eos.type\":\"uint64\",\"logicalType\":\"eos.uint64\",\"type\":\"long\"}},{\"name\":\"asset_manager\",\"type\":{\"eos.type\":\"name\",\"type\":\"string\"}},{\"name\":\"asset_creator\",\"type\":{\"eos.type\":\"name\",\"type\":\"string\"}},{\"name\":\"conversion_rate_oracle_contract\",\"type\":{\"eos.type\":\"name\",\"type\":\"string\"}},{\"name\":\"chosen_rate\",\"type\":{\"type\":\"array\",\"items\":{\"type\":\"record\",\"name\":\"Asset\",\"namespace\":\"eosio\",\"convert\":\"eosio.Asset\",\"fields\":[{\"name\":\"amount\",\"type\":{\"type\":\"bytes\",\"logicalType\":\"decimal\",\"precision\":32,\"scale\":8}},{\"name\":\"symbol\",\"type\":\"string\"},{\"name\":\"precision\",\"type\":\"int\"}]}}},{\"name\":\"minimum_resell_price\",\"type\":\"eosio.Asset\"},{\"name\":\"resale_shares\",\"type\":{\"type\":\"array\",\"items\":{\"type\":\"record\",\"name\":\"ResaleShare\",\"fields\":[{\"name\":\"receiver\",\"type\":{\"eos.type\":\"name\",\"type\":\"string\"}},{\"name\":\"basis_point\",\"type\":{\"eos.type\":\"uint16\",\"type\":\"int\"}}]}}},{\"name\":\"mintable_window_start\",\"type\":[\"null\",{\"eos.type\":\"uint32\",\"type\":\"long\"}],\"default\":null},{\"name\":\"mintable_window_end\",\"type\":[\"null\",{\"eos.type\":\"uint32\",\"type\":\"long\"}],\"default\":null},{\"name\":\"trading_window_start\",\"type\":[\"null\",{\"eos.type\":\"uint32\",\"type\":\"long\"}],\"default\":null},{\"name\":\"trading_window_end\",\"type\":[\"null\",{\"eos.type\":\"uint32\",\"type\":\"long\"}],\"default\":null},{\"name\":\"recall_window_start\",\"type\":[\"null\",{\"eos.type\":\"uint32\",\"type\":\"long\"}],\"default\":null},{\"name\":\"recall_window_end\",\"type\":[\"null\",{\"eos.type\":\"uint32\",\"type\":\"long\"}],\"default\":null},{\"name\":\"lockup_time\",\"type\":[\"null\",{\"eos.type\":\"uint32\",\"type\":\"long\"}],\"default\":null},{\"name\":\"conditionless_receivers\",\"type\":{\"type\":\"array\",\"items\":{\"eos.type\":\"name\",\"type\":\"string\"}}},{\"name\":\"stat\",\"type\":{\"eos.type\":\"uint8\",\"type\":\"int\"}},{\"name\":\"meta_uris\",\"type\":{\"type\":\"array\",\"items\":{\"eos.type\":\"string\",\"type\":\"string\"}}},{\"name\":\"meta_hash\",\"type\":{\"eos.type\":\"checksum256\",\"type\":\"bytes\"}},{\"name\":\"max_mintable_tokens\",\"type\":[\"null\",{\"eos.type\":\"uint32\",\"type\":\"long\"}],\"default\":null},{\"name\":\"minted_tokens_no\",\"type\":{\"eos.type\":\"uint32\",\"type\":\"long\"}},{\"name\":\"existing_tokens_no\",\"type\":{\"eos.type\":\"uint32\",\"type\":\"long\"}}]}],\"default\":null},{\"name\":\"new_json\",\"type\":[\"null\",\"FactoryATableOp\"],\"default\":null}]}}],\"meta\":{\"compatibility\":\"FORWARD\",\"type\":\"notification\",\"version\":\"0.1.0\",\"domain\":\"eosio.nft.ft\"}}",
 					version: 1,
 				},
@@ -829,3 +863,109 @@ func TestStreamedAbiCodec_GetCodec(t *testing.T) {
 		})
 	}
 }
+
+func TestStreamedAbiCodec_readOnly(t *testing.T) {
+	abi, err := LoadABIFile("eosio.nft.ft", "testdata/eosio.nft.ft.abi")
+	if err != nil {
+		t.Fatalf("LoadABIFile() error: %v", err)
+	}
+	generator := MessageSchemaGenerator{Namespace: "test", Account: "eosio.nft.ft"}
+	registry := srclient.CreateMockSchemaRegistryClient("mock://TestStreamedAbiCodec_readOnly")
+	newCodec := func(options ...StreamedAbiCodecOption) ABICodec {
+		return NewStreamedAbiCodec(
+			&AbiRepositoryStub{abi: abi},
+			generator.getTableSchema,
+			registry,
+			"eosio.nft.ft",
+			"mock://TestStreamedAbiCodec_readOnly",
+			srclient.Forward,
+			options...,
+		)
+	}
+	registered, err := newCodec().GetCodec(CodecId{"eosio.nft.ft", "factory.a"}, 42)
+	if err != nil {
+		t.Fatalf("GetCodec() error: %v", err)
+	}
+	// register a schema that does not match the generated one
+	factoryB, _ := generator.getTableSchema("factory.b", abi)
+	mismatch, _ := json.Marshal(factoryB)
+	if _, err := registry.CreateSchema(schemaSubject(factoryB), strings.Replace(string(mismatch), `"name":"block_num"`, `"name":"block_number"`, 1), srclient.Avro); err != nil {
+		t.Fatalf("CreateSchema() error: %v", err)
+	}
+
+	readOnly := newCodec(WithReadOnlySchemas())
+	found, err := readOnly.GetCodec(CodecId{"eosio.nft.ft", "factory.a"}, 42)
+	if err != nil {
+		t.Fatalf("read-only GetCodec() error: %v", err)
+	}
+	if found.(KafkaAvroCodec).schema.id != registered.(KafkaAvroCodec).schema.id {
+		t.Errorf("read-only GetCodec() schema id = %d, want %d", found.(KafkaAvroCodec).schema.id, registered.(KafkaAvroCodec).schema.id)
+	}
+	if _, err := readOnly.GetCodec(CodecId{"eosio.nft.ft", "factory.b"}, 42); err == nil || !strings.Contains(err.Error(), "block_num") {
+		t.Errorf("read-only GetCodec() expected a diff error on block_num, got: %v", err)
+	}
+	if _, err := readOnly.GetCodec(CodecId{"eosio.nft.ft", "token.a"}, 42); err == nil || !strings.Contains(err.Error(), "no schema registered") {
+		t.Errorf("read-only GetCodec() expected an error on unregistered subject, got: %v", err)
+	}
+	versions, _ := registry.GetSchemaVersions(schemaSubject(factoryB))
+	if len(versions) != 1 {
+		t.Errorf("read-only codec registered a schema: %v", versions)
+	}
+}
+
+func TestStreamedAbiCodec_readOnly_logicalTypes(t *testing.T) {
+	abi, err := LoadABIFile("eosio.nft.ft", "testdata/eosio.nft.ft.abi")
+	if err != nil {
+		t.Fatalf("LoadABIFile() error: %v", err)
+	}
+	generator := MessageSchemaGenerator{Namespace: "test", Account: "eosio.nft.ft"}
+	factoryA, _ := generator.getTableSchema("factory.a", abi)
+	generated, _ := json.Marshal(factoryA)
+	tests := []struct {
+		name       string
+		old        string
+		new        string
+		wantErr    bool
+		wantInDiff string
+	}{
+		{"same schema", "", "", false, ""},
+		{"timestamp-micros", `"logicalType":"timestamp-millis"`, `"logicalType":"timestamp-micros"`, true, "timestamp-micros"},
+		{"decimal scale", `"scale":8`, `"scale":4`, true, `"scale":4`},
+		{"decimal precision", `"precision":32`, `"precision":16`, true, `"precision":16`},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			registered := string(generated)
+			if tt.old != "" {
+				if !strings.Contains(registered, tt.old) {
+					t.Fatalf("generated schema does not contain: %s", tt.old)
+				}
+				registered = strings.Replace(registered, tt.old, tt.new, 1)
+			}
+			registry := srclient.CreateMockSchemaRegistryClient("mock://TestStreamedAbiCodec_readOnly_logicalTypes")
+			newCodec := func(options ...StreamedAbiCodecOption) ABICodec {
+				return NewStreamedAbiCodec(
+					&AbiRepositoryStub{abi: abi},
+					generator.getTableSchema,
+					registry,
+					"eosio.nft.ft",
+					"mock://TestStreamedAbiCodec_readOnly_logicalTypes",
+					srclient.Forward,
+					options...,
+				)
+			}
+			// register the static schemas
+			newCodec()
+			if _, err := registry.CreateSchema(schemaSubject(factoryA), registered, srclient.Avro); err != nil {
+				t.Fatalf("CreateSchema() error: %v", err)
+			}
+			_, err := newCodec(WithReadOnlySchemas()).GetCodec(CodecId{"eosio.nft.ft", "factory.a"}, 42)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("read-only GetCodec() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if err != nil && !strings.Contains(err.Error(), tt.wantInDiff) {
+				t.Errorf("read-only GetCodec() error = %v, want a diff on %s", err, tt.wantInDiff)
+			}
+		})
+	}
+}
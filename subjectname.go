@@ -0,0 +1,53 @@
+package dkafka
+
+import (
+	"fmt"
+
+	"github.com/riferrei/srclient"
+)
+
+const (
+	// RecordNameStrategy names the subject after the record full name:
+	// <namespace>.<name>
+	RecordNameStrategy = "record"
+	// TopicNameStrategy names the subject after the topic: <topic>-value
+	TopicNameStrategy = "topic"
+	// TopicRecordNameStrategy names the subject after the topic and the
+	// record full name: <topic>-<namespace>.<name>
+	TopicRecordNameStrategy = "topic-record"
+)
+
+// SubjectNameStrategy returns the schema registry subject of a message schema
+type SubjectNameStrategy func(MessageSchema) string
+
+// NewSubjectNameStrategy returns the subject name strategy of the schemas of
+// the messages written in the given topic. The default strategy is the record
+// name strategy.
+func NewSubjectNameStrategy(strategy string, topic string) (SubjectNameStrategy, error) {
+	switch strategy {
+	case "", RecordNameStrategy:
+		return schemaSubject, nil
+	case TopicNameStrategy:
+		subject := fmt.Sprintf("%s-value", topic)
+		return func(MessageSchema) string {
+			return subject
+		}, nil
+	case TopicRecordNameStrategy:
+		return func(schema MessageSchema) string {
+			return fmt.Sprintf("%s-%s", topic, schemaSubject(schema))
+		}, nil
+	default:
+		return nil, fmt.Errorf("unsupported subject name strategy: '%s', expected one of: %s, %s, %s", strategy, RecordNameStrategy, TopicNameStrategy, TopicRecordNameStrategy)
+	}
+}
+
+// validateSubjectNameStrategy checks that the compatibility level can be
+// enforced on the subjects of the strategy. With the topic name strategy the
+// checkpoint, ABI update and table or action records are all registered in the
+// same subject.
+func validateSubjectNameStrategy(strategy string, compatibility srclient.CompatibilityLevel) error {
+	if strategy == TopicNameStrategy && compatibility != srclient.None {
+		return fmt.Errorf("the %s subject name strategy registers different record types in the same subject, it requires the %s compatibility, got: %s", TopicNameStrategy, srclient.None, compatibility)
+	}
+	return nil
+}
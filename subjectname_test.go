@@ -0,0 +1,67 @@
+package dkafka
+
+import (
+	"testing"
+
+	"github.com/riferrei/srclient"
+)
+
+func TestNewSubjectNameStrategy(t *testing.T) {
+	tests := []struct {
+		strategy string
+		want     string
+		wantErr  bool
+	}{
+		{strategy: "", want: "io.dkafka.DKafkaCheckpoint"},
+		{strategy: RecordNameStrategy, want: "io.dkafka.DKafkaCheckpoint"},
+		{strategy: TopicNameStrategy, want: "events-value"},
+		{strategy: TopicRecordNameStrategy, want: "events-io.dkafka.DKafkaCheckpoint"},
+		{strategy: "unknown", wantErr: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.strategy, func(t *testing.T) {
+			subjectName, err := NewSubjectNameStrategy(tt.strategy, "events")
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("NewSubjectNameStrategy() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if err != nil {
+				return
+			}
+			if got := subjectName(CheckpointMessageSchema); got != tt.want {
+				t.Errorf("subjectName() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestValidateSubjectNameStrategy(t *testing.T) {
+	if err := validateSubjectNameStrategy(TopicNameStrategy, srclient.Forward); err == nil {
+		t.Errorf("validateSubjectNameStrategy() expected an error for the %s strategy with %s compatibility", TopicNameStrategy, srclient.Forward)
+	}
+	if err := validateSubjectNameStrategy(TopicNameStrategy, srclient.None); err != nil {
+		t.Errorf("validateSubjectNameStrategy() error: %v", err)
+	}
+	if err := validateSubjectNameStrategy(TopicRecordNameStrategy, srclient.Forward); err != nil {
+		t.Errorf("validateSubjectNameStrategy() error: %v", err)
+	}
+}
+
+func TestStreamedAbiCodec_subjectNameStrategy(t *testing.T) {
+	registry := srclient.CreateMockSchemaRegistryClient("mock://TestStreamedAbiCodec_subjectNameStrategy")
+	subjectName, _ := NewSubjectNameStrategy(TopicRecordNameStrategy, "events")
+	NewStreamedAbiCodecWithTransaction(
+		&AbiRepositoryStub{},
+		MessageSchemaGenerator{Namespace: "test", Account: "eosio.nft.ft"}.getTableSchema,
+		registry,
+		"eosio.nft.ft",
+		"mock://TestStreamedAbiCodec_subjectNameStrategy",
+		srclient.Forward,
+		WithSubjectNameStrategy(subjectName),
+	)
+	for _, schema := range []MessageSchema{CheckpointMessageSchema, TransactionMessageSchema} {
+		subject := "events-" + schemaSubject(schema)
+		if _, err := registry.GetLatestSchema(subject); err != nil {
+			t.Errorf("GetLatestSchema(%s) error: %v", subject, err)
+		}
+	}
+}
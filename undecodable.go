@@ -0,0 +1,66 @@
+package dkafka
+
+import (
+	"fmt"
+)
+
+const (
+	FailUndecodableDBOp = "fail"
+	SkipUndecodableDBOp = "skip"
+	RawUndecodableDBOp  = "raw"
+)
+
+// undecodableErrorHeader carries the decoding error of the DB operations
+// emitted with the raw policy.
+const undecodableErrorHeader = "ce_dkafkaerror"
+
+const undecodedTableNotification = "UndecodedTableNotification"
+
+// UndecodedTableNotificationSchema is used by the raw policy to emit the DB
+// operations that cannot be decoded with the ABI, either because the table is
+// missing from the ABI or because the row decoding failed.
+var UndecodedTableNotificationSchema = newRecordFQN(
+	dkafkaNamespace,
+	undecodedTableNotification,
+	[]FieldSchema{
+		{
+			Name: "context",
+			Type: newNotificationContextSchema(),
+		},
+		{
+			Name: "action",
+			Type: newActionInfoBasicSchema(),
+		},
+		{
+			Name: "db_op",
+			Type: newDBOpBasicSchema(),
+		},
+		{
+			Name: "error",
+			Type: "string",
+		},
+	},
+)
+
+var UndecodedTableNotificationMessageSchema = MessageSchema{
+	UndecodedTableNotificationSchema,
+	newMeta(dkafkaMetaSupplier{}),
+}
+
+func newUndecodedTableNotification(context map[string]interface{}, action map[string]interface{}, dbOp map[string]interface{}, err error) map[string]interface{} {
+	return map[string]interface{}{
+		"context": context,
+		"action":  action,
+		"db_op":   dbOp,
+		"error":   err.Error(),
+	}
+}
+
+func ValidateUndecodableDBOpPolicy(policy string) error {
+	switch policy {
+	case FailUndecodableDBOp, SkipUndecodableDBOp, RawUndecodableDBOp:
+		return nil
+	default:
+		return fmt.Errorf("unsupported undecodable db op policy: '%s', expected one of: %s, %s, %s", policy, FailUndecodableDBOp, SkipUndecodableDBOp, RawUndecodableDBOp)
+	}
+}
@@ -0,0 +1,114 @@
+package dkafka
+
+import (
+	"fmt"
+	"testing"
+
+	pbcodec "github.com/dfuse-io/dfuse-eosio/pb/dfuse/eosio/codec/v1"
+	"github.com/riferrei/srclient"
+)
+
+func TestTableGenerator_undecodablePolicy(t *testing.T) {
+	abi, err := LoadABIFile("eosio.nft.ft", "testdata/eosio.nft.ft.abi")
+	if err != nil {
+		t.Fatalf("LoadABIFile() error: %v", err)
+	}
+	gc := newActionContext4Test(t, &pbcodec.DBOp{
+		Operation:  pbcodec.DBOp_OPERATION_INSERT,
+		Code:       "eosio.nft.ft",
+		Scope:      "eosio.nft.ft",
+		TableName:  "unknown.a",
+		PrimaryKey: "1",
+		NewData:    []byte{1, 2, 3},
+	})
+	tests := []struct {
+		policy       string
+		wantErr      bool
+		wantMessages int
+	}{
+		{policy: "", wantErr: true},
+		{policy: FailUndecodableDBOp, wantErr: true},
+		{policy: SkipUndecodableDBOp, wantMessages: 0},
+		{policy: RawUndecodableDBOp, wantMessages: 1},
+	}
+	for _, tt := range tests {
+		t.Run(tt.policy, func(t *testing.T) {
+			abiCodec := NewStreamedAbiCodec(
+				&AbiRepositoryStub{abi: abi},
+				MessageSchemaGenerator{Namespace: "test", Account: "eosio.nft.ft"}.getTableSchema,
+				srclient.CreateMockSchemaRegistryClient("mock://TestTableGenerator_undecodablePolicy"),
+				"eosio.nft.ft",
+				"mock://TestTableGenerator_undecodablePolicy",
+				srclient.Forward,
+				WithUndecodedTableNotifications(),
+			)
+			finder, _ := buildTableKeyExtractorFinder([]string{"*:k"})
+			g := TableGenerator{
+				getExtractKey:     finder,
+				abiCodec:          abiCodec,
+				targetedAccount:   "eosio.nft.ft",
+				undecodablePolicy: tt.policy,
+			}
+			messages, err := g.Apply(gc)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("Apply() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if len(messages) != tt.wantMessages {
+				t.Fatalf("Apply() expected %d message(s) got: %d", tt.wantMessages, len(messages))
+			}
+			if tt.policy != RawUndecodableDBOp {
+				return
+			}
+			msg := messages[0]
+			if findHeader(undecodableErrorHeader, msg.Headers) == "" {
+				t.Errorf("missing %s header", undecodableErrorHeader)
+			}
+			codec, err := abiCodec.GetCodec(UndecodedTableNotificationSchema.AsCodecId(), 42)
+			if err != nil {
+				t.Fatalf("GetCodec() error: %v", err)
+			}
+			value, err := codec.Unmarshal(msg.Value)
+			if err != nil {
+				t.Fatalf("Unmarshal() error: %v", err)
+			}
+			dbOp := value.(map[string]interface{})["db_op"].(map[string]interface{})
+			if newData, _ := dbOp["new_data"].(map[string]interface{})["bytes"].([]byte); string(newData) != string([]byte{1, 2, 3}) {
+				t.Errorf("new_data = %v, want [1 2 3]", dbOp["new_data"])
+			}
+		})
+	}
+}
+
+func TestWithUndecodedTableNotifications(t *testing.T) {
+	tests := []struct {
+		name       string
+		config     Config
+		wantSchema bool
+	}{
+		{name: "tables raw", config: Config{CdCType: TABLES_CDC_TYPE, UndecodableDBOpPolicy: RawUndecodableDBOp}, wantSchema: true},
+		{name: "tables skip", config: Config{CdCType: TABLES_CDC_TYPE, UndecodableDBOpPolicy: SkipUndecodableDBOp}},
+		{name: "actions", config: Config{CdCType: ACTIONS_CDC_TYPE, UndecodableDBOpPolicy: RawUndecodableDBOp}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			options, err := tt.config.streamedAbiCodecOptions(srclient.Forward)
+			if err != nil {
+				t.Fatalf("streamedAbiCodecOptions() error: %v", err)
+			}
+			registry := srclient.CreateMockSchemaRegistryClient("mock://TestWithUndecodedTableNotifications")
+			abiCodec := NewStreamedAbiCodec(
+				&AbiRepositoryStub{err: fmt.Errorf("no abi")},
+				nil,
+				registry,
+				"eosio.nft.ft",
+				"mock://TestWithUndecodedTableNotifications",
+				srclient.Forward,
+				options...,
+			)
+			_, err = abiCodec.GetCodec(UndecodedTableNotificationMessageSchema.AsCodecId(), 0)
+			if gotSchema := err == nil; gotSchema != tt.wantSchema {
+				t.Errorf("GetCodec(UndecodedTableNotification) error = %v, want schema %t", err, tt.wantSchema)
+			}
+		})
+	}
+}
@@ -0,0 +1,169 @@
+package dkafka
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"path"
+)
+
+const (
+	// UnionVariant maps the variant to a plain avro union of its types
+	UnionVariant = "union"
+	// TaggedVariant maps the variant to a {type, value} record
+	TaggedVariant = "tagged"
+	// JSONVariant maps the variant to its JSON string representation
+	JSONVariant = "json"
+	// CatchAllVariant maps the variant to the union of all the avro primitive
+	// types and an array of them
+	CatchAllVariant = "catch-all"
+)
+
+// VariantRule selects the strategy of the variants whose name matches the
+// Match pattern (see path.Match)
+type VariantRule struct {
+	Match    string `json:"match"`
+	Strategy string `json:"strategy"`
+}
+
+// VariantMapping is the ordered list of rules, the first matching rule wins.
+// Variants that match no rule are mapped to a plain union except for the
+// HardcodedUberVariant that is mapped to the catch-all union.
+type VariantMapping []VariantRule
+
+// LoadVariantMapping loads a JSON variant mapping file in this format:
+//
+//	[
+//	  {"match": "variant_int8_*", "strategy": "catch-all"},
+//	  {"match": "*", "strategy": "tagged"}
+//	]
+func LoadVariantMapping(filename string) (VariantMapping, error) {
+	content, err := os.ReadFile(filename)
+	if err != nil {
+		return nil, fmt.Errorf("cannot read variant mapping file: %s, error: %w", filename, err)
+	}
+	var mapping VariantMapping
+	if err := json.Unmarshal(content, &mapping); err != nil {
+		return nil, fmt.Errorf("cannot parse variant mapping file: %s, error: %w", filename, err)
+	}
+	if err := mapping.validate(); err != nil {
+		return nil, fmt.Errorf("invalid variant mapping file: %s, error: %w", filename, err)
+	}
+	return mapping, nil
+}
+
+func (m VariantMapping) validate() error {
+	for i, rule := range m {
+		if rule.Match == "" {
+			return fmt.Errorf("rule %d: missing match", i)
+		}
+		if _, err := path.Match(rule.Match, ""); err != nil {
+			return fmt.Errorf("rule %d: invalid match pattern: '%s', error: %w", i, rule.Match, err)
+		}
+		if err := checkMapping(rule.Match, rule.Strategy, UnionVariant, TaggedVariant, JSONVariant, CatchAllVariant); err != nil {
+			return fmt.Errorf("rule %d: %w", i, err)
+		}
+	}
+	return nil
+}
+
+func (m VariantMapping) strategyFor(name string) string {
+	for _, rule := range m {
+		if matched, _ := path.Match(rule.Match, name); matched {
+			return rule.Strategy
+		}
+	}
+	if _, found := hardcodedVariantType[name]; found {
+		return CatchAllVariant
+	}
+	return UnionVariant
+}
+
+// variantMapping is set by SchemaMappingProfile.apply
+var variantMapping VariantMapping
+
+func variantToUnion(abi *ABI, name string, visited map[string]string) (Schema, error) {
+	v := abi.VariantForName(name)
+	if v == nil {
+		return nil, nil
+	}
+	switch variantMapping.strategyFor(name) {
+	case CatchAllVariant:
+		return hardcodedVariantType[HardcodedUberVariant], nil
+	case JSONVariant:
+		return TypedSchema{Type: "string", EosType: name, Convert: "eos.VariantJSON"}, nil
+	case TaggedVariant:
+		union, err := variantTypesToUnion(abi, v.Types, visited)
+		if err != nil {
+			return nil, err
+		}
+		record := newRecordS(name, []FieldSchema{
+			{Name: "type", Type: "string", Doc: "ABI type name of the value"},
+			{Name: "value", Type: union},
+		})
+		record.Convert = "eos.TaggedVariant"
+		// named type must be defined only once
+		visited[name] = reference(record)
+		return record, nil
+	}
+	if len(v.Types) == 1 {
+		//edge case where there is only one type in the union
+		//then return the type ;)
+		return resolveType(abi, v.Types[0], visited)
+	}
+	return variantTypesToUnion(abi, v.Types, visited)
+}
+
+func variantTypesToUnion(abi *ABI, types []string, visited map[string]string) (Union, error) {
+	var union = make([]Schema, len(types))
+	for i, aType := range types {
+		if resolved, err := resolveFieldTypeSchema(abi, aType, visited); err == nil {
+			union[i] = resolved
+		} else {
+			return nil, err
+		}
+	}
+	return union, nil
+}
+
+// variantTag returns the eos type name and the value of a variant.
+// Natively decoded variants and JSON variants share the same representation:
+// [type, value]
+func variantTag(value interface{}) (string, interface{}, error) {
+	v, ok := value.([]interface{})
+	if !ok || len(v) != 2 {
+		return "", nil, fmt.Errorf("unsupported variant type: %T, expected: [type, value]", value)
+	}
+	tag, ok := v[0].(string)
+	if !ok {
+		return "", nil, fmt.Errorf("unsupported variant tag type: %T, expected a string", v[0])
+	}
+	return tag, v[1], nil
+}
+
+func variantJSONConverter(f func([]byte, interface{}) ([]byte, error)) func([]byte, interface{}) ([]byte, error) {
+	return func(bytes []byte, value interface{}) ([]byte, error) {
+		if _, _, err := variantTag(value); err != nil {
+			return bytes, err
+		}
+		j, err := json.Marshal(value)
+		if err != nil {
+			return bytes, fmt.Errorf("cannot marshal variant to json: %w", err)
+		}
+		return f(bytes, string(j))
+	}
+}
+
+func taggedVariantConverter(f func([]byte, interface{}) ([]byte, error)) func([]byte, interface{}) ([]byte, error) {
+	return func(bytes []byte, value interface{}) ([]byte, error) {
+		tag, _, err := variantTag(value)
+		if err != nil {
+			return bytes, err
+		}
+		// the value union resolves its member from the variant itself
+		return f(bytes, map[string]interface{}{
+			"type":  tag,
+			"value": value,
+		})
+	}
+}
@@ -0,0 +1,166 @@
+package dkafka
+
+import (
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/eoscanada/eos-go"
+	"github.com/go-test/deep"
+)
+
+func TestLoadVariantMapping(t *testing.T) {
+	tests := []struct {
+		name    string
+		content string
+		want    VariantMapping
+		wantErr bool
+	}{
+		{
+			name:    "rules",
+			content: `[{"match": "variant_*", "strategy": "json"}, {"match": "*", "strategy": "tagged"}]`,
+			want:    VariantMapping{{Match: "variant_*", Strategy: JSONVariant}, {Match: "*", Strategy: TaggedVariant}},
+		},
+		{
+			name:    "unknown-strategy",
+			content: `[{"match": "*", "strategy": "record"}]`,
+			wantErr: true,
+		},
+		{
+			name:    "invalid-pattern",
+			content: `[{"match": "[", "strategy": "json"}]`,
+			wantErr: true,
+		},
+		{
+			name:    "invalid-json",
+			content: `{"match": "*"}`,
+			wantErr: true,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			filename := filepath.Join(t.TempDir(), "variants.json")
+			if err := os.WriteFile(filename, []byte(tt.content), 0644); err != nil {
+				t.Fatal(err)
+			}
+			got, err := LoadVariantMapping(filename)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("LoadVariantMapping() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if diff := deep.Equal(got, tt.want); diff != nil {
+				t.Errorf("LoadVariantMapping() diff: %v", diff)
+			}
+		})
+	}
+}
+
+func TestVariantMapping_strategyFor(t *testing.T) {
+	initBuiltInTypesForTables(SchemaMappingProfile{})
+	mapping := VariantMapping{{Match: "value_*", Strategy: JSONVariant}, {Match: "other", Strategy: TaggedVariant}}
+	tests := []struct {
+		variant string
+		want    string
+	}{
+		{variant: "value_type", want: JSONVariant},
+		{variant: "other", want: TaggedVariant},
+		{variant: "unknown", want: UnionVariant},
+		{variant: HardcodedUberVariant, want: CatchAllVariant},
+	}
+	for _, tt := range tests {
+		t.Run(tt.variant, func(t *testing.T) {
+			if got := mapping.strategyFor(tt.variant); got != tt.want {
+				t.Errorf("strategyFor() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestVariantMapping_encoding(t *testing.T) {
+	abi := &ABI{
+		ABI: &eos.ABI{
+			Structs: []eos.StructDef{
+				{
+					Name:   "key_def",
+					Fields: []eos.FieldDef{{Name: "name", Type: "string"}},
+				},
+				{
+					Name: "row",
+					Fields: []eos.FieldDef{
+						{Name: "value", Type: "value_type"},
+						{Name: "optional_value", Type: "value_type?"},
+					},
+				},
+			},
+			Variants: []eos.VariantDef{
+				{Name: "value_type", Types: []string{"uint32", "string", "key_def", "uint8[]"}},
+			},
+			Tables: []eos.TableDef{{Name: "rows", Type: "row"}},
+		},
+		Account: "test",
+	}
+	keyDef := []interface{}{"key_def", map[string]interface{}{"name": "gold"}}
+	array := []interface{}{"uint8[]", []interface{}{uint8(1), uint8(2)}}
+	tests := []struct {
+		strategy string
+		value    []interface{}
+		want     interface{}
+	}{
+		{
+			strategy: UnionVariant,
+			value:    keyDef,
+			want:     map[string]interface{}{"KeyDef": map[string]interface{}{"name": "gold"}},
+		},
+		{
+			strategy: UnionVariant,
+			value:    array,
+			want:     map[string]interface{}{"array": []interface{}{int32(1), int32(2)}},
+		},
+		{
+			strategy: TaggedVariant,
+			value:    keyDef,
+			want: map[string]interface{}{
+				"type":  "key_def",
+				"value": map[string]interface{}{"KeyDef": map[string]interface{}{"name": "gold"}},
+			},
+		},
+		{
+			strategy: JSONVariant,
+			value:    keyDef,
+			want:     `["key_def",{"name":"gold"}]`,
+		},
+		{
+			strategy: CatchAllVariant,
+			value:    []interface{}{"uint32", uint32(7)},
+			want:     map[string]interface{}{"long": int64(7)},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.strategy, func(t *testing.T) {
+			record, err := tableToRecord(abi, "rows", SchemaMappingProfile{Variants: VariantMapping{{Match: "*", Strategy: tt.strategy}}})
+			if err != nil {
+				t.Fatalf("tableToRecord() error: %v", err)
+			}
+			schema, err := json.Marshal(record)
+			if err != nil {
+				t.Fatalf("json.Marshal() error: %v", err)
+			}
+			codec := newAvroCodec(t, string(schema))
+			bytes, err := codec.BinaryFromNative(nil, map[string]interface{}{"value": tt.value, "optional_value": tt.value})
+			if err != nil {
+				t.Fatalf("BinaryFromNative() error: %v", err)
+			}
+			native, _, err := codec.NativeFromBinary(bytes)
+			if err != nil {
+				t.Fatalf("NativeFromBinary() error: %v", err)
+			}
+			decoded := native.(map[string]interface{})
+			if diff := deep.Equal(decoded["value"], tt.want); diff != nil {
+				t.Errorf("value diff: %v", diff)
+			}
+			if decoded["optional_value"] == nil {
+				t.Errorf("optional_value is nil")
+			}
+		})
+	}
+}